@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "alias",
+		args:  "<name> <alias>...",
+		short: "Add short names to a config",
+		long: `Aliases can be used instead of the config name to switch to or delete a config. Aliases
+can also be configured in the aliases setting of the config file. With prefix-match enabled a
+unique prefix of a config name or alias works as well.`,
+		minArgs:    2,
+		maxArgs:    -1,
+		configArgs: 1,
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			for _, alias := range args[1:] {
+				if _, err := manager.AddAlias(ctx, args[0], alias); err != nil {
+					return err
+				}
+			}
+			fmt.Println("Updated", args[0])
+			return nil
+		},
+	})
+	registerCommand(&command{
+		name:    "unalias",
+		args:    "<alias>...",
+		short:   "Remove aliases of configs",
+		minArgs: 1,
+		maxArgs: -1,
+		complete: func(positional []string) []string {
+			return aliasNames()
+		},
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			for _, alias := range args {
+				cfg, err := manager.RemoveAlias(ctx, alias)
+				if err != nil {
+					return err
+				}
+				fmt.Println("Updated", cfg.Name)
+			}
+			return nil
+		},
+	})
+	registerCommand(&command{
+		name:    "aliases",
+		short:   "List all aliases and their configs",
+		maxArgs: 0,
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			aliases, err := manager.Aliases(ctx)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, alias := range slices.Sorted(maps.Keys(aliases)) {
+				fmt.Fprintf(w, "%s\t%s\n", alias, aliases[alias])
+			}
+			return w.Flush()
+		},
+	})
+}
+
+// aliasNames returns the aliases stored in the metadata of the configs. Errors are ignored like
+// in configNames.
+func aliasNames() []string {
+	manager, err := co.NewManager(home, append(locationOptions(), co.WithBackend(config.Store))...)
+	if err != nil {
+		return nil
+	}
+	aliases, err := manager.Aliases(context.Background())
+	if err != nil {
+		return nil
+	}
+	return slices.Sorted(maps.Keys(aliases))
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+// auditTimeLayout is the format of the times printed by the log command.
+const auditTimeLayout = "2006-01-02 15:04:05"
+
+func init() {
+	registerCommand(&command{
+		name:  "log",
+		args:  "[<name>] [--since <time>] [--until <time>] [--user <user>] [--action <action>] [--outcome <outcome>] [--json]",
+		short: "Show the audit log of the changes to the config store",
+		long: `Every add, switch (link), delete, rename and metadata change (edit) is appended to the
+audit log in the state directory with the time, user, host, terminal, the configs involved and
+the outcome. The log is rotated when it grows beyond audit-max-size bytes and audit-max-files
+rotated logs are kept. Set audit-log to false to turn it off.
+
+With a name only the entries of that config are shown. --since and --until take a duration
+back from now like 24h, a date like 2024-05-01 or an RFC 3339 time. --json prints the entries as
+JSON lines.`,
+		maxArgs:  1,
+		complete: func(positional []string) []string { return configNames() },
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeySince, "", "Only show entries since this time or duration ago")
+			flags.String(viperKeyUntil, "", "Only show entries until this time or duration ago")
+			flags.String(viperKeyUser, "", "Only show entries of this user")
+			flags.String(viperKeyAction, "", "Only show entries of this action: add, link, delete, rename, edit, update or expire")
+			flags.String(viperKeyOutcome, "", "Only show entries with this outcome: success or failure")
+			flags.Bool(viperKeyJSON, false, "Print the entries as JSON lines")
+		},
+		run: runLog,
+	})
+}
+
+func runLog(ctx context.Context, args []string) error {
+	filter, err := auditFilter(args, time.Now())
+	if err != nil {
+		return err
+	}
+	manager, err := newManager()
+	if err != nil {
+		return err
+	}
+	entries, err := manager.AuditLog(ctx, filter)
+	if err != nil {
+		return err
+	}
+	if config.JSON {
+		return printAuditJSON(os.Stdout, entries)
+	}
+	printAuditLog(os.Stdout, entries)
+	return nil
+}
+
+// auditFilter returns the filter of the log command line.
+func auditFilter(args []string, now time.Time) (co.AuditFilter, error) {
+	filter := co.AuditFilter{User: config.User, Action: config.Action, Outcome: config.Outcome}
+	if len(args) > 0 {
+		filter.Config = args[0]
+	}
+	var err error
+	if filter.Since, err = parseAuditTime(config.Since, now); err != nil {
+		return filter, fmt.Errorf("%w: --%s: %s", co.ErrUsage, viperKeySince, err)
+	}
+	if filter.Until, err = parseAuditTime(config.Until, now); err != nil {
+		return filter, fmt.Errorf("%w: --%s: %s", co.ErrUsage, viperKeyUntil, err)
+	}
+	switch filter.Action {
+	case "", co.AuditAdd, co.AuditLink, co.AuditDelete, co.AuditRename, co.AuditEdit, co.AuditUpdate, co.AuditExpire:
+	default:
+		return filter, fmt.Errorf("%w: unknown action %q", co.ErrUsage, filter.Action)
+	}
+	switch filter.Outcome {
+	case "", co.AuditSuccess, co.AuditFailure:
+	default:
+		return filter, fmt.Errorf("%w: unknown outcome %q", co.ErrUsage, filter.Outcome)
+	}
+	return filter, nil
+}
+
+// parseAuditTime parses a duration back from now, a date in the local time zone or an RFC 3339
+// time. An empty value is the zero time.
+func parseAuditTime(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil {
+		return now.Add(-d), nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, now.Location()); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("%q is neither a duration, a date nor an RFC 3339 time", value)
+}
+
+// printAuditLog prints entries as table in the local time zone.
+func printAuditLog(w io.Writer, entries []co.AuditEntry) {
+	if len(entries) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tUSER\tHOST\tTTY\tACTION\tCONFIG\tFROM\tTO\tOUTCOME")
+	for _, entry := range entries {
+		outcome := entry.Outcome
+		if entry.Error != "" {
+			outcome = fmt.Sprintf("%s: %s", outcome, entry.Error)
+		}
+		fields := []string{entry.Time.Local().Format(auditTimeLayout), entry.User, entry.Host, entry.TTY,
+			entry.Action, entry.Config, entry.From, entry.To, outcome}
+		for i, field := range fields {
+			if field == "" {
+				fields[i] = "-"
+			}
+		}
+		fmt.Fprintln(tw, strings.Join(fields, "\t"))
+	}
+	tw.Flush()
+}
+
+// printAuditJSON prints entries as JSON lines like they are stored.
+func printAuditJSON(w io.Writer, entries []co.AuditEntry) error {
+	encoder := json.NewEncoder(w)
+	for _, entry := range entries {
+		if err := encoder.Encode(entry); err != nil {
+			return err
+		}
+	}
+	return nil
+}
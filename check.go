@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "check",
+		args:  "[name|--all|--group <group>]",
+		short: "Check whether the clusters of a config are reachable",
+		long: `Every context of the config gets a TLS handshake with its server, an unauthenticated
+/version request and, if the user has a token, basic auth or a client certificate, an
+authenticated /api request. Without a name the current config is checked. The requests go
+through the proxy-url of the cluster or the proxy of HTTPS_PROXY, HTTP_PROXY and NO_PROXY.`,
+		maxArgs:    1,
+		configArgs: 1,
+		flags: func(flags *flag.FlagSet) {
+			flags.Bool(viperKeyAll, false, "Check all configs")
+			flags.String(viperKeyGroup, "", "Check all configs of the group")
+			flags.Duration(viperKeyTimeout, co.DefaultCheckTimeout, "Timeout for checking a single context")
+			flags.Int(viperKeyParallel, co.DefaultCheckParallelism, "Number of contexts checked at the same time")
+		},
+		run: runCheck,
+	})
+}
+
+func runCheck(ctx context.Context, args []string) error {
+	if config.All && (len(args) > 0 || config.Group != "") {
+		return fmt.Errorf("%w: either provide a config name, --%s or --%s", co.ErrUsage, viperKeyAll, viperKeyGroup)
+	}
+	manager, err := newManager()
+	if err != nil {
+		return err
+	}
+
+	names, err := namesOrGroup(ctx, manager, args)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 && !config.All {
+		current, err := manager.Current(ctx)
+		if err != nil {
+			return err
+		}
+		if current.Name == "" {
+			return fmt.Errorf("%w: %s is not a stored config", co.ErrNotFound, current.Path)
+		}
+		names = []string{current.Name}
+	}
+
+	results, err := manager.Check(ctx, co.CheckOptions{Timeout: config.Timeout, Parallelism: config.Parallel}, names...)
+	if err != nil {
+		return err
+	}
+	printCheckResults(results)
+
+	unhealthy := 0
+	for _, result := range results {
+		if !result.Healthy() {
+			unhealthy++
+		}
+	}
+	if unhealthy > 0 {
+		return fmt.Errorf("%d of %d contexts are unhealthy", unhealthy, len(results))
+	}
+	return nil
+}
+
+func printCheckResults(results []co.CheckResult) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CONFIG\tCONTEXT\tSERVER\tTLS\tVERSION\tAPI\tTIME\tSTATUS")
+	for _, result := range results {
+		status := "ok"
+		if !result.Healthy() {
+			status = result.Err.Error()
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%s\n",
+			result.Config,
+			orDash(result.Context),
+			orDash(result.Server),
+			orDash(result.TLSVersion),
+			orDash(result.ServerVersion),
+			orDash(statusCode(result.APIStatus)),
+			result.Duration.Round(time.Millisecond),
+			status)
+	}
+	w.Flush()
+}
+
+func statusCode(code int) string {
+	if code == 0 {
+		return ""
+	}
+	return strconv.Itoa(code)
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
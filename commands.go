@@ -0,0 +1,442 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"github.com/spf13/viper"
+	"github.com/steffakasid/eslog"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+// command is a subcommand of kubectl-co like 'add' or 'ls'.
+type command struct {
+	name    string
+	aliases []string
+	// args is the argument synopsis shown in the help, e.g. "<name> [path]".
+	args  string
+	short string
+	long  string
+	// minArgs and maxArgs limit the number of positional arguments. maxArgs < 0 means unlimited.
+	minArgs int
+	maxArgs int
+	// configArgs is the number of leading arguments which are config names, used for completion.
+	configArgs int
+	// complete returns the completion candidates for the next positional argument. It replaces
+	// configArgs if set.
+	complete func(positional []string) []string
+	// flags registers the command specific flags.
+	flags func(flags *flag.FlagSet)
+	run   func(ctx context.Context, args []string) error
+}
+
+var commands = map[string]*command{}
+
+// registerCommand adds cmd to the command tree. It panics on duplicate names so mistakes show
+// up on the first run.
+func registerCommand(cmd *command) {
+	for _, name := range append([]string{cmd.name}, cmd.aliases...) {
+		if _, ok := commands[name]; ok {
+			panic(fmt.Sprintf("command %s registered twice", name))
+		}
+		commands[name] = cmd
+	}
+}
+
+// commandNames returns the sorted primary names of all commands.
+func commandNames() []string {
+	names := []string{}
+	for name, cmd := range commands {
+		if name == cmd.name {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// reservedNames returns the names and aliases of all commands. Configs named like them can't be
+// switched to with 'kubectl co <name>', so they aren't accepted as names of new configs.
+func reservedNames() []string {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// commandSummary returns one line per command for the usage output.
+func commandSummary() string {
+	var sb strings.Builder
+	for _, name := range commandNames() {
+		fmt.Fprintf(&sb, "  %-12s %s\n", name, commands[name].short)
+	}
+	return sb.String()
+}
+
+// flagSet returns the flags of the command including the global flags.
+func (cmd *command) flagSet() *flag.FlagSet {
+	flags := flag.NewFlagSet(cmd.name, flag.ContinueOnError)
+	flags.SetOutput(io.Discard)
+	flags.AddFlagSet(globalFlags())
+	if cmd.flags != nil {
+		cmd.flags(flags)
+	}
+	return flags
+}
+
+// validateArgs checks the number of positional arguments.
+func (cmd *command) validateArgs(args []string) error {
+	if len(args) < cmd.minArgs || (cmd.maxArgs >= 0 && len(args) > cmd.maxArgs) {
+		return fmt.Errorf("%w: usage: kubectl co %s %s", co.ErrUsage, cmd.name, cmd.args)
+	}
+	return nil
+}
+
+func (cmd *command) usage(out io.Writer) {
+	fmt.Fprintf(out, "%s\n\nUsage:\n  kubectl co %s %s\n", cmd.short, cmd.name, cmd.args)
+	if len(cmd.aliases) > 0 {
+		fmt.Fprintf(out, "\nAliases:\n  %s\n", strings.Join(cmd.aliases, ", "))
+	}
+	if cmd.long != "" {
+		fmt.Fprintf(out, "\n%s\n", strings.TrimSpace(cmd.long))
+	}
+	fmt.Fprintf(out, "\nFlags:\n%s", cmd.flagSet().FlagUsages())
+}
+
+// parseCommandLine resolves the command to run and its positional arguments. The legacy flags
+// --add, --delete, --previous and --current are mapped to their commands and a single unknown
+// argument switches to the config with that name.
+func parseCommandLine(argv []string) (*command, []string, error) {
+	root := globalFlags()
+	root.AddFlagSet(legacyFlags())
+	root.SetOutput(io.Discard)
+	root.SetInterspersed(false)
+	if err := root.Parse(argv); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", co.ErrUsage, err)
+	}
+	if err := bindFlags(root); err != nil {
+		return nil, nil, err
+	}
+	rest := root.Args()
+	globalArgs := argv[:len(argv)-len(rest)]
+
+	switch {
+	case viper.GetBool(viperKeyVersion):
+		return commands["version"], nil, nil
+	case config.Add || config.Delete || config.Previous || config.Current:
+		return legacyCommand(globalArgs, rest)
+	case len(rest) == 0 && viper.GetBool(viperKeyHelp):
+		return commands["help"], nil, nil
+	case len(rest) == 0:
+		return commands["ls"], nil, nil
+	}
+
+	cmd, ok := commands[rest[0]]
+	if ok {
+		rest = rest[1:]
+	} else {
+		cmd = commands["use"]
+	}
+
+	// the global flags given before the command are parsed again so all flags are bound from
+	// the same flag set
+	flags := cmd.flagSet()
+	if err := flags.Parse(append(append([]string{}, globalArgs...), rest...)); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", co.ErrUsage, err)
+	}
+	if err := bindFlags(flags); err != nil {
+		return nil, nil, err
+	}
+	if viper.GetBool(viperKeyHelp) {
+		return commands["help"], []string{cmd.name}, nil
+	}
+	if err := cmd.validateArgs(flags.Args()); err != nil {
+		return nil, nil, err
+	}
+	return cmd, flags.Args(), nil
+}
+
+// legacyCommand maps the legacy flags to their command. The arguments following them are
+// parsed with the flags of the command, e.g. --ttl of add.
+func legacyCommand(globalArgs, rest []string) (*command, []string, error) {
+	var cmd *command
+	switch {
+	case config.Add:
+		cmd = commands["add"]
+	case config.Delete:
+		cmd = commands["rm"]
+	case config.Previous:
+		cmd = commands["prev"]
+	default:
+		cmd = commands["current"]
+	}
+
+	flags := cmd.flagSet()
+	flags.AddFlagSet(legacyFlags())
+	if err := flags.Parse(append(append([]string{}, globalArgs...), rest...)); err != nil {
+		return nil, nil, fmt.Errorf("%w: %w", co.ErrUsage, err)
+	}
+	if err := bindFlags(flags); err != nil {
+		return nil, nil, err
+	}
+	args := flags.Args()
+	if err := validateFlags(args); err != nil {
+		return nil, nil, err
+	}
+	return cmd, args, cmd.validateArgs(args)
+}
+
+func init() {
+	registerCommand(&command{
+		name:  "add",
+		args:  "<name> [path|-|url] [--ttl <duration>]",
+		short: "Add a config by copying the file at path or by creating an empty one",
+		long: `With - the config is read from stdin, e.g. 'vault read -field=kubeconfig secret/dev | kubectl
+co add dev -'. An http(s):// or file:// URL is fetched, --fetch-timeout limits the time spent on
+it. Configs read from stdin or a URL must be kubeconfigs.
+
+With --ttl, e.g. --ttl 8h, the config is temporary: switching to it warns when it expires
+within the next hour and once it expired the next command moves it to the trash (see 'gc').`,
+		minArgs:    1,
+		maxArgs:    2,
+		configArgs: 1,
+		flags: func(flags *flag.FlagSet) {
+			flags.Duration(viperKeyTTL, 0, "Time after which the config expires and is moved to the trash")
+			flags.Duration(viperKeyFetchTimeout, co.DefaultFetchTimeout, "Timeout for fetching the config from a URL")
+		},
+		run: func(ctx context.Context, args []string) error {
+			if config.TTL < 0 {
+				return fmt.Errorf("%w: --%s must not be negative", co.ErrUsage, viperKeyTTL)
+			}
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			source := ""
+			if len(args) == 2 {
+				source = args[1]
+			}
+			added, err := manager.Add(ctx, args[0], source)
+			if errors.Is(err, co.ErrExists) {
+				return fmt.Errorf("%w, use 'kubectl co update %s <path>' to merge a refreshed config into it", err, args[0])
+			}
+			if err != nil || config.TTL == 0 {
+				return err
+			}
+			added, err = manager.SetExpiry(ctx, added.Name, time.Now().Add(config.TTL))
+			if err != nil {
+				return err
+			}
+			eslog.Infof("%s expires at %s", added.Name, added.Metadata.Expires.Local().Format(time.DateTime))
+			return nil
+		},
+	})
+	registerCommand(&command{
+		name:    "use",
+		aliases: []string{"switch"},
+		args:    "<name>",
+		short:   "Switch to the config with the given name",
+		long: `This overwrites ~/.kube/config with a symbolic link. 'kubectl co <name>' does the same.
+With --mode env ~/.kube/config is left alone and the statements setting KUBECONFIG to the config
+are printed instead, use them with eval.`,
+		minArgs:    1,
+		maxArgs:    1,
+		configArgs: 1,
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			logToStderrInEnvMode()
+			result, err := manager.Switch(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			return printSwitch(result)
+		},
+	})
+	registerCommand(&command{
+		name:    "rm",
+		aliases: []string{"delete"},
+		args:    "<name>|--group <group>",
+		short:   "Delete the config with the given name or all configs of a group",
+		long: `Before the config is deleted ~/.kube/config is linked to the previous config. With --group
+~/.kube/config is linked to a config outside the group if it links to a config of the group,
+or removed if no config remains.`,
+		maxArgs:    1,
+		configArgs: 1,
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeyGroup, "", "Delete all configs of the group")
+		},
+		run: func(ctx context.Context, args []string) error {
+			if (len(args) == 0) == (config.Group == "") {
+				return fmt.Errorf("%w: either provide a config name or --%s", co.ErrUsage, viperKeyGroup)
+			}
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			if config.Group != "" {
+				return deleteGroup(ctx, manager, config.Group)
+			}
+			result, err := manager.Delete(ctx, args[0])
+			if err != nil {
+				return err
+			}
+			if err := printSwitch(result.Switch); err != nil {
+				return err
+			}
+			fmt.Println("Deleted", result.Deleted.Path)
+			return nil
+		},
+	})
+	registerCommand(&command{
+		name:       "mv",
+		aliases:    []string{"rename"},
+		args:       "<name> <newname>",
+		short:      "Rename a config",
+		minArgs:    2,
+		maxArgs:    2,
+		configArgs: 1,
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			cfg, err := manager.Rename(ctx, args[0], args[1])
+			if err == nil {
+				fmt.Println("Renamed", args[0], "to", cfg.Name)
+			}
+			return err
+		},
+	})
+	registerCommand(&command{
+		name:    "ls",
+		aliases: []string{"list"},
+		short:   "List all available configs",
+		long: `The current config is highlighted and every config is colored by its color or the color
+of its environment (see the colors setting). 'kubectl co' without arguments does the same.
+--sort recent lists the most recently used configs first, --sort frequent the configs switched
+to most often. With --wide the metadata, the number of switches and the expiry dates of the
+embedded client certificates, certificate authorities and JWT bearer tokens are shown. Expiries within warn-days are marked.`,
+		maxArgs: 0,
+		flags: func(flags *flag.FlagSet) {
+			flags.BoolP(viperKeyWide, "w", false, "Show the metadata and the expiry dates of the credentials")
+			flags.StringP(viperKeySelector, "l", "", "Only list configs matching the label selector, e.g. env=prod,team!=ops")
+			flags.String(viperKeyGroup, "", "Only list configs of the group")
+			flags.String(viperKeySort, co.SortName, "Order of the configs: "+strings.Join(co.SortOrders, ", "))
+		},
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			filter, err := co.Selector(config.Selector)
+			if err != nil {
+				return err
+			}
+			filters := []co.Filter{filter}
+			if config.Group != "" {
+				if err := co.ValidateGroup(config.Group); err != nil {
+					return err
+				}
+				filters = append(filters, co.InGroup(config.Group))
+			}
+			configs, err := manager.List(ctx, filters...)
+			if err != nil {
+				return err
+			}
+			if err := co.SortConfigs(configs, config.Sort); err != nil {
+				return err
+			}
+			printConfigs(ctx, manager, configs)
+			return nil
+		},
+	})
+	registerCommand(&command{
+		name:  "current",
+		short: "Show the current config path",
+		long: `Shows the config kubectl uses: the config ~/.kube/config links to or, if the KUBECONFIG
+environment variable is set, the first of its files setting a current context.`,
+		maxArgs: 0,
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			current, err := manager.Current(ctx)
+			if err != nil {
+				return err
+			}
+			fmt.Println(current.Path)
+			if kubeConfig := os.Getenv("KUBECONFIG"); kubeConfig != "" {
+				fmt.Fprintf(os.Stderr, "KUBECONFIG is set to %s\n", kubeConfig)
+			}
+			return nil
+		},
+	})
+	registerCommand(&command{
+		name:    "prev",
+		aliases: []string{"previous"},
+		short:   "Switch to the previous config",
+		maxArgs: 0,
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			logToStderrInEnvMode()
+			result, err := manager.Previous(ctx)
+			if err != nil {
+				return err
+			}
+			return printSwitch(result)
+		},
+	})
+	registerCommand(&command{
+		name:    "completion",
+		args:    "bash|zsh",
+		short:   "Output the shell completion script",
+		minArgs: 1,
+		maxArgs: 1,
+		run: func(ctx context.Context, args []string) error {
+			return handleCompletionCommand(args)
+		},
+	})
+	registerCommand(&command{
+		name:    "version",
+		short:   "Show version information",
+		maxArgs: 0,
+		run: func(ctx context.Context, args []string) error {
+			fmt.Printf("kubectl-co version: %s\n", version)
+			return nil
+		},
+	})
+	registerCommand(&command{
+		name:    "help",
+		args:    "[command]",
+		short:   "Show help for kubectl-co or a command",
+		maxArgs: 1,
+		run: func(ctx context.Context, args []string) error {
+			if len(args) == 0 {
+				usage()
+				return nil
+			}
+			cmd, ok := commands[args[0]]
+			if !ok {
+				return fmt.Errorf("%w: unknown command %q", co.ErrUsage, args[0])
+			}
+			cmd.usage(os.Stderr)
+			return nil
+		},
+	})
+}
@@ -1,12 +1,17 @@
 package main
 
 import (
+	"context"
 	"fmt"
+	"maps"
 	"os"
+	"slices"
 	"strconv"
 	"strings"
 
-	"github.com/steffakasid/kubectl-co/internal"
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/eslog"
+	"github.com/steffakasid/kubectl-co/pkg/co"
 )
 
 func isCompletionInvocation() bool {
@@ -33,43 +38,174 @@ func handleCompletion() {
 		last := line[len(line)-1]
 		if last != ' ' && last != '\t' && len(words) > 0 {
 			cur = words[len(words)-1]
+			words = words[:len(words)-1]
+		}
+	}
+
+	for _, candidate := range completionCandidates(commandWords(words), cur) {
+		fmt.Println(candidate)
+	}
+}
+
+// commandWords strips the program name ("kubectl co" or "kubectl-co") from the words of the
+// command line.
+func commandWords(words []string) []string {
+	if len(words) > 0 && words[0] == "kubectl" {
+		words = words[1:]
+		if len(words) > 0 && words[0] == "co" {
+			words = words[1:]
+		}
+	} else if len(words) > 0 {
+		words = words[1:]
+	}
+	return words
+}
+
+// completionCandidates returns the completions for cur after the already typed words.
+func completionCandidates(words []string, cur string) []string {
+	var cmd *command
+	positional := []string{}
+	legacyConfigArg := false
+	flagValue := false
+	for _, word := range words {
+		switch {
+		case flagValue:
+			flagValue = false
+		case strings.HasPrefix(word, "-"):
+			flagValue = takesValue(completionFlags(cmd), word)
+			legacyConfigArg = legacyConfigArg || word == "--delete" || word == "-d" || word == "--add" || word == "-a"
+		case cmd == nil && len(positional) == 0 && commands[word] != nil:
+			cmd = commands[word]
+		default:
+			positional = append(positional, word)
 		}
 	}
 
-	flags := []string{"--add", "--delete", "--previous", "--current", "--debug", "--help", "--version"}
 	if strings.HasPrefix(cur, "-") {
-		for _, flag := range flags {
-			if strings.HasPrefix(flag, cur) {
-				fmt.Println(flag)
-			}
+		return matching(flagNames(completionFlags(cmd)), cur)
+	}
+	if flagValue {
+		switch words[len(words)-1] {
+		case "--" + viperKeyGroup:
+			return matching(groupNames(), cur)
+		case "--" + viperKeyShell:
+			return matching(shells, cur)
+		case "--" + viperKeySort:
+			return matching(co.SortOrders, cur)
 		}
-		return
+		return nil
 	}
 
-	home, err := os.UserHomeDir()
-	if err != nil {
-		return
+	switch {
+	case cmd == nil && len(positional) == 0 && !legacyConfigArg:
+		return matching(append(commandNames(), configNames()...), cur)
+	case cmd == nil && len(positional) == 0:
+		return matching(configNames(), cur)
+	case cmd != nil && cmd.name == "completion" && len(positional) == 0:
+		return matching([]string{"bash", "zsh"}, cur)
+	case cmd != nil && cmd.name == "help" && len(positional) == 0:
+		return matching(commandNames(), cur)
+	case cmd != nil && cmd.complete != nil:
+		return matching(cmd.complete(positional), cur)
+	case cmd != nil && len(positional) < cmd.configArgs:
+		return matching(configNames(), cur)
 	}
+	return nil
+}
+
+// flagNames returns the long names of all flags in flags.
+func flagNames(flags *flag.FlagSet) []string {
+	names := []string{}
+	flags.VisitAll(func(f *flag.Flag) {
+		names = append(names, "--"+f.Name)
+	})
+	return names
+}
 
-	completionCO, err := internal.NewCO(home)
+// configNames returns the names and aliases of all stored configs. Errors are ignored as
+// completion must not print anything but candidates.
+func configNames() []string {
+	manager, err := co.NewManager(home, append(locationOptions(), co.WithBackend(config.Store), co.WithAliases(configAliases()))...)
 	if err != nil {
-		return
+		return nil
 	}
-
-	err = completionCO.ListConfigs()
+	configs, err := manager.List(context.Background())
+	if err != nil {
+		return nil
+	}
+	if err := co.SortConfigs(configs, config.Sort); err != nil {
+		eslog.Debugf("Ignoring sort order: %s", err)
+	}
+	names := []string{}
+	for _, cfg := range configs {
+		names = append(names, cfg.Name)
+	}
+	aliases, err := manager.Aliases(context.Background())
 	if err != nil {
-		return
+		return names
+	}
+	for _, alias := range slices.Sorted(maps.Keys(aliases)) {
+		if !slices.Contains(names, alias) {
+			names = append(names, alias)
+		}
 	}
+	return names
+}
 
-	printConfigs(completionCO)
+// completionFlags returns the flags of cmd or, before a command is typed, the global and the
+// legacy flags.
+func completionFlags(cmd *command) *flag.FlagSet {
+	if cmd != nil {
+		return cmd.flagSet()
+	}
+	flags := globalFlags()
+	flags.AddFlagSet(legacyFlags())
+	return flags
 }
 
-func handleCompletionCommand(args []string) {
-	if len(args) != 1 {
-		fmt.Fprintln(os.Stderr, "Usage: kubectl-co completion bash|zsh")
-		return
+// takesValue reports whether the flag word is followed by its value, i.e. it isn't a boolean
+// flag and the value isn't given as --flag=value.
+func takesValue(flags *flag.FlagSet, word string) bool {
+	if strings.Contains(word, "=") {
+		return false
+	}
+	var f *flag.Flag
+	if name, ok := strings.CutPrefix(word, "--"); ok {
+		f = flags.Lookup(name)
+	} else if len(word) == 2 {
+		f = flags.ShorthandLookup(word[1:])
 	}
+	return f != nil && f.NoOptDefVal == ""
+}
+
+// groupNames returns the names of all groups. Errors are ignored like in configNames.
+func groupNames() []string {
+	manager, err := co.NewManager(home, append(locationOptions(), co.WithBackend(config.Store))...)
+	if err != nil {
+		return nil
+	}
+	groups, err := manager.Groups(context.Background())
+	if err != nil {
+		return nil
+	}
+	names := []string{}
+	for _, group := range groups {
+		names = append(names, group.Name)
+	}
+	return names
+}
+
+func matching(candidates []string, prefix string) []string {
+	matches := []string{}
+	for _, candidate := range candidates {
+		if strings.HasPrefix(candidate, prefix) {
+			matches = append(matches, candidate)
+		}
+	}
+	return matches
+}
 
+func handleCompletionCommand(args []string) error {
 	switch args[0] {
 	case "bash":
 		fmt.Println("complete -C kubectl-co kubectl-co")
@@ -79,6 +215,7 @@ func handleCompletionCommand(args []string) {
 		fmt.Println("complete -C kubectl-co kubectl-co")
 		fmt.Println("complete -C kubectl-co kubectl")
 	default:
-		fmt.Fprintln(os.Stderr, "Unsupported shell. Use 'bash' or 'zsh'.")
+		return fmt.Errorf("%w: unsupported shell %q. Use 'bash' or 'zsh'", co.ErrUsage, args[0])
 	}
+	return nil
 }
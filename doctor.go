@@ -0,0 +1,78 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"text/tabwriter"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/eslog"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "doctor",
+		args:  "[--fix]",
+		short: "Find and repair problems of the kube home and the config store",
+		long: `Inspects ~/.kube, ~/.kube/co, the configs and their metadata, the ~/.kube/config and
+previous links and the KUBECONFIG environment variable. Every problem is reported with its
+severity. With --fix dangling links and orphaned metadata are removed, the kube config is linked
+to the previous config if its target is gone, missing directories are created and permissions
+are restricted to the owner. Everything else is left for you to decide. The exit code is 1 if an
+error or warning remains.`,
+		maxArgs: 0,
+		flags: func(flags *flag.FlagSet) {
+			flags.Bool(viperKeyFix, false, "Repair the problems which can be repaired safely")
+		},
+		run: runDoctor,
+	})
+}
+
+func runDoctor(ctx context.Context, args []string) error {
+	problems, err := co.Doctor(ctx, home, config.Fix, managerOptions()...)
+	if err != nil {
+		return err
+	}
+	if len(problems) == 0 {
+		fmt.Println("No problems found")
+		return nil
+	}
+	printProblems(os.Stdout, problems)
+
+	remaining, fixable := 0, 0
+	for _, problem := range problems {
+		if problem.Fixed || problem.Severity == co.SeverityInfo {
+			continue
+		}
+		remaining++
+		if problem.Fix != "" && problem.FixErr == nil {
+			fixable++
+		}
+	}
+	if fixable > 0 {
+		eslog.Infof("Run 'kubectl co doctor --fix' to repair %d of them", fixable)
+	}
+	if remaining > 0 {
+		return fmt.Errorf("%d problems found", remaining)
+	}
+	return nil
+}
+
+func printProblems(out io.Writer, problems []co.Problem) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "SEVERITY\tPATH\tPROBLEM\tFIX")
+	for _, problem := range problems {
+		fix := orDash(problem.Fix)
+		switch {
+		case problem.Fixed:
+			fix += " (fixed)"
+		case problem.FixErr != nil:
+			fix += fmt.Sprintf(" (failed: %v)", problem.FixErr)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", problem.Severity, problem.Path, problem.Message, fix)
+	}
+	w.Flush()
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"path"
+	"slices"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+// shells are the shells env prints statements for.
+var shells = []string{"bash", "zsh", "fish"}
+
+func init() {
+	registerCommand(&command{
+		name:  "env",
+		args:  "[name]",
+		short: "Print statements exporting the environment variables of a config",
+		long: `Prints export statements for the variables of the config (see setenv) and unset statements
+for the variables of the config used before. Without a name the current config is used. Use it
+with eval, e.g. in a shell function switching configs:
+
+  kco() { kubectl co "$@" && eval "$(kubectl co env)"; }
+
+The shell defaults to the one in $SHELL.`,
+		maxArgs:    1,
+		configArgs: 1,
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeyShell, "", "Shell to print the statements for: "+strings.Join(shells, ", "))
+		},
+		run: func(ctx context.Context, args []string) error {
+			shell, err := envShell()
+			if err != nil {
+				return err
+			}
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			env, err := manager.Env(ctx, name)
+			if err == nil {
+				printEnv(os.Stdout, shell, env)
+			}
+			return err
+		},
+	})
+	registerCommand(&command{
+		name:       "setenv",
+		args:       "<name> KEY=value... KEY-...",
+		short:      "Set or remove environment variables of a config",
+		long:       "The variables are printed by 'kubectl co env', e.g. AWS_PROFILE or HTTPS_PROXY.",
+		minArgs:    2,
+		maxArgs:    -1,
+		configArgs: 1,
+		run: func(ctx context.Context, args []string) error {
+			set, remove, err := co.ParseLabelChanges(args[1:])
+			if err != nil {
+				return err
+			}
+			return updateMetadata(ctx, args[0], func(md *co.Metadata) error {
+				md.Variables = applyChanges(md.Variables, set, remove)
+				return nil
+			})
+		},
+	})
+}
+
+// envShell returns the shell given by --shell or, if not set, the shell in $SHELL. It defaults
+// to bash.
+func envShell() (string, error) {
+	if config.Shell != "" {
+		if !slices.Contains(shells, config.Shell) {
+			return "", fmt.Errorf("%w: unsupported shell %q. Use one of %s", co.ErrUsage, config.Shell, strings.Join(shells, ", "))
+		}
+		return config.Shell, nil
+	}
+	if shell := path.Base(os.Getenv("SHELL")); slices.Contains(shells, shell) {
+		return shell, nil
+	}
+	return "bash", nil
+}
+
+// printEnv writes the statements setting and unsetting the variables of env in the syntax of
+// shell to w.
+func printEnv(w io.Writer, shell string, env co.Env) {
+	for _, name := range env.Unset {
+		if shell == "fish" {
+			fmt.Fprintf(w, "set -e %s;\n", name)
+		} else {
+			fmt.Fprintf(w, "unset %s;\n", name)
+		}
+	}
+	for _, name := range slices.Sorted(maps.Keys(env.Set)) {
+		if shell == "fish" {
+			fmt.Fprintf(w, "set -gx %s %s;\n", name, fishQuote(env.Set[name]))
+		} else {
+			fmt.Fprintf(w, "export %s=%s;\n", name, shellQuote(env.Set[name]))
+		}
+	}
+}
+
+// shellQuote quotes s for POSIX shells.
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+// fishQuote quotes s for fish, which only knows \' and \\ as escapes in single quotes.
+func fishQuote(s string) string {
+	return "'" + strings.NewReplacer(`\`, `\\`, `'`, `\'`).Replace(s) + "'"
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/steffakasid/eslog"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+// keepExpiredCommands don't move expired configs to the trash before they run. They either
+// don't touch the store, only read the kube config link like prompt, which runs on every shell
+// prompt, or, like doctor and migrate, inspect the store as it is.
+var keepExpiredCommands = []string{"help", "version", "completion", "doctor", "migrate", "gc", "prompt"}
+
+func init() {
+	registerCommand(&command{
+		name:  "gc",
+		args:  "",
+		short: "Move expired temporary configs to the trash",
+		long: `Configs added with --ttl expire after that time. Every command moves expired configs with
+their metadata to the .trash directory in the state directory before it runs, gc does the same
+explicitly. If the current config expired, the previous config is switched to without running
+hooks, or ~/.kube/config is removed if there is no usable previous config.`,
+		maxArgs: 0,
+		run: func(ctx context.Context, args []string) error {
+			result, err := collectExpired(ctx)
+			if err != nil {
+				return err
+			}
+			if len(result.Trashed) == 0 {
+				eslog.Info("No expired configs")
+			}
+			return nil
+		},
+	})
+}
+
+// collectExpiredBefore moves the expired configs to the trash before cmd runs. The notices are
+// written to stderr so the output of cmd can still be evaluated, errors don't stop cmd.
+func collectExpiredBefore(ctx context.Context, cmd *command) {
+	if slices.Contains(keepExpiredCommands, cmd.name) {
+		return
+	}
+	eslog.Logger.SetOutput(os.Stderr)
+	defer eslog.Logger.SetOutput(os.Stdout)
+	_, err := collectExpired(ctx)
+	eslog.LogIfErrorf(err, eslog.Warnf, "Failed to move expired configs to the trash: %s")
+}
+
+// collectExpired moves the expired configs to the trash and logs what was done.
+func collectExpired(ctx context.Context) (co.GCResult, error) {
+	manager, err := newManager()
+	if err != nil {
+		return co.GCResult{}, err
+	}
+	result, err := manager.CollectExpired(ctx)
+	if len(result.Trashed) > 0 {
+		eslog.Warnf("Moved expired configs %s to %s", strings.Join(result.Trashed, ", "), result.Trash)
+	}
+	switch {
+	case result.SwitchedTo != "":
+		eslog.Warnf("Switched to %s as the current config expired", result.SwitchedTo)
+	case result.Unlinked:
+		eslog.Warn("Removed the kube config link as the current config expired")
+	}
+	return result, err
+}
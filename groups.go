@@ -0,0 +1,186 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "group",
+		args:  "<group> <name>...",
+		short: "Add configs to a group",
+		long: `Groups bundle configs, e.g. all configs of a customer. Use --group with ls, check, rm and
+export to work on all configs of a group.`,
+		minArgs:  2,
+		maxArgs:  -1,
+		complete: completeGroupArgs,
+		run: func(ctx context.Context, args []string) error {
+			return setGroup(ctx, args[0], args[1:], true)
+		},
+	})
+	registerCommand(&command{
+		name:     "ungroup",
+		args:     "<group> <name>...",
+		short:    "Remove configs from a group",
+		minArgs:  2,
+		maxArgs:  -1,
+		complete: completeGroupArgs,
+		run: func(ctx context.Context, args []string) error {
+			return setGroup(ctx, args[0], args[1:], false)
+		},
+	})
+	registerCommand(&command{
+		name:    "groups",
+		short:   "List all groups and their configs",
+		maxArgs: 0,
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			groups, err := manager.Groups(ctx)
+			if err != nil {
+				return err
+			}
+			w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+			for _, group := range groups {
+				fmt.Fprintf(w, "%s\t%s\n", group.Name, strings.Join(group.Configs, ","))
+			}
+			return w.Flush()
+		},
+	})
+	registerCommand(&command{
+		name:  "export",
+		args:  "<name>...|--group <group>",
+		short: "Export configs and their metadata as tar.gz archive",
+		long: `The archive has the layout of the store directory (~/.kube/co). It is written to stdout
+unless --output is given.`,
+		maxArgs:  -1,
+		complete: func(positional []string) []string { return configNames() },
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeyGroup, "", "Export all configs of the group")
+			flags.StringP(viperKeyOutput, "o", "", "Write the archive to this file")
+		},
+		run: runExport,
+	})
+}
+
+func setGroup(ctx context.Context, group string, names []string, member bool) error {
+	if err := co.ValidateGroup(group); err != nil {
+		return err
+	}
+	manager, err := newManager()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if _, err := manager.SetGroup(ctx, name, group, member); err != nil {
+			return err
+		}
+		fmt.Println("Updated", name)
+	}
+	return nil
+}
+
+// groupMembers returns the names of the configs in group. ErrNotFound is returned for groups
+// without configs.
+func groupMembers(ctx context.Context, manager *co.Manager, group string) ([]string, error) {
+	if err := co.ValidateGroup(group); err != nil {
+		return nil, err
+	}
+	configs, err := manager.List(ctx, co.InGroup(group))
+	if err != nil {
+		return nil, err
+	}
+	if len(configs) == 0 {
+		return nil, fmt.Errorf("%w: group %s has no configs", co.ErrNotFound, group)
+	}
+	names := make([]string, 0, len(configs))
+	for _, cfg := range configs {
+		names = append(names, cfg.Name)
+	}
+	return names, nil
+}
+
+// namesOrGroup returns args or, if --group is set, the configs of the group. Giving both is a
+// usage error.
+func namesOrGroup(ctx context.Context, manager *co.Manager, args []string) ([]string, error) {
+	if config.Group == "" {
+		return args, nil
+	}
+	if len(args) > 0 {
+		return nil, fmt.Errorf("%w: either provide config names or --%s", co.ErrUsage, viperKeyGroup)
+	}
+	return groupMembers(ctx, manager, config.Group)
+}
+
+// deleteGroup deletes all configs of the group and prints what was done. If the current config
+// is in the group, a config outside the group is switched to first.
+func deleteGroup(ctx context.Context, manager *co.Manager, group string) error {
+	result, err := manager.DeleteGroup(ctx, group)
+	switch {
+	case result.SwitchedTo != "":
+		fmt.Printf("Switched to %s as the current config is in group %s\n", result.SwitchedTo, group)
+	case result.Unlinked:
+		fmt.Printf("Removed the kube config link as no config outside group %s remains\n", group)
+	}
+	for _, name := range result.Deleted {
+		fmt.Println("Deleted", name)
+	}
+	return err
+}
+
+func runExport(ctx context.Context, args []string) error {
+	manager, err := newManager()
+	if err != nil {
+		return err
+	}
+	names, err := namesOrGroup(ctx, manager, args)
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		return fmt.Errorf("%w: provide config names or --%s", co.ErrUsage, viperKeyGroup)
+	}
+
+	if config.Output == "" {
+		if isTerminal(os.Stdout) {
+			return fmt.Errorf("%w: refusing to write the archive to a terminal, use --%s", co.ErrUsage, viperKeyOutput)
+		}
+		return manager.Export(ctx, os.Stdout, names...)
+	}
+
+	file, err := os.OpenFile(config.Output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", config.Output, err)
+	}
+	if err := manager.Export(ctx, file, names...); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %w", config.Output, err)
+	}
+	fmt.Fprintf(os.Stderr, "Exported %s to %s\n", strings.Join(names, ", "), config.Output)
+	return nil
+}
+
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// completeGroupArgs completes a group name as first and config names as further arguments.
+func completeGroupArgs(positional []string) []string {
+	if len(positional) == 0 {
+		return groupNames()
+	}
+	return configNames()
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"os"
+	"path"
+	"strings"
 
 	"github.com/steffakasid/eslog"
+	"github.com/steffakasid/kubectl-co/pkg/co"
 )
 
 var home string
@@ -13,3 +16,39 @@ func initHome() {
 	home, err = os.UserHomeDir()
 	eslog.LogIfErrorf(err, eslog.Fatalf, "Error getting user home directory: %s", err)
 }
+
+// locationOptions returns the Manager options of the store directory, the state directory and the
+// kube config path. With xdg the store and state directories default to the XDG base directories.
+func locationOptions() []co.Option {
+	storeDir, stateDir := expandHome(config.StoreDir), expandHome(config.StateDir)
+	if config.XDG {
+		if storeDir == "" {
+			storeDir = path.Join(xdgDir("XDG_DATA_HOME", ".local/share"), "kubectl-co")
+		}
+		if stateDir == "" {
+			stateDir = path.Join(xdgDir("XDG_STATE_HOME", ".local/state"), "kubectl-co")
+		}
+	}
+	return []co.Option{
+		co.WithStoreDir(storeDir),
+		co.WithStateDir(stateDir),
+		co.WithKubeConfigPath(expandHome(config.KubeConfigPath)),
+	}
+}
+
+// xdgDir returns the directory in the XDG environment variable or, if it isn't set to an absolute
+// path, the fallback below home.
+func xdgDir(variable, fallback string) string {
+	if dir := os.Getenv(variable); path.IsAbs(dir) {
+		return dir
+	}
+	return path.Join(home, fallback)
+}
+
+// expandHome replaces a leading ~ of p by the home directory.
+func expandHome(p string) string {
+	if p == "~" || strings.HasPrefix(p, "~/") {
+		return path.Join(home, strings.TrimPrefix(p, "~"))
+	}
+	return p
+}
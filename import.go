@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/eslog"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "import",
+		args:  "--from-dir <dir> [--watch] [--delete-source]",
+		short: "Add the kubeconfigs in a directory, e.g. ~/Downloads",
+		long: `Adds every kubeconfig in --from-dir to the store. The names are derived from the current
+context of the kubeconfig, or its cluster, and get a number appended if they are taken, rename
+them with 'kubectl co mv'. Kubeconfigs with the same content as a stored config, hidden files,
+partial downloads and other files are skipped. With --watch new files are imported as they
+appear until the command is interrupted. --delete-source removes the imported files and the
+ones already stored.`,
+		maxArgs: 0,
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeyFromDir, "", "Directory to import the kubeconfigs from")
+			flags.Bool(viperKeyWatch, false, "Keep watching the directory for new kubeconfigs")
+			flags.Bool(viperKeyDeleteSource, false, "Delete the imported files")
+		},
+		run: runImport,
+	})
+}
+
+func runImport(ctx context.Context, args []string) error {
+	if config.FromDir == "" {
+		return fmt.Errorf("%w: --%s is required", co.ErrUsage, viperKeyFromDir)
+	}
+	dir := expandHome(config.FromDir)
+	manager, err := newManager()
+	if err != nil {
+		return err
+	}
+
+	if config.Watch {
+		ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+		defer stop()
+		eslog.Infof("Watching %s for kubeconfigs, press Ctrl+C to stop", dir)
+		return manager.WatchImports(ctx, dir, config.DeleteSource, func(result co.ImportResult) {
+			reportImport(result)
+		})
+	}
+
+	results, err := manager.Import(ctx, dir, config.DeleteSource)
+	if err != nil {
+		return err
+	}
+	failed := 0
+	for _, result := range results {
+		if !reportImport(result) {
+			failed++
+		}
+	}
+	if len(results) == 0 {
+		eslog.Infof("No kubeconfigs found in %s", dir)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d files couldn't be imported", failed, len(results))
+	}
+	return nil
+}
+
+// reportImport logs the outcome of importing a file and returns false if it failed.
+func reportImport(result co.ImportResult) bool {
+	switch {
+	case result.Err != nil:
+		eslog.Errorf("Failed to import %s: %s", result.Source, result.Err)
+		return false
+	case result.Duplicate:
+		eslog.Infof("Skipped %s, it is the same as %s", result.Source, result.Name)
+	default:
+		eslog.Infof("Imported %s as %s", result.Source, result.Name)
+	}
+	return true
+}
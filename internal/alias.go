@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/steffakasid/eslog"
+)
+
+// WithAliases sets aliases in addition to the ones stored in the metadata of the configs. The
+// map goes from alias to config name. Its aliases take precedence over the metadata.
+func WithAliases(aliases map[string]string) Option {
+	return func(co *CO) {
+		co.aliases = aliases
+	}
+}
+
+// Aliases returns all aliases mapped to the names of their configs.
+func (co *CO) Aliases() (map[string]string, error) {
+	if err := co.ListConfigs(); err != nil {
+		return nil, err
+	}
+	store := co.store()
+	aliases := map[string]string{}
+	for _, name := range co.Configs {
+		md, err := store.Metadata(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, alias := range md.Aliases {
+			aliases[alias] = name
+		}
+	}
+	for alias, name := range co.aliases {
+		aliases[alias] = name
+	}
+	return aliases, nil
+}
+
+// ResolveName returns the config name meant by name. name is returned unchanged if a config
+// with this name exists, otherwise an alias or, if enabled by WithPrefixMatch, a unique
+// prefix of a config name or alias is resolved. A prefix matching several configs returns
+// ErrAmbiguous. If nothing matches name is returned unchanged, so the caller reports the
+// missing config.
+func (co *CO) ResolveName(name string) (string, error) {
+	target, err := co.ResolveAlias(name)
+	if err != nil || target != name || slices.Contains(co.Configs, name) || !co.prefixMatch {
+		return target, err
+	}
+	aliases, err := co.Aliases()
+	if err != nil {
+		return "", err
+	}
+
+	matches := map[string]bool{}
+	for _, config := range co.Configs {
+		if strings.HasPrefix(config, name) {
+			matches[config] = true
+		}
+	}
+	for alias, target := range aliases {
+		if strings.HasPrefix(alias, name) {
+			matches[target] = true
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return name, nil
+	case 1:
+		for target := range matches {
+			eslog.Debugf("Resolved prefix %s to %s", name, target)
+			return target, nil
+		}
+	}
+	candidates := make([]string, 0, len(matches))
+	for target := range matches {
+		candidates = append(candidates, target)
+	}
+	sort.Strings(candidates)
+	return "", fmt.Errorf("%w: %s matches %s", ErrAmbiguous, name, strings.Join(candidates, ", "))
+}
+
+// ResolveAlias returns the config name meant by name: name itself if a config with this name
+// exists or the config of the alias name. Unlike ResolveName it doesn't resolve prefixes, so
+// destructive operations only act on configs named exactly. If nothing matches name is returned
+// unchanged, so the caller reports the missing config.
+func (co *CO) ResolveAlias(name string) (string, error) {
+	if err := validateName(name); err != nil {
+		return "", err
+	}
+	if err := co.ListConfigs(); err != nil {
+		return "", err
+	}
+	if slices.Contains(co.Configs, name) {
+		return name, nil
+	}
+	aliases, err := co.Aliases()
+	if err != nil {
+		return "", err
+	}
+	if target, ok := aliases[name]; ok {
+		eslog.Debugf("Resolved alias %s to %s", name, target)
+		return target, nil
+	}
+	return name, nil
+}
+
+// AddAlias adds alias to the config co.ConfigName. It returns ErrExists if alias is the name of
+// a config or an alias of another config and ErrReserved if it is reserved (see
+// WithReservedNames).
+func (co *CO) AddAlias(alias string) error {
+	if err := co.validateNewName(alias); err != nil {
+		return err
+	}
+	if err := co.ListConfigs(); err != nil {
+		return err
+	}
+	if slices.Contains(co.Configs, alias) {
+		return fmt.Errorf("%w: %s is the name of a config", ErrExists, alias)
+	}
+	aliases, err := co.Aliases()
+	if err != nil {
+		return err
+	}
+	if target, ok := aliases[alias]; ok && target != co.ConfigName {
+		return fmt.Errorf("%w: %s is an alias of %s", ErrExists, alias, target)
+	}
+	return co.UpdateMetadata(func(md *Metadata) error {
+		if !slices.Contains(md.Aliases, alias) {
+			md.Aliases = append(md.Aliases, alias)
+			slices.Sort(md.Aliases)
+		}
+		return nil
+	})
+}
+
+// RemoveAlias removes alias from the metadata of its config and returns the config name.
+// Aliases set by WithAliases can't be removed.
+func (co *CO) RemoveAlias(alias string) (string, error) {
+	if _, ok := co.aliases[alias]; ok {
+		return "", fmt.Errorf("%w: alias %s is configured in the config file", ErrInvalid, alias)
+	}
+	aliases, err := co.Aliases()
+	if err != nil {
+		return "", err
+	}
+	name, ok := aliases[alias]
+	if !ok {
+		return "", fmt.Errorf("%w: alias %s", ErrNotFound, alias)
+	}
+	err = co.updateMetadata(name, func(md *Metadata) error {
+		md.Aliases = slices.DeleteFunc(md.Aliases, func(a string) bool { return a == alias })
+		if len(md.Aliases) == 0 {
+			md.Aliases = nil
+		}
+		return nil
+	})
+	co.audit(AuditEntry{Action: AuditEdit, Config: name}, err)
+	return name, err
+}
@@ -0,0 +1,120 @@
+package internal
+
+import (
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func initAliasCO(t *testing.T, opts ...Option) (*MemFS, *CO) {
+	t.Helper()
+	fsys, _ := initMemCO(t)
+	for _, name := range []string{"arn-aws-eks-staging", "arn-aws-eks-stable", "sandbox"} {
+		require.NoError(t, fsys.WriteFile(path.Join("/home/.kube/co", name), nil, onlyOwnerAccess))
+	}
+	co, err := NewCO("/home", append([]Option{WithFS(fsys)}, opts...)...)
+	require.NoError(t, err)
+	return fsys, co
+}
+
+func TestResolveName(t *testing.T) {
+	_, co := initAliasCO(t, WithAliases(map[string]string{"sb": "sandbox"}), WithPrefixMatch(true))
+	co.ConfigName = "arn-aws-eks-staging"
+	require.NoError(t, co.AddAlias("stg"))
+
+	tests := map[string]struct {
+		name    string
+		want    string
+		wantErr error
+	}{
+		"Exact name":         {name: "dev", want: "dev"},
+		"Exact beats prefix": {name: "prod", want: "prod"},
+		"Metadata alias":     {name: "stg", want: "arn-aws-eks-staging"},
+		"Configured alias":   {name: "sb", want: "sandbox"},
+		"Unique prefix":      {name: "arn-aws-eks-stab", want: "arn-aws-eks-stable"},
+		"Alias prefix":       {name: "st", want: "arn-aws-eks-staging"},
+		"Ambiguous prefix":   {name: "arn", wantErr: ErrAmbiguous},
+		"Unknown":            {name: "missing", want: "missing"},
+		"Invalid":            {name: "../dev", wantErr: ErrInvalid},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resolved, err := co.ResolveName(test.name)
+			if test.wantErr != nil {
+				require.ErrorIs(t, err, test.wantErr)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, test.want, resolved)
+		})
+	}
+
+	t.Run("Prefixes are disabled by default", func(t *testing.T) {
+		_, co := initAliasCO(t)
+		resolved, err := co.ResolveName("arn-aws-eks-stab")
+		require.NoError(t, err)
+		assert.Equal(t, "arn-aws-eks-stab", resolved)
+	})
+}
+
+func TestAliases(t *testing.T) {
+	_, co := initAliasCO(t, WithAliases(map[string]string{"sb": "sandbox"}), WithPrefixMatch(true))
+	co.ConfigName = "arn-aws-eks-staging"
+	require.NoError(t, co.AddAlias("stg"))
+	require.NoError(t, co.AddAlias("stg"))
+	require.NoError(t, co.AddAlias("staging"))
+
+	aliases, err := co.Aliases()
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"stg": "arn-aws-eks-staging", "staging": "arn-aws-eks-staging", "sb": "sandbox"}, aliases)
+
+	co.ConfigName = "sandbox"
+	require.ErrorIs(t, co.AddAlias("stg"), ErrExists)
+	require.ErrorIs(t, co.AddAlias("dev"), ErrExists)
+	require.ErrorIs(t, co.AddAlias(".hidden"), ErrInvalid)
+
+	name, err := co.RemoveAlias("stg")
+	require.NoError(t, err)
+	assert.Equal(t, "arn-aws-eks-staging", name)
+	md, err := co.Metadata("arn-aws-eks-staging")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"staging"}, md.Aliases)
+
+	_, err = co.RemoveAlias("stg")
+	require.ErrorIs(t, err, ErrNotFound)
+	_, err = co.RemoveAlias("sb")
+	require.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestLinkAndDeleteResolveAliases(t *testing.T) {
+	fsys, co := initAliasCO(t, WithPrefixMatch(true))
+	co.ConfigName = "arn-aws-eks-staging"
+	require.NoError(t, co.AddAlias("stg"))
+
+	co.ConfigName = "stg"
+	require.NoError(t, co.LinkKubeConfig())
+	assert.Equal(t, "arn-aws-eks-staging", co.ConfigName)
+	target, err := fsys.Readlink("/home/.kube/config")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/.kube/co/arn-aws-eks-staging", target)
+
+	co.ConfigName = "arn"
+	require.ErrorIs(t, co.LinkKubeConfig(), ErrAmbiguous)
+
+	co, err = NewCO("/home", WithFS(fsys), WithAliases(map[string]string{"sb": "sandbox"}))
+	require.NoError(t, err)
+	co.ConfigName = "sand"
+	err = co.DeleteConfig()
+	require.ErrorIs(t, err, ErrNotFound, "delete doesn't resolve prefixes")
+	assert.Contains(t, err.Error(), "Did you mean sandbox?")
+	_, err = fsys.Stat("/home/.kube/co/sandbox")
+	require.NoError(t, err)
+
+	co.ConfigName = "sb"
+	require.NoError(t, co.DeleteConfig())
+	_, err = fsys.Stat("/home/.kube/co/sandbox")
+	require.Error(t, err)
+}
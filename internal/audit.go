@@ -0,0 +1,240 @@
+package internal
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/user"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/steffakasid/eslog"
+)
+
+// auditLogName is the file name of the audit log in the state directory. It is hidden so it
+// isn't listed as config if the state directory is the store directory.
+const auditLogName = ".audit.log"
+
+// Defaults of the audit log rotation.
+const (
+	DefaultAuditMaxSize  = 10 << 20
+	DefaultAuditMaxFiles = 5
+)
+
+// Actions recorded in the audit log.
+const (
+	AuditAdd    = "add"
+	AuditLink   = "link"
+	AuditDelete = "delete"
+	AuditRename = "rename"
+	AuditEdit   = "edit"
+	// AuditUpdate records merging a kubeconfig into a stored config.
+	AuditUpdate = "update"
+	// AuditExpire records moving an expired temporary config to the trash.
+	AuditExpire = "expire"
+)
+
+// Outcomes of the recorded actions.
+const (
+	AuditSuccess = "success"
+	AuditFailure = "failure"
+)
+
+// AuditEntry is a single line of the audit log.
+type AuditEntry struct {
+	Time   time.Time `json:"time"`
+	User   string    `json:"user"`
+	Host   string    `json:"host"`
+	TTY    string    `json:"tty,omitempty"`
+	Action string    `json:"action"`
+	// Config is the name of the config the action was applied to.
+	Config string `json:"config,omitempty"`
+	// From and To are the config paths switched from and to by link, the old and new name
+	// of rename and the source file of add.
+	From    string `json:"from,omitempty"`
+	To      string `json:"to,omitempty"`
+	Outcome string `json:"outcome"`
+	Error   string `json:"error,omitempty"`
+}
+
+// AuditFilter selects entries of the audit log. Empty fields match every entry.
+type AuditFilter struct {
+	Since   time.Time
+	Until   time.Time
+	User    string
+	Action  string
+	Config  string
+	Outcome string
+}
+
+// Matches reports whether entry is selected by f.
+func (f AuditFilter) Matches(entry AuditEntry) bool {
+	switch {
+	case !f.Since.IsZero() && entry.Time.Before(f.Since):
+		return false
+	case !f.Until.IsZero() && entry.Time.After(f.Until):
+		return false
+	case f.User != "" && entry.User != f.User:
+		return false
+	case f.Action != "" && entry.Action != f.Action:
+		return false
+	case f.Config != "" && entry.Config != f.Config:
+		return false
+	case f.Outcome != "" && entry.Outcome != f.Outcome:
+		return false
+	}
+	return true
+}
+
+// auditSettings configures the audit log of a CO.
+type auditSettings struct {
+	disabled bool
+	maxSize  int64
+	maxFiles int
+}
+
+// WithAuditLog turns the audit log on or off and sets its rotation: the log is rotated before it
+// grows beyond maxSize bytes and maxFiles rotated logs are kept. Values below one keep the
+// defaults DefaultAuditMaxSize and DefaultAuditMaxFiles.
+func WithAuditLog(enabled bool, maxSize int64, maxFiles int) Option {
+	return func(co *CO) {
+		co.auditLog = auditSettings{disabled: !enabled, maxSize: maxSize, maxFiles: maxFiles}
+	}
+}
+
+// auditIdentity returns the user, host and terminal recorded in the audit log. It is a
+// variable so tests can replace it.
+var auditIdentity = func() (string, string, string) {
+	name := os.Getenv("USER")
+	if u, err := user.Current(); err == nil {
+		name = u.Username
+	}
+	host, _ := os.Hostname()
+	tty := ""
+	// the terminal of stdin, only available on Linux
+	if target, err := os.Readlink("/proc/self/fd/0"); err == nil && strings.HasPrefix(target, "/dev/") && target != "/dev/null" {
+		tty = target
+	}
+	return name, host, tty
+}
+
+// AuditLogPath returns the path of the audit log. Rotated logs have the suffixes .1 (the
+// newest) to .<max files>.
+func (co *CO) AuditLogPath() string {
+	return path.Join(co.StateDir, auditLogName)
+}
+
+// audit appends entry with the outcome of err to the audit log. Failures are only logged as the
+// action itself already happened.
+func (co *CO) audit(entry AuditEntry, err error) {
+	if co.auditLog.disabled || co.StateDir == "" {
+		return
+	}
+	entry.Time = time.Now().UTC()
+	entry.User, entry.Host, entry.TTY = auditIdentity()
+	if entry.Config == "" && entry.To != "" {
+		entry.Config = co.storeName(entry.To)
+	}
+	entry.Outcome = AuditSuccess
+	if err != nil {
+		entry.Outcome = AuditFailure
+		entry.Error = err.Error()
+	}
+	line, err := json.Marshal(entry)
+	if err != nil {
+		eslog.Warnf("Failed to record %s in the audit log: %s", entry.Action, err)
+		return
+	}
+	line = append(line, '\n')
+	if err := co.rotateAuditLog(int64(len(line))); err != nil {
+		eslog.Warnf("Failed to rotate the audit log: %s", err)
+	}
+	if err := co.filesystem().AppendFile(co.AuditLogPath(), line, 0600); err != nil {
+		eslog.Warnf("Failed to record %s in the audit log: %s", entry.Action, err)
+	}
+}
+
+// rotateAuditLog renames the audit log to <log>.1 and the older rotated logs to the next
+// number if writing size bytes would exceed the maximum size. The oldest log is removed.
+func (co *CO) rotateAuditLog(size int64) error {
+	maxSize, maxFiles := co.auditLog.maxSize, co.auditLog.maxFiles
+	if maxSize < 1 {
+		maxSize = DefaultAuditMaxSize
+	}
+	if maxFiles < 1 {
+		maxFiles = DefaultAuditMaxFiles
+	}
+	fsys := co.filesystem()
+	logPath := co.AuditLogPath()
+	fi, err := fsys.Stat(logPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+	if fi.Size() == 0 || fi.Size()+size <= maxSize {
+		return nil
+	}
+
+	if err := fsys.Remove(rotatedAuditLog(logPath, maxFiles)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	for i := maxFiles - 1; i >= 1; i-- {
+		err := fsys.Rename(rotatedAuditLog(logPath, i), rotatedAuditLog(logPath, i+1))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+	}
+	return fsys.Rename(logPath, rotatedAuditLog(logPath, 1))
+}
+
+func rotatedAuditLog(logPath string, i int) string {
+	return fmt.Sprintf("%s.%d", logPath, i)
+}
+
+// AuditLog returns the entries of the audit log and the rotated logs selected by filter, the
+// oldest first. Lines which can't be parsed are skipped.
+func (co *CO) AuditLog(filter AuditFilter) ([]AuditEntry, error) {
+	fsys := co.filesystem()
+	logPath := co.AuditLogPath()
+	files := []string{}
+	for i := 1; ; i++ {
+		rotated := rotatedAuditLog(logPath, i)
+		if _, err := fsys.Stat(rotated); err != nil {
+			break
+		}
+		files = append([]string{rotated}, files...)
+	}
+	files = append(files, logPath)
+
+	entries := []AuditEntry{}
+	for _, file := range files {
+		data, err := fsys.ReadFile(file)
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read audit log %s: %w", file, err)
+		}
+		scanner := bufio.NewScanner(bytes.NewReader(data))
+		scanner.Buffer(nil, 1<<20)
+		for line := 1; scanner.Scan(); line++ {
+			var entry AuditEntry
+			if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+				eslog.Debugf("Skipping line %d of %s: %s", line, file, err)
+				continue
+			}
+			if filter.Matches(entry) {
+				entries = append(entries, entry)
+			}
+		}
+		if err := scanner.Err(); err != nil {
+			return nil, fmt.Errorf("failed to read audit log %s: %w", file, err)
+		}
+	}
+	return entries, nil
+}
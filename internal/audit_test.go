@@ -0,0 +1,131 @@
+package internal
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func setAuditIdentity(t *testing.T, user string) {
+	t.Helper()
+	old := auditIdentity
+	auditIdentity = func() (string, string, string) { return user, "host", "/dev/pts/1" }
+	t.Cleanup(func() { auditIdentity = old })
+}
+
+func TestAuditLog(t *testing.T) {
+	t.Run("Mutating methods", func(t *testing.T) {
+		setAuditIdentity(t, "alice")
+		fsys, _ := initMemCO(t)
+		// every command works on a new CO like the CLI does
+		newCO := func(name string) *CO {
+			co, err := NewCO("/home", WithFS(fsys))
+			require.NoError(t, err)
+			co.ConfigName = name
+			return co
+		}
+
+		require.NoError(t, newCO("staging").AddConfig(t.Context(), ""))
+		require.NoError(t, newCO("staging").LinkKubeConfig())
+		require.NoError(t, newCO("staging").UpdateMetadata(func(md *Metadata) error {
+			md.Description = "staging"
+			return nil
+		}))
+		require.NoError(t, newCO("staging").RenameConfig("stage"))
+		require.Error(t, newCO("missing").LinkKubeConfig())
+		co := newCO("stage")
+		require.NoError(t, co.DeleteConfig())
+
+		entries, err := co.AuditLog(AuditFilter{})
+		require.NoError(t, err)
+		got := []string{}
+		for _, entry := range entries {
+			got = append(got, fmt.Sprintf("%s %s %s %s->%s", entry.Action, entry.Config, entry.Outcome, entry.From, entry.To))
+		}
+		assert.Equal(t, []string{
+			"add staging success ->",
+			"link staging success /home/.kube/co/dev->/home/.kube/co/staging",
+			"edit staging success ->",
+			"rename stage success staging->stage",
+			"link missing failure /home/.kube/co/stage->",
+			"link dev success /home/.kube/co/stage->/home/.kube/co/dev",
+			"delete stage success ->",
+		}, got)
+		assert.Equal(t, "alice", entries[0].User)
+		assert.Equal(t, "host", entries[0].Host)
+		assert.Equal(t, "/dev/pts/1", entries[0].TTY)
+		assert.Contains(t, entries[4].Error, "not found")
+		assert.False(t, entries[0].Time.IsZero())
+	})
+
+	t.Run("Filter", func(t *testing.T) {
+		_, co := initMemCO(t)
+		setAuditIdentity(t, "alice")
+		co.ConfigName = "prod"
+		require.NoError(t, co.LinkKubeConfig())
+		setAuditIdentity(t, "bob")
+		co.ConfigName = "dev"
+		require.NoError(t, co.LinkKubeConfig())
+
+		entries, err := co.AuditLog(AuditFilter{User: "bob"})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "dev", entries[0].Config)
+
+		entries, err = co.AuditLog(AuditFilter{Config: "prod", Action: AuditLink, Outcome: AuditSuccess})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "alice", entries[0].User)
+
+		entries, err = co.AuditLog(AuditFilter{Since: time.Now().Add(time.Hour)})
+		require.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("Rotation", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		setAuditIdentity(t, "alice")
+		co, err := NewCO("/home", WithFS(fsys), WithAuditLog(true, 300, 2))
+		require.NoError(t, err)
+		for i := 0; i < 10; i++ {
+			co.ConfigName = []string{"dev", "prod"}[i%2]
+			require.NoError(t, co.LinkKubeConfig())
+		}
+
+		for _, file := range []string{"/home/.kube/co/.audit.log", "/home/.kube/co/.audit.log.1", "/home/.kube/co/.audit.log.2"} {
+			fi, err := fsys.Stat(file)
+			require.NoError(t, err)
+			assert.LessOrEqual(t, fi.Size(), int64(300))
+		}
+		_, err = fsys.Stat("/home/.kube/co/.audit.log.3")
+		assert.Error(t, err, "only two rotated logs are kept")
+
+		entries, err := co.AuditLog(AuditFilter{})
+		require.NoError(t, err)
+		require.NotEmpty(t, entries)
+		assert.Less(t, len(entries), 10)
+		assert.Equal(t, "prod", entries[len(entries)-1].Config, "the newest entry is last")
+	})
+
+	t.Run("Disabled", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		co, err := NewCO("/home", WithFS(fsys), WithAuditLog(false, 0, 0))
+		require.NoError(t, err)
+		co.ConfigName = "prod"
+		require.NoError(t, co.LinkKubeConfig())
+		_, err = fsys.Stat(co.AuditLogPath())
+		assert.Error(t, err)
+	})
+
+	t.Run("Invalid lines are skipped", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		require.NoError(t, fsys.WriteFile(co.AuditLogPath(), []byte("garbage\n{\"action\":\"add\",\"config\":\"dev\"}\n"), 0600))
+		entries, err := co.AuditLog(AuditFilter{})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "dev", entries[0].Config)
+	})
+}
@@ -0,0 +1,310 @@
+package internal
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultCheckTimeout limits the time spent on checking a single context.
+	DefaultCheckTimeout = 5 * time.Second
+	// DefaultCheckParallelism is the number of contexts checked at the same time.
+	DefaultCheckParallelism = 4
+)
+
+// CheckOptions configures CheckConfigs.
+type CheckOptions struct {
+	// Timeout limits the time spent on checking a single context. Defaults to DefaultCheckTimeout.
+	Timeout time.Duration
+	// Parallelism is the number of contexts checked at the same time. Defaults to DefaultCheckParallelism.
+	Parallelism int
+}
+
+// CheckResult is the outcome of checking one context of a config.
+type CheckResult struct {
+	Config  string
+	Context string
+	Server  string
+	// TLSVersion is the negotiated TLS version. It is empty for plain HTTP servers or if the
+	// handshake failed.
+	TLSVersion string
+	// ServerVersion is the git version reported by the unauthenticated /version request. It is
+	// empty if the server requires authentication for it.
+	ServerVersion string
+	// VersionStatus is the HTTP status code of the /version request.
+	VersionStatus int
+	// APIStatus is the HTTP status code of the authenticated /api request. It is 0 if the user has
+	// no static credentials and the request was skipped.
+	APIStatus int
+	Duration  time.Duration
+	// Err is set if the context is not healthy.
+	Err error
+}
+
+// Healthy reports whether all checks of the context passed.
+func (r CheckResult) Healthy() bool {
+	return r.Err == nil
+}
+
+// checkJob is a single context to check.
+type checkJob struct {
+	config     string
+	dir        string
+	kubeConfig *KubeConfig
+	context    NamedContext
+	err        error
+}
+
+// CheckConfigs checks every context of the given configs for reachability. The server of each
+// context gets a TLS handshake, an unauthenticated /version request and, if the user has
+// static credentials, an authenticated /api request. The checks run concurrently but the
+// results keep the order of names and contexts.
+func (co *CO) CheckConfigs(ctx context.Context, names []string, opts CheckOptions) ([]CheckResult, error) {
+	if opts.Timeout <= 0 {
+		opts.Timeout = DefaultCheckTimeout
+	}
+	if opts.Parallelism <= 0 {
+		opts.Parallelism = DefaultCheckParallelism
+	}
+
+	store := co.store()
+	jobs := []checkJob{}
+	for _, name := range names {
+		if err := validateName(name); err != nil {
+			return nil, err
+		}
+		data, err := store.Get(name)
+		if err != nil {
+			return nil, err
+		}
+		dir := path.Dir(store.Path(name))
+		kubeConfig, err := ParseKubeConfig(data)
+		if err == nil && len(kubeConfig.Contexts) == 0 {
+			err = fmt.Errorf("%w: no contexts defined", ErrInvalid)
+		}
+		if err != nil {
+			jobs = append(jobs, checkJob{config: name, err: err})
+			continue
+		}
+		for _, context := range kubeConfig.Contexts {
+			jobs = append(jobs, checkJob{config: name, dir: dir, kubeConfig: kubeConfig, context: context})
+		}
+	}
+
+	results := make([]CheckResult, len(jobs))
+	sem := make(chan struct{}, opts.Parallelism)
+	var wg sync.WaitGroup
+	for i, job := range jobs {
+		wg.Go(func() {
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			results[i] = co.checkContext(ctx, job, opts.Timeout)
+		})
+	}
+	wg.Wait()
+	return results, nil
+}
+
+func (co *CO) checkContext(ctx context.Context, job checkJob, timeout time.Duration) (result CheckResult) {
+	result = CheckResult{Config: job.config, Context: job.context.Name, Err: job.err}
+	if result.Err != nil {
+		return result
+	}
+	start := time.Now()
+	defer func() { result.Duration = time.Since(start) }()
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	cluster, ok := job.kubeConfig.Cluster(job.context.Context.Cluster)
+	if !ok {
+		result.Err = fmt.Errorf("%w: cluster %q not found", ErrInvalid, job.context.Context.Cluster)
+		return result
+	}
+	result.Server = cluster.Server
+	user, _ := job.kubeConfig.User(job.context.Context.User)
+
+	server, err := url.Parse(cluster.Server)
+	if err != nil || server.Host == "" || (server.Scheme != "https" && server.Scheme != "http") {
+		result.Err = fmt.Errorf("%w: invalid server %q", ErrInvalid, cluster.Server)
+		return result
+	}
+
+	tlsConfig, err := co.tlsConfig(job.dir, cluster)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	proxy, err := checkProxy(cluster)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+
+	client := checkClient(tlsConfig, proxy)
+	defer client.CloseIdleConnections()
+	status, body, connState, err := get(ctx, client, server, "/version", nil)
+	result.VersionStatus = status
+	if connState != nil {
+		result.TLSVersion = tls.VersionName(connState.Version)
+	}
+	if isTLSError(err) {
+		result.Err = fmt.Errorf("TLS handshake failed: %w", err)
+		return result
+	} else if err != nil {
+		result.Err = fmt.Errorf("version request failed: %w", err)
+		return result
+	}
+	switch status {
+	case http.StatusOK:
+		version := struct {
+			GitVersion string `json:"gitVersion"`
+		}{}
+		if err := json.Unmarshal(body, &version); err != nil {
+			result.Err = fmt.Errorf("failed to parse version: %w", err)
+			return result
+		}
+		result.ServerVersion = version.GitVersion
+	case http.StatusUnauthorized, http.StatusForbidden:
+		// the server is reachable but doesn't allow anonymous requests
+	default:
+		result.Err = fmt.Errorf("version request failed: %s", http.StatusText(status))
+		return result
+	}
+
+	if !user.HasCredentials() {
+		return result
+	}
+	authClient, setAuth, err := co.authClient(job.dir, tlsConfig, proxy, user)
+	if err != nil {
+		result.Err = err
+		return result
+	}
+	defer authClient.CloseIdleConnections()
+	result.APIStatus, _, _, err = get(ctx, authClient, server, "/api", setAuth)
+	if err != nil {
+		result.Err = fmt.Errorf("api request failed: %w", err)
+	} else if result.APIStatus != http.StatusOK {
+		result.Err = fmt.Errorf("api request failed: %s", http.StatusText(result.APIStatus))
+	}
+	return result
+}
+
+// tlsConfig returns the TLS client config for cluster without client certificates.
+func (co *CO) tlsConfig(dir string, cluster Cluster) (*tls.Config, error) {
+	tlsConfig := &tls.Config{
+		ServerName:         cluster.TLSServerName,
+		InsecureSkipVerify: cluster.InsecureSkipTLSVerify,
+	}
+	ca, err := readData(co.filesystem(), dir, cluster.CertificateAuthorityData, cluster.CertificateAuthority)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read certificate authority: %w", err)
+	}
+	if ca != nil {
+		tlsConfig.RootCAs = x509.NewCertPool()
+		if !tlsConfig.RootCAs.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("%w: certificate authority contains no PEM certificate", ErrInvalid)
+		}
+	}
+	return tlsConfig, nil
+}
+
+// authClient returns a client authenticating as user and a function adding the credentials
+// to a request.
+func (co *CO) authClient(dir string, tlsConfig *tls.Config, proxy proxyFunc, user AuthInfo) (*http.Client, func(*http.Request), error) {
+	fsys := co.filesystem()
+	tlsConfig = tlsConfig.Clone()
+	cert, err := readData(fsys, dir, user.ClientCertificateData, user.ClientCertificate)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read client certificate: %w", err)
+	}
+	key, err := readData(fsys, dir, user.ClientKeyData, user.ClientKey)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read client key: %w", err)
+	}
+	if cert != nil && key != nil {
+		pair, err := tls.X509KeyPair(cert, key)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%w: invalid client certificate: %w", ErrInvalid, err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{pair}
+	}
+
+	token := user.Token
+	if token == "" && user.TokenFile != "" {
+		content, err := readData(fsys, dir, "", user.TokenFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read token: %w", err)
+		}
+		token = strings.TrimSpace(string(content))
+	}
+	setAuth := func(req *http.Request) {
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		} else if user.Username != "" {
+			req.SetBasicAuth(user.Username, user.Password)
+		}
+	}
+	return checkClient(tlsConfig, proxy), setAuth, nil
+}
+
+// proxyFunc returns the proxy to use for a request, see http.Transport.Proxy.
+type proxyFunc func(*http.Request) (*url.URL, error)
+
+// checkProxy returns the proxy-url of cluster or, like kubectl without one, the proxy configured
+// by HTTPS_PROXY, HTTP_PROXY and NO_PROXY.
+func checkProxy(cluster Cluster) (proxyFunc, error) {
+	if cluster.ProxyURL == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyURL, err := url.Parse(cluster.ProxyURL)
+	if err != nil || proxyURL.Host == "" {
+		return nil, fmt.Errorf("%w: invalid proxy-url %q", ErrInvalid, cluster.ProxyURL)
+	}
+	return http.ProxyURL(proxyURL), nil
+}
+
+// checkClient returns a client using tlsConfig and connecting through proxy. The TLS handshake
+// is part of the requests, so it goes through the proxy as well.
+func checkClient(tlsConfig *tls.Config, proxy proxyFunc) *http.Client {
+	return &http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig, Proxy: proxy}}
+}
+
+// isTLSError reports whether err is caused by a failed TLS handshake, e.g. an untrusted
+// certificate or a server not speaking TLS.
+func isTLSError(err error) bool {
+	var certErr *tls.CertificateVerificationError
+	var recordErr tls.RecordHeaderError
+	var alertErr tls.AlertError
+	return errors.As(err, &certErr) || errors.As(err, &recordErr) || errors.As(err, &alertErr)
+}
+
+// get requests apiPath below server and returns the status code, the body and the TLS state of
+// the connection, which is nil for plain HTTP.
+func get(ctx context.Context, client *http.Client, server *url.URL, apiPath string, setAuth func(*http.Request)) (int, []byte, *tls.ConnectionState, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(server.String(), "/")+apiPath, nil)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	if setAuth != nil {
+		setAuth(req)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, nil, nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	return resp.StatusCode, body, resp.TLS, err
+}
@@ -0,0 +1,247 @@
+package internal
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testToken = "secret"
+
+// newTestAPIServer starts a TLS server answering /version anonymously and /api for testToken.
+func newTestAPIServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/version", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, `{"gitVersion":"v1.31.0"}`)
+	})
+	mux.HandleFunc("/api", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer "+testToken {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprint(w, `{"kind":"APIVersions","versions":["v1"]}`)
+	})
+	server := httptest.NewTLSServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func caData(server *httptest.Server) string {
+	ca := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	return base64.StdEncoding.EncodeToString(ca)
+}
+
+func testKubeConfig(server, ca, token string) []byte {
+	return fmt.Appendf(nil, `apiVersion: v1
+kind: Config
+current-context: test
+clusters:
+- name: test
+  cluster:
+    server: %s
+    certificate-authority-data: %s
+users:
+- name: test
+  user:
+    token: %q
+contexts:
+- name: test
+  context:
+    cluster: test
+    user: test
+`, server, ca, token)
+}
+
+func TestCheckConfigs(t *testing.T) {
+	server := newTestAPIServer(t)
+	fsys, co := initMemCO(t)
+	coHome := "/home/.kube/co"
+	put := func(name string, data []byte) {
+		require.NoError(t, fsys.WriteFile(path.Join(coHome, name), data, onlyOwnerAccess))
+	}
+	put("dev", testKubeConfig(server.URL, caData(server), testToken))
+	put("prod", testKubeConfig(server.URL, caData(server), ""))
+	put("badtoken", testKubeConfig(server.URL, caData(server), "wrong"))
+	put("untrusted", testKubeConfig(server.URL, "", testToken))
+	put("broken", []byte("clusters: ["))
+
+	closed := httptest.NewTLSServer(http.NotFoundHandler())
+	closed.Close()
+	put("down", testKubeConfig(closed.URL, caData(server), testToken))
+
+	t.Run("Healthy", func(t *testing.T) {
+		results, err := co.CheckConfigs(context.Background(), []string{"dev"}, CheckOptions{})
+		require.NoError(t, err)
+		require.Len(t, results, 1)
+		result := results[0]
+		require.NoError(t, result.Err)
+		assert.True(t, result.Healthy())
+		assert.Equal(t, "dev", result.Config)
+		assert.Equal(t, "test", result.Context)
+		assert.Equal(t, server.URL, result.Server)
+		assert.NotEmpty(t, result.TLSVersion)
+		assert.Equal(t, "v1.31.0", result.ServerVersion)
+		assert.Equal(t, http.StatusOK, result.VersionStatus)
+		assert.Equal(t, http.StatusOK, result.APIStatus)
+		assert.Positive(t, result.Duration)
+	})
+
+	t.Run("Without credentials", func(t *testing.T) {
+		results, err := co.CheckConfigs(context.Background(), []string{"prod"}, CheckOptions{})
+		require.NoError(t, err)
+		require.NoError(t, results[0].Err)
+		assert.Equal(t, 0, results[0].APIStatus)
+	})
+
+	t.Run("Unhealthy", func(t *testing.T) {
+		names := []string{"badtoken", "untrusted", "down", "broken"}
+		results, err := co.CheckConfigs(context.Background(), names, CheckOptions{Timeout: time.Second})
+		require.NoError(t, err)
+		require.Len(t, results, len(names))
+		for i, name := range names {
+			assert.Equal(t, name, results[i].Config)
+			assert.False(t, results[i].Healthy(), name)
+		}
+		assert.Equal(t, http.StatusUnauthorized, results[0].APIStatus)
+		assert.ErrorContains(t, results[1].Err, "TLS handshake failed")
+		assert.ErrorContains(t, results[2].Err, "version request failed", "an unreachable server fails the first request")
+		assert.ErrorIs(t, results[3].Err, ErrInvalid)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, err := co.CheckConfigs(context.Background(), []string{"missing"}, CheckOptions{})
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Invalid name", func(t *testing.T) {
+		_, err := co.CheckConfigs(context.Background(), []string{"../dev"}, CheckOptions{})
+		require.ErrorIs(t, err, ErrInvalid)
+	})
+}
+
+func TestCheckConfigsTimeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	t.Cleanup(server.Close)
+	t.Cleanup(func() { close(release) })
+
+	fsys, co := initMemCO(t)
+	require.NoError(t, fsys.WriteFile("/home/.kube/co/dev", testKubeConfig(server.URL, caData(server), testToken), onlyOwnerAccess))
+
+	results, err := co.CheckConfigs(context.Background(), []string{"dev"}, CheckOptions{Timeout: 100 * time.Millisecond})
+	require.NoError(t, err)
+	require.ErrorIs(t, results[0].Err, context.DeadlineExceeded)
+}
+
+func TestCheckConfigsParallelism(t *testing.T) {
+	var running, maxRunning atomic.Int32
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := running.Add(1)
+		defer running.Add(-1)
+		for {
+			max := maxRunning.Load()
+			if n <= max || maxRunning.CompareAndSwap(max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprint(w, `{"gitVersion":"v1.31.0"}`)
+	}))
+	t.Cleanup(server.Close)
+
+	fsys, co := initMemCO(t)
+	names := []string{}
+	for i := range 6 {
+		name := fmt.Sprintf("cluster%d", i)
+		names = append(names, name)
+		require.NoError(t, fsys.WriteFile(path.Join("/home/.kube/co", name), testKubeConfig(server.URL, caData(server), ""), onlyOwnerAccess))
+	}
+
+	results, err := co.CheckConfigs(context.Background(), names, CheckOptions{Parallelism: 2})
+	require.NoError(t, err)
+	for i, result := range results {
+		require.NoError(t, result.Err)
+		assert.Equal(t, names[i], result.Config)
+	}
+	assert.LessOrEqual(t, maxRunning.Load(), int32(2))
+}
+
+// newConnectProxy starts an HTTP proxy tunneling every CONNECT request to target and records
+// the requested hosts.
+func newConnectProxy(t *testing.T, target string) (*httptest.Server, *[]string) {
+	t.Helper()
+	var mu sync.Mutex
+	hosts := []string{}
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodConnect {
+			http.Error(w, "only CONNECT is supported", http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		hosts = append(hosts, r.Host)
+		mu.Unlock()
+		upstream, err := net.Dial("tcp", target)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		conn, _, err := w.(http.Hijacker).Hijack()
+		if err != nil {
+			upstream.Close()
+			return
+		}
+		fmt.Fprint(conn, "HTTP/1.1 200 Connection established\r\n\r\n")
+		go func() {
+			defer upstream.Close()
+			_, _ = io.Copy(upstream, conn)
+		}()
+		go func() {
+			defer conn.Close()
+			_, _ = io.Copy(conn, upstream)
+		}()
+	}))
+	t.Cleanup(proxy.Close)
+	return proxy, &hosts
+}
+
+func TestCheckConfigsProxy(t *testing.T) {
+	server := newTestAPIServer(t)
+	proxy, hosts := newConnectProxy(t, server.Listener.Addr().String())
+	fsys, co := initMemCO(t)
+	// the name doesn't resolve, the server is only reachable through the proxy
+	unresolvable := "https://kube-api.invalid"
+	put := func(name, proxyURL string) {
+		data := strings.Replace(string(testKubeConfig(server.URL, caData(server), testToken)),
+			"    server: "+server.URL+"\n",
+			"    server: "+unresolvable+"\n    tls-server-name: example.com\n    proxy-url: "+proxyURL+"\n", 1)
+		require.NoError(t, fsys.WriteFile("/home/.kube/co/"+name, []byte(data), onlyOwnerAccess))
+	}
+	put("proxied", proxy.URL)
+	put("invalid", "://proxy")
+
+	results, err := co.CheckConfigs(context.Background(), []string{"proxied", "invalid"}, CheckOptions{})
+	require.NoError(t, err)
+	require.NoError(t, results[0].Err)
+	assert.NotEmpty(t, results[0].TLSVersion, "the TLS handshake goes through the proxy")
+	assert.Equal(t, "v1.31.0", results[0].ServerVersion)
+	assert.Equal(t, http.StatusOK, results[0].APIStatus)
+	assert.Contains(t, *hosts, "kube-api.invalid:443")
+	assert.ErrorIs(t, results[1].Err, ErrInvalid)
+}
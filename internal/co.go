@@ -1,10 +1,15 @@
 package internal
 
 import (
+	"context"
 	"errors"
 	"fmt"
+	"io"
 	"io/fs"
-	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"time"
 
 	"github.com/steffakasid/eslog"
 )
@@ -16,43 +21,170 @@ const (
 
 func init() {}
 
+// Errors returned by CO and the stores. Use errors.Is to check for them and ExitCode to map
+// them to the process exit code.
+var (
+	// ErrNotFound is returned when the requested config does not exist.
+	ErrNotFound = errors.New("config not found")
+	// ErrNoPrevious is returned when switching back without a previous config.
+	ErrNoPrevious = errors.New("no previous config")
+	// ErrExists is returned when a config with the same name already exists.
+	ErrExists = errors.New("config already exists")
+	// ErrInvalid is returned when an input like a config name or a setting is not valid.
+	ErrInvalid = errors.New("invalid input")
+	// ErrUsage is returned when the command line is used wrongly.
+	ErrUsage = errors.New("wrong usage")
+	// ErrProtected is returned when switching to or deleting a protected config wasn't confirmed.
+	ErrProtected = errors.New("config is protected")
+	// ErrAmbiguous is returned when a name prefix matches more than one config.
+	ErrAmbiguous = errors.New("ambiguous config name")
+	// ErrVetoed is returned when a pre-switch hook failed and the switch was aborted.
+	ErrVetoed = errors.New("switch vetoed by hook")
+	// ErrReserved is returned when a new config or alias is named like a command, see
+	// WithReservedNames. It wraps ErrInvalid.
+	ErrReserved = fmt.Errorf("%w: name is reserved for a command", ErrInvalid)
+)
+
 type CO struct {
-	ConfigName         string
-	CObasePath         string
-	KubeConfigPath     string
+	ConfigName     string
+	CObasePath     string
+	KubeConfigPath string
+	// StateDir holds the previous link. It defaults to CObasePath.
+	StateDir           string
 	PreviousConifgPath string
 	PreviousConfigLink string
 	CurrentConfigPath  string
 	Configs            []string
+	// KubeConfigEnv holds the files listed in the KUBECONFIG environment variable. kubectl
+	// ignores the kube config link if it is set and doesn't list it.
+	KubeConfigEnv []string
+	// Expiring holds the credentials of the config linked by LinkKubeConfig which expire
+	// within the days set by WithWarnDays.
+	Expiring []Expiry
+
+	backend               string
+	fs                    FS
+	warnDays              int
+	protectedEnvironments []string
+	confirm               func(name string) bool
+	aliases               map[string]string
+	prefixMatch           bool
+	hooks                 Hooks
+	effectiveEnv          *effectiveEnv
+	relativeLinks         bool
+	auditLog              auditSettings
+	stdin                 io.Reader
+	fetchTimeout          time.Duration
+	reservedNames         []string
 }
 
 const onlyOwnerAccess = 0700
 
-func NewCO(home string) (*CO, error) {
+// Option configures optional behaviour of a CO instance.
+type Option func(*CO)
+
+// WithBackend selects the store backend (see StoreBackendDir and StoreBackendGit).
+func WithBackend(backend string) Option {
+	return func(co *CO) {
+		co.backend = backend
+	}
+}
+
+// WithFS lets CO operate on the given filesystem instead of the disk.
+func WithFS(fsys FS) Option {
+	return func(co *CO) {
+		co.fs = fsys
+	}
+}
+
+// WithWarnDays makes LinkKubeConfig warn about credentials of the linked config which expire
+// within the given number of days. Zero disables the warning.
+func WithWarnDays(days int) Option {
+	return func(co *CO) {
+		co.warnDays = days
+	}
+}
+
+// WithStoreDir keeps the configs in dir instead of ~/.kube/co. An empty dir keeps the default.
+func WithStoreDir(dir string) Option {
+	return func(co *CO) {
+		if dir != "" {
+			co.CObasePath = path.Clean(dir)
+		}
+	}
+}
+
+// WithKubeConfigPath links kubeConfigPath instead of ~/.kube/config to the selected config. An
+// empty path keeps the default.
+func WithKubeConfigPath(kubeConfigPath string) Option {
+	return func(co *CO) {
+		if kubeConfigPath != "" {
+			co.KubeConfigPath = path.Clean(kubeConfigPath)
+		}
+	}
+}
+
+// WithStateDir keeps the previous link in dir instead of the store directory. An empty dir
+// keeps the default.
+func WithStateDir(dir string) Option {
+	return func(co *CO) {
+		if dir != "" {
+			co.StateDir = path.Clean(dir)
+		}
+	}
+}
+
+// WithRelativeLinks makes the kube config and previous links point to their targets relative to
+// the directory of the link, so they survive moving the home directory.
+func WithRelativeLinks(relative bool) Option {
+	return func(co *CO) {
+		co.relativeLinks = relative
+	}
+}
+
+// WithReservedNames refuses names as names of new configs and aliases, e.g. the commands of a
+// command line which would shadow configs named like them.
+func WithReservedNames(names ...string) Option {
+	return func(co *CO) {
+		co.reservedNames = names
+	}
+}
+
+// NewCO returns a CO for the store, kube config and state locations below home or set by opts.
+// The directories are created if they don't exist and the kube config and previous links are read.
+func NewCO(home string, opts ...Option) (*CO, error) {
 	var err error
-	var co = &CO{}
+	co := newCO(home, opts...)
+	if _, err := NewStore(co.backend, co.fs, ""); err != nil {
+		return nil, err
+	}
 
-	kubeHome := fmt.Sprintf("%s/%s", home, dotKube)
-	if err := initKubeHome(kubeHome); err != nil {
+	if err := initKubeHome(co.fs, path.Dir(co.KubeConfigPath)); err != nil {
 		return nil, fmt.Errorf("failed to initialize kube home: %w", err)
 	}
 
-	co.CObasePath = fmt.Sprintf("%s/%s", kubeHome, COfolderName)
-	co.KubeConfigPath = fmt.Sprintf("%s/%s/config", home, dotKube)
-	co.PreviousConfigLink = fmt.Sprintf("%s/previous", co.CObasePath)
-
+	// the parent of a store directory outside the kube home may not exist yet
+	if err := co.fs.MkdirAll(path.Dir(co.CObasePath), onlyOwnerAccess); err != nil {
+		return nil, fmt.Errorf("failed to initialize CO home: %w", err)
+	}
 	if err := co.initCOHome(); err != nil {
 		return nil, fmt.Errorf("failed to initialize CO home: %w", err)
 	}
 
-	co.PreviousConifgPath, err = os.Readlink(co.PreviousConfigLink)
+	if co.StateDir != co.CObasePath {
+		if err := co.fs.MkdirAll(co.StateDir, onlyOwnerAccess); err != nil {
+			return nil, fmt.Errorf("failed to create state directory: %w", err)
+		}
+	}
+
+	co.PreviousConifgPath, err = co.linkTarget(co.PreviousConfigLink)
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return nil, fmt.Errorf("failed to read previous config link: %w", err)
 	}
 
-	fi, err := os.Lstat(co.KubeConfigPath)
-	if err == nil && fi.Mode()&os.ModeSymlink != 0 {
-		co.CurrentConfigPath, err = os.Readlink(co.KubeConfigPath)
+	fi, err := co.fs.Lstat(co.KubeConfigPath)
+	if err == nil && fi.Mode()&fs.ModeSymlink != 0 {
+		co.CurrentConfigPath, err = co.linkTarget(co.KubeConfigPath)
 		if err != nil && !errors.Is(err, fs.ErrNotExist) {
 			return nil, fmt.Errorf("failed to read current config path: %w", err)
 		}
@@ -61,12 +193,55 @@ func NewCO(home string) (*CO, error) {
 	return co, nil
 }
 
+// newCO applies opts to a CO with the default settings and the paths below home. It doesn't
+// touch the filesystem.
+func newCO(home string, opts ...Option) *CO {
+	co := &CO{
+		fs:                    OSFS{},
+		protectedEnvironments: DefaultProtectedEnvironments,
+		KubeConfigEnv:         kubeConfigEnvDefault(),
+	}
+	co.CObasePath = DefaultStoreDir(home)
+	co.KubeConfigPath = fmt.Sprintf("%s/%s/config", home, dotKube)
+	for _, opt := range opts {
+		opt(co)
+	}
+	if co.StateDir == "" {
+		co.StateDir = co.CObasePath
+	}
+	co.PreviousConfigLink = path.Join(co.StateDir, previousLinkName)
+	return co
+}
+
+// DefaultStoreDir returns the store directory below home used without WithStoreDir.
+func DefaultStoreDir(home string) string {
+	return fmt.Sprintf("%s/%s/%s", home, dotKube, COfolderName)
+}
+
+// filesystem returns the FS of co. Instances not created by NewCO use the OS filesystem.
+func (co *CO) filesystem() FS {
+	if co.fs == nil {
+		return OSFS{}
+	}
+	return co.fs
+}
+
+// store returns the configured Store rooted at the current CO base path.
+func (co *CO) store() Store {
+	store, err := NewStore(co.backend, co.filesystem(), co.CObasePath)
+	if err != nil {
+		// the backend is validated in NewCO, fall back to the default for hand-built instances
+		return &dirStore{fs: co.filesystem(), basePath: co.CObasePath}
+	}
+	return store
+}
+
 // initKubeHome creates the kube home directory if it does not exist.
 // The directory is created with permissions that only allow owner access (0700).
 // Returns an error if the directory creation fails.
-func initKubeHome(kubeHome string) error {
-	if _, err := os.Stat(kubeHome); errors.Is(err, fs.ErrNotExist) {
-		err := os.Mkdir(kubeHome, onlyOwnerAccess)
+func initKubeHome(fsys FS, kubeHome string) error {
+	if _, err := fsys.Stat(kubeHome); errors.Is(err, fs.ErrNotExist) {
+		err := fsys.Mkdir(kubeHome, onlyOwnerAccess)
 		if err != nil {
 			return fmt.Errorf("failed to create kube home directory: %w", err)
 		}
@@ -78,8 +253,8 @@ func initKubeHome(kubeHome string) error {
 // It returns an error if the directory creation fails or if an unexpected error occurs
 // when checking for the directory's existence.
 func (co *CO) initCOHome() error {
-	if _, err := os.Stat(co.CObasePath); errors.Is(err, fs.ErrNotExist) {
-		err := os.Mkdir(co.CObasePath, onlyOwnerAccess)
+	if _, err := co.filesystem().Stat(co.CObasePath); errors.Is(err, fs.ErrNotExist) {
+		err := co.filesystem().Mkdir(co.CObasePath, onlyOwnerAccess)
 		if err != nil {
 			return fmt.Errorf("failed to create CO home directory: %w", err)
 		}
@@ -92,33 +267,63 @@ func (co *CO) initCOHome() error {
 // AddConfig creates or copies a kubeconfig file to the CO base path.
 // If newConfigPath is empty, it creates a new empty config file with owner-only access permissions.
 // If newConfigPath is provided, it reads the config from that path and writes it to the CO base path.
-// The created or copied config file will be named according to co.ConfigName.
-// Returns an error if file operations fail.
-func (co *CO) AddConfig(newConfigPath string) error {
-	configToWrite := fmt.Sprintf("%s/%s", co.CObasePath, co.ConfigName)
+// newConfigPath can also be StdinSource to read the config from standard input (see WithStdin)
+// or an http(s) or file URL to fetch it from (see WithFetchTimeout). Configs read from standard
+// input or a URL must be kubeconfigs (ErrInvalid).
+// The created or copied config file will be named according to co.ConfigName and is written
+// through the configured Store. The creation time is recorded in the metadata.
+// The addition is recorded in the audit log. Returns ErrExists if a config named co.ConfigName
+// already exists, use UpdateConfig to change it, ErrReserved if the name is reserved (see
+// WithReservedNames) and an error if file operations fail.
+func (co *CO) AddConfig(ctx context.Context, newConfigPath string) error {
+	err := co.addConfig(ctx, newConfigPath)
+	co.audit(AuditEntry{Action: AuditAdd, Config: co.ConfigName, From: sourceName(newConfigPath)}, err)
+	return err
+}
+
+func (co *CO) addConfig(ctx context.Context, newConfigPath string) error {
+	if err := co.validateNewName(co.ConfigName); err != nil {
+		return err
+	}
+	store := co.store()
+	configToWrite := store.Path(co.ConfigName)
+	if _, err := co.filesystem().Lstat(configToWrite); err == nil {
+		return fmt.Errorf("%w: %s", ErrExists, co.ConfigName)
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to check config file %s: %w", configToWrite, err)
+	}
 
 	if newConfigPath == "" {
-		_, err := os.Create(configToWrite)
+		err := store.Put(co.ConfigName, nil)
 		if err != nil {
 			return fmt.Errorf("failed to create new config file: %w", err)
 		}
-		err = os.Chmod(configToWrite, onlyOwnerAccess)
-		if err != nil {
-			return fmt.Errorf("failed to set permissions on new config file: %w", err)
-		}
 		eslog.Infof("Created new config file %s. You may need to initalize it.", configToWrite)
 	} else {
-		input, err := os.ReadFile(newConfigPath)
+		input, err := co.readSource(ctx, newConfigPath)
 		if err != nil {
-			return fmt.Errorf("failed to read input config file: %w", err)
+			return err
 		}
 
-		err = os.WriteFile(configToWrite, input, onlyOwnerAccess)
+		err = store.Put(co.ConfigName, input)
 		if err != nil {
 			return fmt.Errorf("failed to write config file: %w", err)
 		}
 		eslog.Infof("Added %s", configToWrite)
 	}
+	co.touch(configToWrite, true)
+	return nil
+}
+
+// validateNewName checks the name of a new config or alias. Besides the checks of validateName
+// it refuses the names set by WithReservedNames.
+func (co *CO) validateNewName(name string) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	if slices.Contains(co.reservedNames, name) {
+		return fmt.Errorf("%w: %s", ErrReserved, name)
+	}
 	return nil
 }
 
@@ -129,39 +334,93 @@ func (co *CO) AddConfig(newConfigPath string) error {
 //
 // If the configuration file to use doesn't exist, the function returns nil without error.
 // Otherwise, it performs the following steps:
-//  1. Cleans up any previous Kubernetes configuration
-//  2. Links the selected configuration file
-//  3. Creates a link to the previous configuration for rollback purposes
+//  1. Asks for confirmation if the selected configuration is protected (see WithConfirm)
+//  2. Runs the pre-switch hooks (see WithHooks)
+//  3. Cleans up any previous Kubernetes configuration
+//  4. Links the selected configuration file
+//  5. Creates a link to the previous configuration for rollback purposes
+//  6. Records the time of use in the metadata of the selected configuration
+//  7. Warns about credentials of the selected configuration which expire soon (see WithWarnDays),
+//     about a temporary configuration which expires soon (see SetExpiry) and about a KUBECONFIG
+//     environment variable making kubectl ignore the link
+//  8. Runs the post-switch hooks
+//  9. Records the switch and its outcome in the audit log
 //
 // Returns an error if:
 //   - Neither ConfigName nor PreviousConifgPath is set
+//   - The selected configuration is protected and switching wasn't confirmed (ErrProtected)
+//   - A pre-switch hook failed (ErrVetoed)
 //   - Cleanup of previous configuration fails
 //   - Linking the configuration fails
 //   - Linking the previous configuration fails
 func (co *CO) LinkKubeConfig() error {
-	var configToUse string
+	from := co.CurrentConfigPath
+	configToUse, err := co.linkKubeConfig()
+	co.audit(AuditEntry{Action: AuditLink, Config: co.ConfigName, From: from, To: configToUse}, err)
+	return err
+}
 
-	if co.ConfigName != "" {
-		configToUse = fmt.Sprintf("%s/%s", co.CObasePath, co.ConfigName)
-	} else if co.PreviousConifgPath != "" {
-		configToUse = co.PreviousConifgPath
-	} else {
-		return errors.New("don't know what to do. Need a configname to configure")
+// linkKubeConfig does the work of LinkKubeConfig and returns the config linked, which is empty
+// if it couldn't be determined.
+func (co *CO) linkKubeConfig() (string, error) {
+	configToUse, err := co.configToUse()
+	if err != nil {
+		return "", err
+	}
+
+	if err := co.confirmProtected(configToUse); err != nil {
+		return configToUse, err
 	}
 
-	if _, err := os.Stat(configToUse); errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("config '%s' does not exist", co.ConfigName)
+	from := co.CurrentConfigPath
+	if err := co.runHooks(HookPreSwitch, from, configToUse); err != nil {
+		return configToUse, err
 	}
 
 	if err := co.cleanup(); err != nil {
-		return fmt.Errorf("failed to cleanup previous kube config: %w", err)
+		return configToUse, fmt.Errorf("failed to cleanup previous kube config: %w", err)
 	}
 
 	if err := co.linkConfigToUse(configToUse); err != nil {
-		return fmt.Errorf("failed to link kube config: %w", err)
+		return configToUse, fmt.Errorf("failed to link kube config: %w", err)
+	}
+
+	if err := co.linkPreviousConfig(); err != nil {
+		return configToUse, err
+	}
+	co.touch(configToUse, false)
+	co.warnExpiring(configToUse)
+	co.warnTemporary(configToUse)
+	co.warnKubeConfigIgnored()
+	return configToUse, co.runHooks(HookPostSwitch, from, configToUse)
+}
+
+// configToUse returns the path of co.ConfigName or, if it is empty, of the previous config. The
+// resolved name is stored in co.ConfigName. It returns ErrNoPrevious if neither is set and
+// ErrNotFound if the config doesn't exist.
+func (co *CO) configToUse() (string, error) {
+	var configToUse string
+
+	if co.ConfigName != "" {
+		name, err := co.ResolveName(co.ConfigName)
+		if err != nil {
+			return "", err
+		}
+		co.ConfigName = name
+		configToUse = co.store().Path(co.ConfigName)
+	} else if co.PreviousConifgPath != "" {
+		configToUse = co.PreviousConifgPath
+	} else {
+		return "", fmt.Errorf("%w: don't know what to do. Need a configname to configure", ErrNoPrevious)
 	}
 
-	return co.linkPreviousConfig()
+	if _, err := co.filesystem().Stat(configToUse); errors.Is(err, fs.ErrNotExist) {
+		if co.ConfigName != "" {
+			return "", co.notFound(co.ConfigName)
+		}
+		return "", fmt.Errorf("config '%s' does not exist: %w", configToUse, ErrNotFound)
+	}
+	return configToUse, nil
 }
 
 // linkConfigToUse creates a symbolic link from co.KubeConfigPath to the specified configToUse file.
@@ -169,16 +428,16 @@ func (co *CO) LinkKubeConfig() error {
 // onlyOwnerAccess to avoid kubectl warnings. Returns an error if the config file doesn't exist,
 // if symlink creation fails, or if setting permissions fails.
 func (co *CO) linkConfigToUse(configToUse string) error {
-	if _, err := os.Stat(configToUse); errors.Is(err, fs.ErrNotExist) {
-		return fmt.Errorf("config file %s does not exist", configToUse)
+	if _, err := co.filesystem().Stat(configToUse); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("config file %s does not exist: %w", configToUse, ErrNotFound)
 	}
 
-	if err := os.Symlink(configToUse, co.KubeConfigPath); err != nil {
+	if err := co.symlink(configToUse, co.KubeConfigPath); err != nil {
 		return fmt.Errorf("failed to create symlink: %w", err)
 	}
-	fmt.Printf("Linked %s to %s\n", co.KubeConfigPath, configToUse)
+	eslog.Debugf("Linked %s to %s", co.KubeConfigPath, configToUse)
 	// chmod on symlink to avoid kubectl warnings.
-	if err := os.Chmod(co.KubeConfigPath, onlyOwnerAccess); err != nil {
+	if err := co.filesystem().Chmod(co.KubeConfigPath, onlyOwnerAccess); err != nil {
 		return fmt.Errorf("failed to set permissions on kube config symlink: %w", err)
 	}
 	return nil
@@ -189,7 +448,7 @@ func (co *CO) linkConfigToUse(configToUse string) error {
 // Returns an error if the symlink creation fails.
 func (co *CO) linkPreviousConfig() error {
 	if co.CurrentConfigPath != "" {
-		if err := os.Symlink(co.CurrentConfigPath, co.PreviousConfigLink); err != nil {
+		if err := co.symlink(co.CurrentConfigPath, co.PreviousConfigLink); err != nil {
 			return fmt.Errorf("failed to create symlink for previous config: %w", err)
 		}
 		eslog.Debugf("Linked %s to %s", co.PreviousConfigLink, co.CurrentConfigPath)
@@ -200,12 +459,12 @@ func (co *CO) linkPreviousConfig() error {
 // cleanup removes the kube config symlink and previous config symlink.
 // It returns an error if either removal fails, unless the file does not exist.
 func (co *CO) cleanup() error {
-	err := os.Remove(co.KubeConfigPath)
+	err := co.filesystem().Remove(co.KubeConfigPath)
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return fmt.Errorf("failed to remove kube config symlink: %w", err)
 	}
 
-	err = os.Remove(co.PreviousConfigLink)
+	err = co.filesystem().Remove(co.PreviousConfigLink)
 	if err != nil && !errors.Is(err, fs.ErrNotExist) {
 		return fmt.Errorf("failed to remove previous config symlink: %w", err)
 	}
@@ -213,43 +472,151 @@ func (co *CO) cleanup() error {
 }
 
 // DeleteConfig removes the configuration file associated with the CO instance.
-// It first verifies that the config file exists, then clears the ConfigName,
-// relinks the kubeconfig to remove the deleted config, and finally deletes the file.
-// Returns an error if the config file does not exist, if relinking the kubeconfig fails,
-// or if the file deletion fails.
+// co.ConfigName must be the name or an alias of the config, prefixes aren't resolved (see
+// ResolveAlias). It first verifies that the config file exists and asks for confirmation if it
+// is protected,
+// then clears the ConfigName, relinks the kubeconfig to remove the deleted config, and finally
+// deletes the file.
+// Returns an error if the config file does not exist, if deleting wasn't confirmed, if relinking
+// the kubeconfig fails, or if the file deletion fails. The deletion is recorded in the audit log.
 func (co *CO) DeleteConfig() error {
-	configToUse := fmt.Sprintf("%s/%s", co.CObasePath, co.ConfigName)
-	if _, err := os.Stat(configToUse); err != nil {
-		return fmt.Errorf("config file %s does not exist: %w", configToUse, err)
+	name, err := co.deleteConfig()
+	if name == "" {
+		name = co.ConfigName
+	}
+	co.audit(AuditEntry{Action: AuditDelete, Config: name}, err)
+	return err
+}
+
+// deleteConfig does the work of DeleteConfig and returns the resolved name of the config, which
+// is empty if it couldn't be resolved.
+func (co *CO) deleteConfig() (string, error) {
+	store := co.store()
+	name, err := co.ResolveAlias(co.ConfigName)
+	if err != nil {
+		return "", err
+	}
+	configToUse := store.Path(name)
+	if _, err := co.filesystem().Stat(configToUse); errors.Is(err, fs.ErrNotExist) {
+		return name, co.notFound(name)
+	} else if err != nil {
+		return name, fmt.Errorf("failed to check config file %s: %w", configToUse, err)
+	}
+	if err := co.confirmProtected(configToUse); err != nil {
+		return name, err
 	}
 	co.ConfigName = ""
-	err := co.LinkKubeConfig()
+	err = co.LinkKubeConfig()
 	if err != nil {
-		return fmt.Errorf("failed to link kube config after deletion: %w", err)
+		return name, fmt.Errorf("failed to link kube config after deletion: %w", err)
 	}
 
-	err = os.Remove(configToUse)
+	err = store.Delete(name)
 	if err != nil {
-		return fmt.Errorf("failed to delete config file %s: %w", configToUse, err)
+		return name, fmt.Errorf("failed to delete config %s: %w", name, err)
+	}
+	co.updateUsage(name, "", nil)
+	eslog.Debugf("Deleted %s", configToUse)
+	return name, nil
+}
+
+// RenameConfig renames the config co.ConfigName to newName. The kube config and previous links
+// are pointed to the new path if they referenced the renamed config.
+// Returns ErrExists if a config named newName already exists and ErrReserved if newName is
+// reserved (see WithReservedNames). The rename is recorded in the audit log.
+func (co *CO) RenameConfig(newName string) error {
+	oldName := co.ConfigName
+	err := co.renameConfig(newName)
+	co.audit(AuditEntry{Action: AuditRename, Config: newName, From: oldName, To: newName}, err)
+	return err
+}
+
+func (co *CO) renameConfig(newName string) error {
+	if err := co.validateNewName(newName); err != nil {
+		return err
+	}
+	store := co.store()
+	oldPath := store.Path(co.ConfigName)
+	if err := store.Rename(co.ConfigName, newName); err != nil {
+		return fmt.Errorf("failed to rename config %s: %w", co.ConfigName, err)
+	}
+	co.updateUsage(co.ConfigName, newName, func(*usage) {})
+	newPath := store.Path(newName)
+	co.ConfigName = newName
+
+	if co.CurrentConfigPath == oldPath {
+		if err := co.relink(co.KubeConfigPath, newPath); err != nil {
+			return fmt.Errorf("failed to relink kube config: %w", err)
+		}
+		co.CurrentConfigPath = newPath
+	}
+	if co.PreviousConifgPath == oldPath {
+		if err := co.relink(co.PreviousConfigLink, newPath); err != nil {
+			return fmt.Errorf("failed to relink previous config: %w", err)
+		}
+		co.PreviousConifgPath = newPath
 	}
-	fmt.Println("Deleted", configToUse)
 	return nil
 }
 
-// ListConfigs reads the CO base directory and populates the Configs field with
-// all directory entries except "previous". It returns an error if the directory
-// cannot be read.
-func (co *CO) ListConfigs() error {
-	entries, err := os.ReadDir(co.CObasePath)
+// relink replaces the symlink link with a symlink pointing to target.
+func (co *CO) relink(link, target string) error {
+	if err := co.filesystem().Remove(link); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return co.symlink(target, link)
+}
+
+// symlink creates link pointing to target, relative to the directory of link if co uses
+// relative links.
+func (co *CO) symlink(target, link string) error {
+	return co.filesystem().Symlink(linkPath(link, target, co.relativeLinks), link)
+}
+
+// linkPath returns the target to store in link: target itself or, if relative is set, target
+// relative to the directory of link. Relative targets are returned as they are.
+func linkPath(link, target string, relative bool) string {
+	if !relative || !path.IsAbs(target) {
+		return target
+	}
+	rel, err := filepath.Rel(path.Dir(link), target)
 	if err != nil {
-		return fmt.Errorf("failed to read config directory %s: %w", co.CObasePath, err)
+		return target
 	}
-	configs := []string{}
-	for _, entry := range entries {
-		if entry.Name() != "previous" {
-			configs = append(configs, entry.Name())
-		}
+	return filepath.ToSlash(rel)
+}
+
+// linkTarget reads the symlink link and resolves a relative target against its directory.
+func (co *CO) linkTarget(link string) (string, error) {
+	target, err := co.filesystem().Readlink(link)
+	if err != nil {
+		return "", err
+	}
+	if !path.IsAbs(target) {
+		target = path.Join(path.Dir(link), target)
+	}
+	return target, nil
+}
+
+// ListConfigs reads the configured Store and populates the Configs field with
+// the names of all stored configs. It returns an error if the store cannot be read.
+func (co *CO) ListConfigs() error {
+	configs, err := co.store().List()
+	if err != nil {
+		return err
 	}
 	co.Configs = configs
 	return nil
 }
+
+// Metadata returns the metadata of the config with the given name including its usage.
+func (co *CO) Metadata(name string) (Metadata, error) {
+	if err := validateName(name); err != nil {
+		return Metadata{}, err
+	}
+	md, err := co.store().Metadata(name)
+	if err != nil {
+		return md, err
+	}
+	return co.withUsage(name, md)
+}
@@ -1,8 +1,10 @@
 package internal
 
 import (
+	"io/fs"
 	"os"
 	"path"
+	"syscall"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -41,13 +43,19 @@ func TestNewCO(t *testing.T) {
 		require.NoError(t, err)
 		assert.NotNil(t, co)
 	})
+	t.Run("Unknown backend", func(t *testing.T) {
+		home := t.TempDir()
+		co, err := NewCO(home, WithBackend("s3"))
+		require.Error(t, err)
+		assert.Nil(t, co)
+	})
 }
 
 func TestInitKubeHome(t *testing.T) {
 	t.Run("Success", func(t *testing.T) {
 		home := t.TempDir()
 		kubeHome := path.Join(home, ".kube")
-		err := initKubeHome(kubeHome)
+		err := initKubeHome(OSFS{}, kubeHome)
 		require.NoError(t, err)
 	})
 }
@@ -78,7 +86,7 @@ func TestAddConfig(t *testing.T) {
 		co.ConfigName = "newconfig"
 		target := path.Join(co.CObasePath, co.ConfigName)
 
-		err := co.AddConfig("")
+		err := co.AddConfig(t.Context(), "")
 		require.NoError(t, err)
 		assert.FileExists(t, target)
 	})
@@ -92,7 +100,7 @@ func TestAddConfig(t *testing.T) {
 		err := os.WriteFile(srcFile, content, 0600)
 		require.NoError(t, err)
 
-		err = co.AddConfig(srcFile)
+		err = co.AddConfig(t.Context(), srcFile)
 		require.NoError(t, err)
 
 		target := path.Join(co.CObasePath, co.ConfigName)
@@ -107,7 +115,7 @@ func TestAddConfig(t *testing.T) {
 		co := initCO(t)
 		co.ConfigName = "willfail"
 
-		err := co.AddConfig("does-not-exist.yml")
+		err := co.AddConfig(t.Context(), "does-not-exist.yml")
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "no such file or directory")
 	})
@@ -117,7 +125,7 @@ func TestAddConfig(t *testing.T) {
 		co.ConfigName = "badpath"
 		co.CObasePath = path.Join(t.TempDir(), "non", "existent", "dir")
 
-		err := co.AddConfig("")
+		err := co.AddConfig(t.Context(), "")
 		require.Error(t, err)
 		assert.Contains(t, err.Error(), "no such file or directory")
 	})
@@ -240,7 +248,7 @@ func TestDeleteConfig(t *testing.T) {
 		co.ConfigName = "does-not-exist"
 
 		err := co.DeleteConfig()
-		require.Error(t, err)
+		require.ErrorIs(t, err, ErrNotFound)
 		assert.Contains(t, err.Error(), "does not exist")
 	})
 
@@ -310,3 +318,320 @@ func TestListConfigs(t *testing.T) {
 		require.Contains(t, err.Error(), "no such file or directory")
 	})
 }
+
+// initMemCO creates a CO on a MemFS with the configs dev and prod. The kube config is linked to
+// dev and the previous link points to prod.
+func initMemCO(t *testing.T) (*MemFS, *CO) {
+	t.Setenv("KUBECONFIG", "")
+	fsys := NewMemFS()
+	coHome := "/home/.kube/co"
+	require.NoError(t, fsys.MkdirAll(coHome, onlyOwnerAccess))
+	require.NoError(t, fsys.WriteFile(path.Join(coHome, "dev"), nil, onlyOwnerAccess))
+	require.NoError(t, fsys.WriteFile(path.Join(coHome, "prod"), nil, onlyOwnerAccess))
+	require.NoError(t, fsys.Symlink(path.Join(coHome, "dev"), "/home/.kube/config"))
+	require.NoError(t, fsys.Symlink(path.Join(coHome, "prod"), path.Join(coHome, "previous")))
+
+	co, err := NewCO("/home", WithFS(fsys))
+	require.NoError(t, err)
+	return fsys, co
+}
+
+func TestNewCOFaults(t *testing.T) {
+	tests := []struct {
+		name    string
+		fault   func(fsys *MemFS)
+		wantErr string
+	}{
+		{
+			name: "kube home",
+			fault: func(fsys *MemFS) {
+				fsys.Fail("Stat", "/home/.kube", syscall.ENOENT)
+				fsys.Fail("Mkdir", "/home/.kube", syscall.EACCES)
+			},
+			wantErr: "failed to initialize kube home",
+		},
+		{
+			name:    "CO home",
+			fault:   func(fsys *MemFS) { fsys.Fail("Stat", "/home/.kube/co", syscall.EACCES) },
+			wantErr: "failed to initialize CO home",
+		},
+		{
+			name:    "previous link",
+			fault:   func(fsys *MemFS) { fsys.Fail("Readlink", "/home/.kube/co/previous", syscall.EACCES) },
+			wantErr: "failed to read previous config link",
+		},
+		{
+			name:    "current link",
+			fault:   func(fsys *MemFS) { fsys.Fail("Readlink", "/home/.kube/config", syscall.EACCES) },
+			wantErr: "failed to read current config path",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys := NewMemFS()
+			require.NoError(t, fsys.MkdirAll("/home/.kube/co", onlyOwnerAccess))
+			require.NoError(t, fsys.Symlink("/home/.kube/co/dev", "/home/.kube/config"))
+			tc.fault(fsys)
+
+			_, err := NewCO("/home", WithFS(fsys))
+			require.ErrorIs(t, err, syscall.EACCES)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+
+	t.Run("reads links", func(t *testing.T) {
+		_, co := initMemCO(t)
+		assert.Equal(t, "/home/.kube/co/dev", co.CurrentConfigPath)
+		assert.Equal(t, "/home/.kube/co/prod", co.PreviousConifgPath)
+	})
+}
+
+func TestLinkKubeConfigFaults(t *testing.T) {
+	kubeConfig := "/home/.kube/config"
+	previousLink := "/home/.kube/co/previous"
+	prod := "/home/.kube/co/prod"
+
+	tests := []struct {
+		name    string
+		fault   func(fsys *MemFS)
+		wantErr string
+	}{
+		{
+			name:    "remove kube config",
+			fault:   func(fsys *MemFS) { fsys.Fail("Remove", kubeConfig, syscall.EACCES) },
+			wantErr: "failed to remove kube config symlink",
+		},
+		{
+			name:    "remove previous link",
+			fault:   func(fsys *MemFS) { fsys.Fail("Remove", previousLink, syscall.EACCES) },
+			wantErr: "failed to remove previous config symlink",
+		},
+		{
+			name:    "config vanishes before linking",
+			fault:   func(fsys *MemFS) { fsys.FailAfter("Stat", prod, 1, syscall.ENOENT) },
+			wantErr: "config file /home/.kube/co/prod does not exist",
+		},
+		{
+			name:    "symlink kube config",
+			fault:   func(fsys *MemFS) { fsys.Fail("Symlink", kubeConfig, syscall.EACCES) },
+			wantErr: "failed to create symlink",
+		},
+		{
+			name:    "chmod kube config",
+			fault:   func(fsys *MemFS) { fsys.Fail("Chmod", kubeConfig, syscall.EACCES) },
+			wantErr: "failed to set permissions on kube config symlink",
+		},
+		{
+			name:    "symlink previous",
+			fault:   func(fsys *MemFS) { fsys.Fail("Symlink", previousLink, syscall.EACCES) },
+			wantErr: "failed to create symlink for previous config",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			fsys, co := initMemCO(t)
+			co.ConfigName = "prod"
+			tc.fault(fsys)
+
+			err := co.LinkKubeConfig()
+			require.Error(t, err)
+			assert.Contains(t, err.Error(), tc.wantErr)
+		})
+	}
+
+	t.Run("config missing", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "missing"
+
+		err := co.LinkKubeConfig()
+		require.ErrorIs(t, err, ErrNotFound)
+		assert.Contains(t, err.Error(), "config 'missing' does not exist")
+		target, err := fsys.Readlink(kubeConfig)
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/dev", target)
+	})
+
+	t.Run("no config name and no previous", func(t *testing.T) {
+		_, co := initMemCO(t)
+		co.PreviousConifgPath = ""
+
+		err := co.LinkKubeConfig()
+		require.ErrorIs(t, err, ErrNoPrevious)
+		assert.Contains(t, err.Error(), "don't know what to do")
+	})
+
+	t.Run("success", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "prod"
+
+		require.NoError(t, co.LinkKubeConfig())
+		target, err := fsys.Readlink(kubeConfig)
+		require.NoError(t, err)
+		assert.Equal(t, prod, target)
+		target, err = fsys.Readlink(previousLink)
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/dev", target)
+	})
+}
+
+func TestCleanupFaults(t *testing.T) {
+	t.Run("remove kube config", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		fsys.Fail("Remove", co.KubeConfigPath, syscall.EACCES)
+
+		err := co.cleanup()
+		require.ErrorIs(t, err, syscall.EACCES)
+		assert.Contains(t, err.Error(), "failed to remove kube config symlink")
+	})
+
+	t.Run("remove previous link", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		fsys.Fail("Remove", co.PreviousConfigLink, syscall.EACCES)
+
+		err := co.cleanup()
+		require.ErrorIs(t, err, syscall.EACCES)
+		assert.Contains(t, err.Error(), "failed to remove previous config symlink")
+	})
+
+	t.Run("missing links are tolerated", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		require.NoError(t, fsys.Remove(co.KubeConfigPath))
+		require.NoError(t, fsys.Remove(co.PreviousConfigLink))
+
+		require.NoError(t, co.cleanup())
+	})
+}
+
+func TestDeleteConfigFaults(t *testing.T) {
+	t.Run("stat", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "dev"
+		fsys.Fail("Stat", "/home/.kube/co/dev", syscall.EACCES)
+
+		err := co.DeleteConfig()
+		require.ErrorIs(t, err, syscall.EACCES)
+		assert.Contains(t, err.Error(), "failed to check config file")
+	})
+
+	t.Run("link", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "dev"
+		fsys.Fail("Symlink", co.KubeConfigPath, syscall.EACCES)
+
+		err := co.DeleteConfig()
+		require.ErrorIs(t, err, syscall.EACCES)
+		assert.Contains(t, err.Error(), "failed to link kube config after deletion")
+		_, err = fsys.Stat("/home/.kube/co/dev")
+		require.NoError(t, err)
+	})
+
+	t.Run("remove config", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "dev"
+		fsys.Fail("Remove", "/home/.kube/co/dev", syscall.EACCES)
+
+		err := co.DeleteConfig()
+		require.ErrorIs(t, err, syscall.EACCES)
+		assert.Contains(t, err.Error(), "failed to delete config file")
+	})
+
+	t.Run("remove metadata", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "dev"
+		require.NoError(t, co.store().SetMetadata("dev", Metadata{Annotations: map[string]string{"a": "b"}}))
+		fsys.Fail("Remove", "/home/.kube/co/.meta/dev.yaml", syscall.EACCES)
+
+		err := co.DeleteConfig()
+		require.ErrorIs(t, err, syscall.EACCES)
+		assert.Contains(t, err.Error(), "failed to delete metadata of dev")
+	})
+
+	t.Run("success", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "dev"
+
+		require.NoError(t, co.DeleteConfig())
+		_, err := fsys.Stat("/home/.kube/co/dev")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		target, err := fsys.Readlink(co.KubeConfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/prod", target)
+	})
+}
+
+func TestRenameConfig(t *testing.T) {
+	t.Run("Relinks current", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "dev"
+
+		require.NoError(t, co.RenameConfig("development"))
+		target, err := fsys.Readlink(co.KubeConfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/development", target)
+		assert.Equal(t, "/home/.kube/co/development", co.CurrentConfigPath)
+		assert.Equal(t, "development", co.ConfigName)
+	})
+
+	t.Run("Relinks previous", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "prod"
+
+		require.NoError(t, co.RenameConfig("production"))
+		target, err := fsys.Readlink(co.PreviousConfigLink)
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/production", target)
+		target, err = fsys.Readlink(co.KubeConfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/dev", target)
+	})
+
+	t.Run("Existing target", func(t *testing.T) {
+		_, co := initMemCO(t)
+		co.ConfigName = "dev"
+
+		err := co.RenameConfig("prod")
+		require.ErrorIs(t, err, ErrExists)
+	})
+
+	t.Run("Not found", func(t *testing.T) {
+		_, co := initMemCO(t)
+		co.ConfigName = "missing"
+
+		err := co.RenameConfig("other")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Relink failure", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "dev"
+		fsys.Fail("Symlink", co.KubeConfigPath, syscall.EACCES)
+
+		err := co.RenameConfig("development")
+		require.ErrorIs(t, err, syscall.EACCES)
+		assert.Contains(t, err.Error(), "failed to relink kube config")
+	})
+}
+
+func TestReservedNames(t *testing.T) {
+	fsys, _ := initMemCO(t)
+	co, err := NewCO("/home", WithFS(fsys), WithReservedNames("log", "update"))
+	require.NoError(t, err)
+
+	co.ConfigName = "log"
+	require.ErrorIs(t, co.AddConfig(t.Context(), ""), ErrReserved)
+	co.ConfigName = "dev"
+	err = co.RenameConfig("update")
+	require.ErrorIs(t, err, ErrReserved)
+	require.ErrorIs(t, err, ErrInvalid)
+	assert.Contains(t, err.Error(), "reserved for a command")
+	require.ErrorIs(t, co.AddAlias("log"), ErrReserved)
+
+	require.NoError(t, fsys.MkdirAll("/home/Downloads", onlyOwnerAccess))
+	require.NoError(t, fsys.WriteFile("/home/Downloads/cfg.yaml", kubeConfigData("update", "update"), 0600))
+	results, err := co.Import(t.Context(), "/home/Downloads", false)
+	require.NoError(t, err)
+	require.Len(t, results, 1)
+	assert.Equal(t, "update-2", results[0].Name)
+}
@@ -0,0 +1,322 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/steffakasid/eslog"
+)
+
+// Severity tells how serious a Problem found by Doctor is.
+type Severity string
+
+const (
+	// SeverityError marks problems which break switching or using configs.
+	SeverityError Severity = "error"
+	// SeverityWarning marks problems which may lead to errors or leak credentials.
+	SeverityWarning Severity = "warning"
+	// SeverityInfo marks unusual but working setups.
+	SeverityInfo Severity = "info"
+)
+
+// Problem is an issue of the kube home, the store or the links found by Doctor.
+type Problem struct {
+	Severity Severity
+	// Path is the file or directory the problem was found at.
+	Path    string
+	Message string
+	// Fix describes the repair done with Doctor(fix=true). It is empty if the problem can't be
+	// repaired safely.
+	Fix string
+	// Fixed is set if the problem was repaired.
+	Fixed bool
+	// FixErr is set if repairing the problem failed.
+	FixErr error
+}
+
+// doctor collects the problems found and repairs them if fix is set.
+type doctor struct {
+	co       *CO
+	fix      bool
+	problems []Problem
+}
+
+// Doctor inspects everything NewCO reads: the kube home, the store and state directories, the
+// configs and their metadata, the kube config and previous links and the KUBECONFIG environment
+// variable.
+// With fix set, every problem with a Fix is repaired right after it was found, so later checks
+// see the repaired state. Doctor doesn't create a CO first as NewCO fails on some of the problems.
+// Only an invalid backend is returned as error.
+func Doctor(home string, fix bool, opts ...Option) ([]Problem, error) {
+	co := newCO(home, opts...)
+	if _, err := NewStore(co.backend, co.fs, ""); err != nil {
+		return nil, err
+	}
+	d := &doctor{co: co, fix: fix}
+
+	d.checkKubeConfigEnv()
+	if !d.checkDir(path.Dir(co.KubeConfigPath), "kube home") || !d.checkDir(co.CObasePath, "config store") {
+		return d.problems, nil
+	}
+	if co.StateDir != co.CObasePath && !d.checkDir(co.StateDir, "state directory") {
+		return d.problems, nil
+	}
+	d.checkConfigs()
+	d.checkMetadata()
+	d.checkKubeConfigLink()
+	d.checkPreviousLink()
+	return d.problems, nil
+}
+
+// report records p and runs repair if fixing is enabled. It returns whether p was repaired.
+func (d *doctor) report(p Problem, repair func() error) bool {
+	if d.fix && repair != nil {
+		p.FixErr = repair()
+		p.Fixed = p.FixErr == nil
+		if p.Fixed {
+			eslog.Debugf("Fixed %s: %s", p.Path, p.Fix)
+		}
+	}
+	d.problems = append(d.problems, p)
+	return p.Fixed
+}
+
+func (d *doctor) fs() FS {
+	return d.co.filesystem()
+}
+
+// checkKubeConfigEnv reports a KUBECONFIG environment variable which makes kubectl ignore the
+// kube config link.
+func (d *doctor) checkKubeConfigEnv() {
+	files := d.co.KubeConfigEnv
+	if len(files) == 0 {
+		return
+	}
+	if d.co.KubeConfigIgnored() {
+		d.report(Problem{Severity: SeverityWarning, Path: d.co.KubeConfigPath,
+			Message: fmt.Sprintf("KUBECONFIG=%s overrides the kube config, switching configs has no effect on kubectl", JoinKubeConfigEnv(files))}, nil)
+	} else if len(files) > 1 {
+		d.report(Problem{Severity: SeverityInfo, Path: d.co.KubeConfigPath,
+			Message: fmt.Sprintf("KUBECONFIG merges the kube config with %d other files", len(files)-1)}, nil)
+	}
+}
+
+// checkDir makes sure dir is a directory only the owner can access. It returns false if dir
+// can't be used, which makes all further checks pointless.
+func (d *doctor) checkDir(dir, what string) bool {
+	fi, err := d.fs().Stat(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return d.report(Problem{Severity: SeverityWarning, Path: dir, Message: what + " does not exist", Fix: "create it"},
+			func() error { return d.fs().MkdirAll(dir, onlyOwnerAccess) })
+	} else if err != nil {
+		d.report(Problem{Severity: SeverityError, Path: dir, Message: fmt.Sprintf("failed to read %s: %v", what, err)}, nil)
+		return false
+	}
+	if !fi.IsDir() {
+		d.report(Problem{Severity: SeverityError, Path: dir, Message: what + " is not a directory"}, nil)
+		return false
+	}
+	d.checkMode(dir, what, fi.Mode())
+	return true
+}
+
+// checkMode reports files and directories other users can access.
+func (d *doctor) checkMode(name, what string, mode fs.FileMode) {
+	if mode.Perm()&^onlyOwnerAccess == 0 {
+		return
+	}
+	d.report(Problem{Severity: SeverityWarning, Path: name,
+		Message: fmt.Sprintf("%s is accessible by other users (mode %04o)", what, mode.Perm()),
+		Fix:     fmt.Sprintf("restrict the permissions to %04o", onlyOwnerAccess)},
+		func() error { return d.fs().Chmod(name, onlyOwnerAccess) })
+}
+
+// checkConfigs inspects every entry List returns as config.
+func (d *doctor) checkConfigs() {
+	entries, err := d.fs().ReadDir(d.co.CObasePath)
+	if err != nil {
+		d.report(Problem{Severity: SeverityError, Path: d.co.CObasePath, Message: fmt.Sprintf("failed to read config store: %v", err)}, nil)
+		return
+	}
+	for _, entry := range entries {
+		if entry.Name() == previousLinkName || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		d.checkConfig(entry.Name())
+	}
+}
+
+func (d *doctor) checkConfig(name string) {
+	configPath := d.co.store().Path(name)
+	what := fmt.Sprintf("config %s", name)
+	fi, err := d.fs().Lstat(configPath)
+	if err != nil {
+		d.report(Problem{Severity: SeverityError, Path: configPath, Message: fmt.Sprintf("failed to read %s: %v", what, err)}, nil)
+		return
+	}
+	if fi.Mode()&fs.ModeSymlink != 0 {
+		target, _ := d.co.linkTarget(configPath)
+		fi, err = d.fs().Stat(configPath)
+		if errors.Is(err, fs.ErrNotExist) {
+			d.report(Problem{Severity: SeverityWarning, Path: configPath,
+				Message: fmt.Sprintf("%s links to the missing file %s", what, target), Fix: "remove the link"},
+				func() error { return d.fs().Remove(configPath) })
+			return
+		} else if err != nil {
+			d.report(Problem{Severity: SeverityError, Path: configPath, Message: fmt.Sprintf("failed to read %s: %v", what, err)}, nil)
+			return
+		}
+	}
+	if fi.IsDir() {
+		d.report(Problem{Severity: SeverityWarning, Path: configPath,
+			Message: fmt.Sprintf("%s is a directory and can't be used as kube config, move it out of the store", what)}, nil)
+		return
+	}
+	d.checkMode(configPath, what, fi.Mode())
+
+	data, err := d.fs().ReadFile(configPath)
+	if err != nil {
+		d.report(Problem{Severity: SeverityError, Path: configPath, Message: fmt.Sprintf("failed to read %s: %v", what, err)}, nil)
+		return
+	}
+	kubeConfig, err := ParseKubeConfig(data)
+	if err != nil {
+		d.report(Problem{Severity: SeverityError, Path: configPath, Message: fmt.Sprintf("%s is not a valid kubeconfig: %v", what, err)}, nil)
+		return
+	}
+	if kubeConfig.CurrentContext != "" {
+		if _, ok := kubeConfig.Context(kubeConfig.CurrentContext); !ok {
+			d.report(Problem{Severity: SeverityWarning, Path: configPath,
+				Message: fmt.Sprintf("current context %q of %s does not exist", kubeConfig.CurrentContext, what)}, nil)
+		}
+	}
+}
+
+// checkMetadata reports metadata which can't be read or doesn't belong to a config.
+func (d *doctor) checkMetadata() {
+	metadataDir := path.Join(d.co.CObasePath, metadataDirName)
+	entries, err := d.fs().ReadDir(metadataDir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	} else if err != nil {
+		d.report(Problem{Severity: SeverityError, Path: metadataDir, Message: fmt.Sprintf("failed to read metadata directory: %v", err)}, nil)
+		return
+	}
+	store := d.co.store()
+	for _, entry := range entries {
+		name, ok := strings.CutSuffix(entry.Name(), ".yaml")
+		if !ok || entry.IsDir() {
+			continue
+		}
+		metadataPath := path.Join(metadataDir, entry.Name())
+		if _, err := d.fs().Lstat(store.Path(name)); errors.Is(err, fs.ErrNotExist) {
+			d.report(Problem{Severity: SeverityWarning, Path: metadataPath,
+				Message: fmt.Sprintf("metadata of the missing config %s", name), Fix: "remove it"},
+				func() error { return d.fs().Remove(metadataPath) })
+			continue
+		}
+		md, err := store.Metadata(name)
+		if err != nil {
+			d.report(Problem{Severity: SeverityError, Path: metadataPath, Message: err.Error()}, nil)
+			continue
+		}
+		if err := md.Validate(); err != nil {
+			d.report(Problem{Severity: SeverityWarning, Path: metadataPath,
+				Message: fmt.Sprintf("metadata of %s: %v", name, err)}, nil)
+		}
+	}
+}
+
+// checkKubeConfigLink makes sure the kube config links to an existing file. A dangling link is
+// pointed to the previous config if there is one and removed otherwise.
+func (d *doctor) checkKubeConfigLink() {
+	co := d.co
+	fi, err := d.fs().Lstat(co.KubeConfigPath)
+	if errors.Is(err, fs.ErrNotExist) {
+		d.report(Problem{Severity: SeverityInfo, Path: co.KubeConfigPath, Message: "kube config does not exist, no config is in use"}, nil)
+		return
+	} else if err != nil {
+		d.report(Problem{Severity: SeverityError, Path: co.KubeConfigPath, Message: fmt.Sprintf("failed to read kube config: %v", err)}, nil)
+		return
+	}
+	if fi.Mode()&fs.ModeSymlink == 0 {
+		if fi.IsDir() {
+			d.report(Problem{Severity: SeverityError, Path: co.KubeConfigPath, Message: "kube config is a directory"}, nil)
+			return
+		}
+		d.report(Problem{Severity: SeverityWarning, Path: co.KubeConfigPath,
+			Message: "kube config is a file and not a link, it is removed on the next switch. Add it to the store first"}, nil)
+		return
+	}
+
+	target, err := d.co.linkTarget(co.KubeConfigPath)
+	if err != nil {
+		d.report(Problem{Severity: SeverityError, Path: co.KubeConfigPath, Message: fmt.Sprintf("failed to read kube config link: %v", err)}, nil)
+		return
+	}
+	fi, err = d.fs().Stat(target)
+	switch {
+	case errors.Is(err, fs.ErrNotExist):
+		p := Problem{Severity: SeverityError, Path: co.KubeConfigPath, Message: fmt.Sprintf("kube config links to the missing file %s", target)}
+		if previous, ok := d.previousConfig(); ok {
+			p.Fix = fmt.Sprintf("link the previous config %s", previous)
+			d.report(p, func() error {
+				if err := co.relink(co.KubeConfigPath, previous); err != nil {
+					return err
+				}
+				co.CurrentConfigPath = previous
+				return d.fs().Remove(co.PreviousConfigLink)
+			})
+			return
+		}
+		p.Fix = "remove the link"
+		d.report(p, func() error { return d.fs().Remove(co.KubeConfigPath) })
+	case err != nil:
+		d.report(Problem{Severity: SeverityError, Path: co.KubeConfigPath, Message: fmt.Sprintf("failed to read kube config: %v", err)}, nil)
+	case fi.IsDir():
+		d.report(Problem{Severity: SeverityError, Path: co.KubeConfigPath,
+			Message: fmt.Sprintf("kube config links to the directory %s", target), Fix: "remove the link"},
+			func() error { return d.fs().Remove(co.KubeConfigPath) })
+	case path.Dir(target) != path.Clean(co.CObasePath):
+		d.report(Problem{Severity: SeverityInfo, Path: co.KubeConfigPath,
+			Message: fmt.Sprintf("kube config links to %s outside of the store", target)}, nil)
+	}
+}
+
+// previousConfig returns the file the previous link points to if it exists.
+func (d *doctor) previousConfig() (string, bool) {
+	target, err := d.co.linkTarget(d.co.PreviousConfigLink)
+	if err != nil {
+		return "", false
+	}
+	if fi, err := d.fs().Stat(target); err != nil || fi.IsDir() {
+		return "", false
+	}
+	return target, true
+}
+
+// checkPreviousLink reports a previous link which isn't a symlink or points to a missing file.
+func (d *doctor) checkPreviousLink() {
+	link := d.co.PreviousConfigLink
+	fi, err := d.fs().Lstat(link)
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	} else if err != nil {
+		d.report(Problem{Severity: SeverityError, Path: link, Message: fmt.Sprintf("failed to read previous link: %v", err)}, nil)
+		return
+	}
+	if fi.Mode()&fs.ModeSymlink == 0 {
+		d.report(Problem{Severity: SeverityError, Path: link,
+			Message: "previous is not a link, the next switch removes it or fails"}, nil)
+		return
+	}
+	if _, ok := d.previousConfig(); !ok {
+		target, _ := d.co.linkTarget(link)
+		d.report(Problem{Severity: SeverityWarning, Path: link,
+			Message: fmt.Sprintf("previous links to the missing file %s", target), Fix: "remove the link"},
+			func() error { return d.fs().Remove(link) })
+	}
+}
@@ -0,0 +1,175 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDoctor(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+
+	t.Run("Healthy", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		problems, err := Doctor("/home", false, WithFS(fsys))
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("Missing kube home", func(t *testing.T) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.Mkdir("/home", onlyOwnerAccess))
+		problems, err := Doctor("/home", false, WithFS(fsys))
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+		assert.Equal(t, "/home/.kube", problems[0].Path)
+		assert.False(t, problems[0].Fixed)
+
+		problems, err = Doctor("/home", true, WithFS(fsys))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/home/.kube", "/home/.kube/co", "/home/.kube/config"}, problemPaths(problems))
+		assert.True(t, problems[0].Fixed)
+		assert.True(t, problems[1].Fixed)
+		_, err = NewCO("/home", WithFS(fsys))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Kube home is a file", func(t *testing.T) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.Mkdir("/home", onlyOwnerAccess))
+		require.NoError(t, fsys.WriteFile("/home/.kube", nil, 0600))
+		problems, err := Doctor("/home", true, WithFS(fsys))
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+		assert.Equal(t, SeverityError, problems[0].Severity)
+		assert.Empty(t, problems[0].Fix)
+	})
+
+	t.Run("Broken store", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		coHome := "/home/.kube/co"
+		require.NoError(t, fsys.Chmod(coHome, 0755))
+		require.NoError(t, fsys.Chmod(path.Join(coHome, "prod"), 0644))
+		require.NoError(t, fsys.Mkdir(path.Join(coHome, "folder"), onlyOwnerAccess))
+		require.NoError(t, fsys.WriteFile(path.Join(coHome, "broken"), []byte("clusters: {"), onlyOwnerAccess))
+		require.NoError(t, fsys.WriteFile(path.Join(coHome, "ctx"), []byte("current-context: missing\n"), onlyOwnerAccess))
+		require.NoError(t, fsys.Symlink(path.Join(coHome, "gone"), path.Join(coHome, "dangling")))
+		require.NoError(t, fsys.MkdirAll(path.Join(coHome, metadataDirName), onlyOwnerAccess))
+		require.NoError(t, fsys.WriteFile(path.Join(coHome, metadataDirName, "gone.yaml"), nil, 0600))
+		require.NoError(t, fsys.WriteFile(path.Join(coHome, metadataDirName, "dev.yaml"), []byte("labels: {'bad key': x}\n"), 0600))
+
+		problems, err := Doctor("/home", false, WithFS(fsys))
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"warning /home/.kube/co",
+			"error /home/.kube/co/broken",
+			"warning /home/.kube/co/ctx",
+			"warning /home/.kube/co/dangling",
+			"warning /home/.kube/co/folder",
+			"warning /home/.kube/co/prod",
+			"warning /home/.kube/co/.meta/dev.yaml",
+			"warning /home/.kube/co/.meta/gone.yaml",
+		}, problemSummaries(problems))
+
+		problems, err = Doctor("/home", true, WithFS(fsys))
+		require.NoError(t, err)
+		for _, p := range problems {
+			assert.Equal(t, p.Fix != "", p.Fixed, p.Path)
+			assert.NoError(t, p.FixErr)
+		}
+
+		problems, err = Doctor("/home", false, WithFS(fsys))
+		require.NoError(t, err)
+		assert.Equal(t, []string{
+			"error /home/.kube/co/broken",
+			"warning /home/.kube/co/ctx",
+			"warning /home/.kube/co/folder",
+			"warning /home/.kube/co/.meta/dev.yaml",
+		}, problemSummaries(problems))
+	})
+
+	t.Run("Dangling links", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		require.NoError(t, fsys.Remove("/home/.kube/co/dev"))
+
+		problems, err := Doctor("/home", true, WithFS(fsys))
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+		assert.Equal(t, SeverityError, problems[0].Severity)
+		assert.True(t, problems[0].Fixed)
+		target, err := fsys.Readlink("/home/.kube/config")
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/prod", target)
+		_, err = fsys.Lstat("/home/.kube/co/previous")
+		assert.Error(t, err)
+
+		require.NoError(t, fsys.Remove("/home/.kube/co/prod"))
+		problems, err = Doctor("/home", true, WithFS(fsys))
+		require.NoError(t, err)
+		require.Len(t, problems, 1)
+		assert.Equal(t, "remove the link", problems[0].Fix)
+		_, err = fsys.Lstat("/home/.kube/config")
+		assert.Error(t, err)
+	})
+
+	t.Run("Dangling previous link", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		require.NoError(t, fsys.Remove("/home/.kube/co/prod"))
+		problems, err := Doctor("/home", true, WithFS(fsys))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"warning /home/.kube/co/previous"}, problemSummaries(problems))
+		assert.True(t, problems[0].Fixed)
+	})
+
+	t.Run("Kube config is a file", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		require.NoError(t, fsys.Remove("/home/.kube/config"))
+		require.NoError(t, fsys.WriteFile("/home/.kube/config", nil, 0600))
+		problems, err := Doctor("/home", true, WithFS(fsys))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"warning /home/.kube/config"}, problemSummaries(problems))
+		assert.False(t, problems[0].Fixed)
+	})
+
+	t.Run("KUBECONFIG", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		t.Setenv("KUBECONFIG", "/tmp/other")
+		problems, err := Doctor("/home", false, WithFS(fsys))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"warning /home/.kube/config"}, problemSummaries(problems))
+
+		t.Setenv("KUBECONFIG", "/home/.kube/config:/tmp/other")
+		problems, err = Doctor("/home", false, WithFS(fsys))
+		require.NoError(t, err)
+		assert.Equal(t, []string{"info /home/.kube/config"}, problemSummaries(problems))
+
+		t.Setenv("KUBECONFIG", "/home/.kube/config")
+		problems, err = Doctor("/home", false, WithFS(fsys))
+		require.NoError(t, err)
+		assert.Empty(t, problems)
+	})
+
+	t.Run("Invalid backend", func(t *testing.T) {
+		_, err := Doctor("/home", false, WithFS(NewMemFS()), WithBackend("svn"))
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+}
+
+func problemPaths(problems []Problem) []string {
+	paths := []string{}
+	for _, p := range problems {
+		paths = append(paths, p.Path)
+	}
+	return paths
+}
+
+func problemSummaries(problems []Problem) []string {
+	summaries := []string{}
+	for _, p := range problems {
+		summaries = append(summaries, fmt.Sprintf("%s %s", p.Severity, p.Path))
+	}
+	return summaries
+}
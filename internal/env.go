@@ -0,0 +1,47 @@
+package internal
+
+import (
+	"maps"
+	"slices"
+)
+
+// EnvChanges returns the environment variables to set and to unset for using the config name.
+// If name is the current config the variables of the previous config are unset, otherwise the
+// ones of the current config. Variables of both configs are only set.
+func (co *CO) EnvChanges(name string) (map[string]string, []string, error) {
+	name, err := co.ResolveName(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !slices.Contains(co.Configs, name) {
+		return nil, nil, co.notFound(name)
+	}
+	store := co.store()
+	md, err := store.Metadata(name)
+	if err != nil {
+		return nil, nil, err
+	}
+	set := maps.Clone(md.Variables)
+	if set == nil {
+		set = map[string]string{}
+	}
+
+	old := co.storeName(co.EffectiveConfigPath())
+	if old == name {
+		old = co.storeName(co.PreviousConifgPath)
+	}
+	unset := []string{}
+	if old != "" && old != name {
+		oldMd, err := store.Metadata(old)
+		if err != nil {
+			return nil, nil, err
+		}
+		for variable := range oldMd.Variables {
+			if _, ok := set[variable]; !ok {
+				unset = append(unset, variable)
+			}
+		}
+	}
+	slices.Sort(unset)
+	return set, unset, nil
+}
@@ -0,0 +1,49 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEnvChanges(t *testing.T) {
+	_, co := initMemCO(t)
+	setVariables := func(name string, variables map[string]string) {
+		co.ConfigName = name
+		require.NoError(t, co.UpdateMetadata(func(md *Metadata) error {
+			md.Variables = variables
+			return nil
+		}))
+	}
+	setVariables("dev", map[string]string{"AWS_PROFILE": "dev", "HTTPS_PROXY": "http://proxy:3128"})
+	setVariables("prod", map[string]string{"AWS_PROFILE": "prod"})
+
+	// dev is the current config and prod the previous one
+	set, unset, err := co.EnvChanges("dev")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"AWS_PROFILE": "dev", "HTTPS_PROXY": "http://proxy:3128"}, set)
+	assert.Empty(t, unset)
+
+	set, unset, err = co.EnvChanges("prod")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"AWS_PROFILE": "prod"}, set)
+	assert.Equal(t, []string{"HTTPS_PROXY"}, unset)
+
+	co.ConfigName = "prod"
+	require.NoError(t, co.LinkKubeConfig())
+	co, err = NewCO("/home", WithFS(co.filesystem()))
+	require.NoError(t, err)
+	_, unset, err = co.EnvChanges("prod")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HTTPS_PROXY"}, unset)
+
+	_, _, err = co.EnvChanges("missing")
+	require.ErrorIs(t, err, ErrNotFound)
+
+	co.ConfigName = "dev"
+	require.ErrorIs(t, co.UpdateMetadata(func(md *Metadata) error {
+		md.Variables = map[string]string{"NOT-VALID": "x"}
+		return nil
+	}), ErrInvalid)
+}
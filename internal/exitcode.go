@@ -0,0 +1,52 @@
+package internal
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"os/exec"
+)
+
+// Process exit codes by error category.
+const (
+	ExitOK         = 0
+	ExitError      = 1
+	ExitUsage      = 2
+	ExitNotFound   = 3
+	ExitConflict   = 4
+	ExitIO         = 5
+	ExitValidation = 6
+	ExitProtected  = 7
+)
+
+// ExitCode maps err to the exit code of its category. Errors wrapping ErrUsage, ErrNotFound,
+// ErrNoPrevious, ErrExists, ErrAmbiguous, ErrInvalid or ErrProtected get the code of their
+// category, errors wrapping a filesystem or process error are I/O errors. Everything else,
+// including switches vetoed by a hook (ErrVetoed), returns ExitError.
+func ExitCode(err error) int {
+	var pathErr *fs.PathError
+	var linkErr *os.LinkError
+	var syscallErr *os.SyscallError
+	var execErr *exec.ExitError
+
+	switch {
+	case err == nil:
+		return ExitOK
+	case errors.Is(err, ErrUsage):
+		return ExitUsage
+	case errors.Is(err, ErrNotFound), errors.Is(err, ErrNoPrevious):
+		return ExitNotFound
+	case errors.Is(err, ErrExists), errors.Is(err, ErrAmbiguous):
+		return ExitConflict
+	case errors.Is(err, ErrInvalid):
+		return ExitValidation
+	case errors.Is(err, ErrProtected):
+		return ExitProtected
+	case errors.Is(err, ErrVetoed):
+		return ExitError
+	case errors.As(err, &pathErr), errors.As(err, &linkErr), errors.As(err, &syscallErr), errors.As(err, &execErr):
+		return ExitIO
+	default:
+		return ExitError
+	}
+}
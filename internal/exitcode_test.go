@@ -0,0 +1,65 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExitCode(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want int
+	}{
+		{name: "nil", err: nil, want: ExitOK},
+		{name: "usage", err: fmt.Errorf("%w: too many arguments", ErrUsage), want: ExitUsage},
+		{name: "not found", err: fmt.Errorf("config 'x' does not exist: %w", ErrNotFound), want: ExitNotFound},
+		{name: "no previous", err: fmt.Errorf("wrapped: %w", ErrNoPrevious), want: ExitNotFound},
+		{name: "conflict", err: fmt.Errorf("failed to rename: %w", ErrExists), want: ExitConflict},
+		{name: "ambiguous", err: fmt.Errorf("%w: st matches staging, stable", ErrAmbiguous), want: ExitConflict},
+		{name: "validation", err: fmt.Errorf("%w: config name %q", ErrInvalid, "a/b"), want: ExitValidation},
+		{name: "protected", err: fmt.Errorf("%w: prod was not confirmed", ErrProtected), want: ExitProtected},
+		{
+			name: "io path error",
+			err:  fmt.Errorf("failed: %w", &fs.PathError{Op: "open", Path: "/x", Err: syscall.EACCES}),
+			want: ExitIO,
+		},
+		{
+			name: "io link error",
+			err:  fmt.Errorf("failed: %w", &os.LinkError{Op: "symlink", Old: "/a", New: "/b", Err: syscall.EEXIST}),
+			want: ExitIO,
+		},
+		{
+			name: "not found wins over io",
+			err:  fmt.Errorf("%w: %w", ErrNotFound, &fs.PathError{Op: "stat", Path: "/x", Err: syscall.ENOENT}),
+			want: ExitNotFound,
+		},
+		{name: "other", err: errors.New("boom"), want: ExitError},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			assert.Equal(t, tc.want, ExitCode(tc.err))
+		})
+	}
+
+	t.Run("CO errors", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "missing"
+		assert.Equal(t, ExitNotFound, ExitCode(co.LinkKubeConfig()))
+
+		co.ConfigName = "dev"
+		assert.Equal(t, ExitConflict, ExitCode(co.RenameConfig("prod")))
+		assert.Equal(t, ExitValidation, ExitCode(co.RenameConfig("../escape")))
+
+		fsys.Fail("Symlink", co.KubeConfigPath, syscall.EACCES)
+		co.ConfigName = "prod"
+		assert.Equal(t, ExitIO, ExitCode(co.LinkKubeConfig()))
+	})
+}
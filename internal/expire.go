@@ -0,0 +1,197 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/steffakasid/eslog"
+	"go.yaml.in/yaml/v3"
+)
+
+// trashDirName is the directory in the state directory expired configs are moved to. It is
+// hidden so it isn't listed as config if the state directory is the store directory.
+const trashDirName = ".trash"
+
+// trashTimeLayout is the format of the time suffix of a trashed config.
+const trashTimeLayout = "20060102T150405Z"
+
+// ExpiryWarningWindow is the time before the expiry of a temporary config within which switching
+// to it warns.
+const ExpiryWarningWindow = time.Hour
+
+// GCResult describes what CollectExpired did.
+type GCResult struct {
+	// Trashed are the names of the expired configs moved to the trash.
+	Trashed []string
+	// Trash is the directory the configs were moved to.
+	Trash string
+	// SwitchedTo is the config the kube config was linked to because the current config
+	// expired. It is empty if the current config didn't expire or there was no usable previous
+	// config, see Unlinked.
+	SwitchedTo string
+	// Unlinked is set if the kube config link of an expired config was removed because there
+	// was no previous config to switch to.
+	Unlinked bool
+}
+
+// SetExpiry makes co.ConfigName a temporary config which CollectExpired moves to the trash
+// after expires. A zero expires makes it permanent again.
+func (co *CO) SetExpiry(expires time.Time) error {
+	return co.UpdateMetadata(func(md *Metadata) error {
+		md.Expires = expires.UTC().Truncate(time.Second)
+		return nil
+	})
+}
+
+// TrashDir returns the directory expired configs are moved to.
+func (co *CO) TrashDir() string {
+	return path.Join(co.StateDir, trashDirName)
+}
+
+// CollectExpired moves the configs which expired before now with their metadata to a
+// directory per config in TrashDir. If the kube config links to an expired config it is
+// linked to the previous config instead, unless that is missing, expired as well or protected,
+// in which case the link is removed. Hooks don't run for this switch. A previous link to an
+// expired config is removed. Every expired config is recorded in the audit log.
+func (co *CO) CollectExpired(now time.Time) (GCResult, error) {
+	result := GCResult{Trash: co.TrashDir()}
+	if err := co.ListConfigs(); err != nil {
+		return result, err
+	}
+	store := co.store()
+	expired := []string{}
+	for _, name := range co.Configs {
+		md, err := store.Metadata(name)
+		if err != nil {
+			return result, err
+		}
+		if !md.Expires.IsZero() && !md.Expires.After(now) {
+			expired = append(expired, name)
+		}
+	}
+	if len(expired) == 0 {
+		return result, nil
+	}
+
+	if current := co.storeName(co.CurrentConfigPath); slices.Contains(expired, current) {
+		if err := co.switchFromExpired(expired, &result); err != nil {
+			return result, err
+		}
+	}
+	if previous := co.storeName(co.PreviousConifgPath); slices.Contains(expired, previous) {
+		if err := co.filesystem().Remove(co.PreviousConfigLink); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return result, fmt.Errorf("failed to remove previous config link: %w", err)
+		}
+		co.PreviousConifgPath = ""
+	}
+
+	for _, name := range expired {
+		err := co.trash(name, now)
+		co.audit(AuditEntry{Action: AuditExpire, Config: name}, err)
+		if err != nil {
+			return result, err
+		}
+		result.Trashed = append(result.Trashed, name)
+	}
+	return result, nil
+}
+
+// switchFromExpired links the kube config to the previous config if it is usable and removes
+// the link otherwise.
+func (co *CO) switchFromExpired(expired []string, result *GCResult) error {
+	from := co.CurrentConfigPath
+	previous := co.PreviousConifgPath
+	usable := co.storeName(previous) != "" && !slices.Contains(expired, co.storeName(previous))
+	if usable {
+		if _, err := co.filesystem().Stat(previous); err != nil {
+			usable = false
+		}
+	}
+	if usable {
+		protected, err := co.IsProtected(co.storeName(previous))
+		usable = err == nil && !protected
+	}
+
+	if !usable {
+		err := co.filesystem().Remove(co.KubeConfigPath)
+		co.audit(AuditEntry{Action: AuditLink, From: from}, err)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove kube config link: %w", err)
+		}
+		co.CurrentConfigPath = ""
+		result.Unlinked = true
+		return nil
+	}
+	err := co.relink(co.KubeConfigPath, previous)
+	co.audit(AuditEntry{Action: AuditLink, Config: co.storeName(previous), From: from, To: previous}, err)
+	if err != nil {
+		return fmt.Errorf("failed to link kube config to previous config: %w", err)
+	}
+	if err := co.filesystem().Remove(co.PreviousConfigLink); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove previous config link: %w", err)
+	}
+	co.CurrentConfigPath = previous
+	co.PreviousConifgPath = ""
+	result.SwitchedTo = co.storeName(previous)
+	return nil
+}
+
+// trash copies the config name and its metadata to a new directory in the trash and deletes it
+// from the store.
+func (co *CO) trash(name string, now time.Time) error {
+	store := co.store()
+	data, err := store.Get(name)
+	if err != nil {
+		return err
+	}
+	md, err := store.Metadata(name)
+	if err != nil {
+		return err
+	}
+	metadata, err := yaml.Marshal(md)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata of %s: %w", name, err)
+	}
+
+	dir := path.Join(co.TrashDir(), fmt.Sprintf("%s-%s", name, now.UTC().Format(trashTimeLayout)))
+	fsys := co.filesystem()
+	if err := fsys.MkdirAll(dir, onlyOwnerAccess); err != nil {
+		return fmt.Errorf("failed to create trash directory: %w", err)
+	}
+	if err := fsys.WriteFile(path.Join(dir, name), data, 0600); err != nil {
+		return fmt.Errorf("failed to move %s to the trash: %w", name, err)
+	}
+	if err := fsys.WriteFile(path.Join(dir, name+".yaml"), metadata, 0600); err != nil {
+		return fmt.Errorf("failed to move metadata of %s to the trash: %w", name, err)
+	}
+	if err := store.Delete(name); err != nil {
+		return fmt.Errorf("failed to delete expired config %s: %w", name, err)
+	}
+	co.updateUsage(name, "", nil)
+	eslog.Debugf("Moved expired config %s to %s", name, dir)
+	return nil
+}
+
+// warnTemporary warns if the temporary config at configPath has expired or expires within
+// ExpiryWarningWindow.
+func (co *CO) warnTemporary(configPath string) {
+	name := co.storeName(configPath)
+	if name == "" {
+		return
+	}
+	md, err := co.store().Metadata(name)
+	if err != nil || md.Expires.IsZero() {
+		return
+	}
+	remaining := time.Until(md.Expires)
+	switch {
+	case remaining <= 0:
+		eslog.Warnf("Config %s has expired, it is moved to the trash on the next run", name)
+	case remaining <= ExpiryWarningWindow:
+		eslog.Warnf("Config %s expires in %s and is moved to the trash then", name, remaining.Round(time.Minute))
+	}
+}
@@ -0,0 +1,123 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.yaml.in/yaml/v3"
+)
+
+func TestCollectExpired(t *testing.T) {
+	now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+	expire := func(t *testing.T, co *CO, name string, expires time.Time) {
+		t.Helper()
+		co.ConfigName = name
+		require.NoError(t, co.SetExpiry(expires))
+	}
+
+	t.Run("Nothing expired", func(t *testing.T) {
+		_, co := initMemCO(t)
+		expire(t, co, "dev", now.Add(time.Minute))
+
+		result, err := co.CollectExpired(now)
+		require.NoError(t, err)
+		assert.Empty(t, result.Trashed)
+		assert.Equal(t, []string{"dev", "prod"}, co.Configs)
+	})
+
+	t.Run("Current config switches to previous", func(t *testing.T) {
+		setAuditIdentity(t, "alice")
+		fsys, co := initMemCO(t)
+		expire(t, co, "dev", now.Add(-time.Minute))
+
+		result, err := co.CollectExpired(now)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dev"}, result.Trashed)
+		assert.Equal(t, "prod", result.SwitchedTo)
+		assert.False(t, result.Unlinked)
+
+		target, err := fsys.Readlink("/home/.kube/config")
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/prod", target)
+		_, err = fsys.Lstat("/home/.kube/co/previous")
+		assert.Error(t, err, "the previous link is removed")
+		_, err = fsys.Stat("/home/.kube/co/dev")
+		assert.Error(t, err, "the expired config is removed from the store")
+
+		dir := "/home/.kube/co/.trash/dev-20261018T120000Z"
+		_, err = fsys.Stat(dir + "/dev")
+		assert.NoError(t, err)
+		data, err := fsys.ReadFile(dir + "/dev.yaml")
+		require.NoError(t, err)
+		md := Metadata{}
+		require.NoError(t, yaml.Unmarshal(data, &md))
+		assert.Equal(t, now.Add(-time.Minute), md.Expires)
+
+		co, err = NewCO("/home", WithFS(fsys))
+		require.NoError(t, err)
+		require.NoError(t, co.ListConfigs())
+		assert.Equal(t, []string{"prod"}, co.Configs, "the trash is not listed")
+		entries, err := co.AuditLog(AuditFilter{Action: AuditExpire})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "dev", entries[0].Config)
+		assert.Equal(t, AuditSuccess, entries[0].Outcome)
+	})
+
+	t.Run("Current config is unlinked without usable previous", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		expire(t, co, "dev", now.Add(-time.Minute))
+		expire(t, co, "prod", now.Add(-time.Hour))
+
+		result, err := co.CollectExpired(now)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dev", "prod"}, result.Trashed)
+		assert.Empty(t, result.SwitchedTo)
+		assert.True(t, result.Unlinked)
+		_, err = fsys.Lstat("/home/.kube/config")
+		assert.Error(t, err)
+		_, err = fsys.Lstat("/home/.kube/co/previous")
+		assert.Error(t, err)
+	})
+
+	t.Run("Protected previous is not switched to", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		expire(t, co, "dev", now.Add(-time.Minute))
+		co.ConfigName = "prod"
+		require.NoError(t, co.SetProtection(true, ""))
+
+		result, err := co.CollectExpired(now)
+		require.NoError(t, err)
+		assert.True(t, result.Unlinked)
+		_, err = fsys.Lstat("/home/.kube/config")
+		assert.Error(t, err)
+	})
+
+	t.Run("Previous config expired", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		expire(t, co, "prod", now)
+
+		result, err := co.CollectExpired(now)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"prod"}, result.Trashed)
+		assert.Empty(t, result.SwitchedTo)
+		assert.False(t, result.Unlinked)
+		target, err := fsys.Readlink("/home/.kube/config")
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/dev", target)
+		_, err = fsys.Lstat("/home/.kube/co/previous")
+		assert.Error(t, err)
+	})
+
+	t.Run("Permanent again", func(t *testing.T) {
+		_, co := initMemCO(t)
+		expire(t, co, "dev", now.Add(-time.Minute))
+		expire(t, co, "dev", time.Time{})
+
+		result, err := co.CollectExpired(now)
+		require.NoError(t, err)
+		assert.Empty(t, result.Trashed)
+	})
+}
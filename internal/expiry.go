@@ -0,0 +1,171 @@
+package internal
+
+import (
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/steffakasid/eslog"
+)
+
+// DefaultWarnDays is the number of days before an expiry a warning is shown.
+const DefaultWarnDays = 30
+
+// Kinds of credentials reported by Expiries.
+const (
+	ExpiryClientCertificate    = "client-certificate"
+	ExpiryCertificateAuthority = "certificate-authority"
+	ExpiryToken                = "token"
+)
+
+// Expiry is the expiry date of a credential embedded in a kubeconfig.
+type Expiry struct {
+	// Kind is one of ExpiryClientCertificate, ExpiryCertificateAuthority or ExpiryToken.
+	Kind string
+	// Name is the name of the user or cluster the credential belongs to.
+	Name     string
+	NotAfter time.Time
+}
+
+// Expired reports whether the credential is expired at now.
+func (e Expiry) Expired(now time.Time) bool {
+	return !now.Before(e.NotAfter)
+}
+
+// ExpiresWithin reports whether the credential expires within the given number of days after now.
+func (e Expiry) ExpiresWithin(days int, now time.Time) bool {
+	return e.NotAfter.Before(now.AddDate(0, 0, days))
+}
+
+// Expiries returns the expiry dates of the embedded client certificates, certificate
+// authorities and JWT bearer tokens sorted by date. Credentials which can't be decoded are
+// skipped. File references are not followed.
+func (k *KubeConfig) Expiries() []Expiry {
+	expiries := []Expiry{}
+	for _, cluster := range k.Clusters {
+		if notAfter, ok := certificateNotAfter(cluster.Cluster.CertificateAuthorityData); ok {
+			expiries = append(expiries, Expiry{Kind: ExpiryCertificateAuthority, Name: cluster.Name, NotAfter: notAfter})
+		}
+	}
+	for _, user := range k.Users {
+		if notAfter, ok := certificateNotAfter(user.User.ClientCertificateData); ok {
+			expiries = append(expiries, Expiry{Kind: ExpiryClientCertificate, Name: user.Name, NotAfter: notAfter})
+		}
+		if notAfter, ok := tokenNotAfter(user.User.Token); ok {
+			expiries = append(expiries, Expiry{Kind: ExpiryToken, Name: user.Name, NotAfter: notAfter})
+		}
+	}
+	sort.SliceStable(expiries, func(i, j int) bool {
+		return expiries[i].NotAfter.Before(expiries[j].NotAfter)
+	})
+	return expiries
+}
+
+// certificateNotAfter returns the earliest expiry of the PEM certificates in the base64
+// encoded data.
+func certificateNotAfter(data string) (time.Time, bool) {
+	if data == "" {
+		return time.Time{}, false
+	}
+	rest, err := base64.StdEncoding.DecodeString(data)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var notAfter time.Time
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			continue
+		}
+		if notAfter.IsZero() || cert.NotAfter.Before(notAfter) {
+			notAfter = cert.NotAfter
+		}
+	}
+	return notAfter, !notAfter.IsZero()
+}
+
+// tokenNotAfter returns the exp claim of a JWT. The signature is not verified.
+func tokenNotAfter(token string) (time.Time, bool) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, false
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, false
+	}
+	claims := struct {
+		Exp *json.Number `json:"exp"`
+	}{}
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.Exp == nil {
+		return time.Time{}, false
+	}
+	exp, err := claims.Exp.Float64()
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Unix(int64(exp), 0), true
+}
+
+// ConfigExpiries returns the expiries of the credentials in the config co.ConfigName.
+func (co *CO) ConfigExpiries() ([]Expiry, error) {
+	if err := validateName(co.ConfigName); err != nil {
+		return nil, err
+	}
+	data, err := co.store().Get(co.ConfigName)
+	if err != nil {
+		return nil, err
+	}
+	kubeConfig, err := ParseKubeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	return kubeConfig.Expiries(), nil
+}
+
+// warnExpiring sets co.Expiring to the credentials of the config at configPath which expire
+// within co.warnDays and logs a warning for each of them.
+func (co *CO) warnExpiring(configPath string) {
+	co.Expiring = nil
+	if co.warnDays <= 0 {
+		return
+	}
+	data, err := co.filesystem().ReadFile(configPath)
+	if err != nil {
+		eslog.Debugf("Not checking expiry of %s: %s", configPath, err)
+		return
+	}
+	kubeConfig, err := ParseKubeConfig(data)
+	if err != nil {
+		eslog.Debugf("Not checking expiry of %s: %s", configPath, err)
+		return
+	}
+	now := time.Now()
+	for _, expiry := range kubeConfig.Expiries() {
+		if !expiry.ExpiresWithin(co.warnDays, now) {
+			continue
+		}
+		co.Expiring = append(co.Expiring, expiry)
+		eslog.Warn(expiryWarning(expiry, now))
+	}
+}
+
+func expiryWarning(expiry Expiry, now time.Time) string {
+	if expiry.Expired(now) {
+		return fmt.Sprintf("The %s of %s expired on %s", expiry.Kind, expiry.Name, expiry.NotAfter.Format(time.DateOnly))
+	}
+	return fmt.Sprintf("The %s of %s expires on %s", expiry.Kind, expiry.Name, expiry.NotAfter.Format(time.DateOnly))
+}
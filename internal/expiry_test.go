@@ -0,0 +1,170 @@
+package internal
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCertificate returns a base64 encoded self-signed PEM certificate expiring at notAfter.
+func testCertificate(t *testing.T, notAfter time.Time) string {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "test"},
+		NotBefore:    notAfter.AddDate(-1, 0, 0),
+		NotAfter:     notAfter,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	return base64.StdEncoding.EncodeToString(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+}
+
+// testJWT returns an unsigned JWT with the given claims.
+func testJWT(claims string) string {
+	encode := base64.RawURLEncoding.EncodeToString
+	return encode([]byte(`{"alg":"none"}`)) + "." + encode([]byte(claims)) + "." + encode([]byte("sig"))
+}
+
+func expiringKubeConfig(ca, cert, token string) []byte {
+	return fmt.Appendf(nil, `clusters:
+- name: cluster
+  cluster:
+    server: https://localhost:6443
+    certificate-authority-data: %s
+users:
+- name: admin
+  user:
+    client-certificate-data: %s
+    client-key-data: a2V5
+- name: sa
+  user:
+    token: %s
+contexts:
+- name: admin
+  context:
+    cluster: cluster
+    user: admin
+`, ca, cert, token)
+}
+
+func TestExpiries(t *testing.T) {
+	now := time.Now().Truncate(time.Second)
+	caExpiry := now.AddDate(5, 0, 0)
+	certExpiry := now.AddDate(0, 0, 10)
+	tokenExpiry := now.Add(time.Hour)
+
+	kubeConfig, err := ParseKubeConfig(expiringKubeConfig(
+		testCertificate(t, caExpiry),
+		testCertificate(t, certExpiry),
+		testJWT(fmt.Sprintf(`{"sub":"sa","exp":%d}`, tokenExpiry.Unix())),
+	))
+	require.NoError(t, err)
+
+	expiries := kubeConfig.Expiries()
+	require.Len(t, expiries, 3)
+	assert.Equal(t, Expiry{Kind: ExpiryToken, Name: "sa", NotAfter: tokenExpiry}, expiries[0])
+	assert.Equal(t, ExpiryClientCertificate, expiries[1].Kind)
+	assert.Equal(t, "admin", expiries[1].Name)
+	assert.True(t, certExpiry.Equal(expiries[1].NotAfter))
+	assert.Equal(t, ExpiryCertificateAuthority, expiries[2].Kind)
+	assert.Equal(t, "cluster", expiries[2].Name)
+
+	assert.True(t, expiries[1].ExpiresWithin(30, now))
+	assert.False(t, expiries[1].ExpiresWithin(5, now))
+	assert.False(t, expiries[1].Expired(now))
+	assert.True(t, expiries[1].Expired(now.AddDate(0, 0, 11)))
+}
+
+func TestExpiriesSkipsUndecodable(t *testing.T) {
+	kubeConfig, err := ParseKubeConfig(expiringKubeConfig("not base64!", "bm90IGEgY2VydA==", "opaque-token"))
+	require.NoError(t, err)
+	assert.Empty(t, kubeConfig.Expiries())
+}
+
+func TestTokenNotAfter(t *testing.T) {
+	tests := map[string]struct {
+		token string
+		ok    bool
+	}{
+		"JWT":            {token: testJWT(`{"exp":1700000000}`), ok: true},
+		"Float exp":      {token: testJWT(`{"exp":1700000000.0}`), ok: true},
+		"Without exp":    {token: testJWT(`{"sub":"sa"}`), ok: false},
+		"Opaque":         {token: "abcdef", ok: false},
+		"Invalid base64": {token: "a.!!.c", ok: false},
+		"Invalid JSON":   {token: "a." + base64.RawURLEncoding.EncodeToString([]byte("{")) + ".c", ok: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			notAfter, ok := tokenNotAfter(test.token)
+			assert.Equal(t, test.ok, ok)
+			if ok {
+				assert.Equal(t, int64(1700000000), notAfter.Unix())
+			}
+		})
+	}
+}
+
+func TestConfigExpiries(t *testing.T) {
+	fsys, co := initMemCO(t)
+	certExpiry := time.Now().AddDate(0, 0, 3).Truncate(time.Second)
+	require.NoError(t, fsys.WriteFile("/home/.kube/co/prod", expiringKubeConfig("", testCertificate(t, certExpiry), ""), onlyOwnerAccess))
+
+	co.ConfigName = "prod"
+	expiries, err := co.ConfigExpiries()
+	require.NoError(t, err)
+	require.Len(t, expiries, 1)
+	assert.True(t, certExpiry.Equal(expiries[0].NotAfter))
+
+	co.ConfigName = "missing"
+	_, err = co.ConfigExpiries()
+	require.ErrorIs(t, err, ErrNotFound)
+
+	co.ConfigName = ""
+	_, err = co.ConfigExpiries()
+	require.ErrorIs(t, err, ErrInvalid)
+}
+
+func TestLinkKubeConfigWarnsExpiring(t *testing.T) {
+	coHome := "/home/.kube/co"
+	soon := testCertificate(t, time.Now().AddDate(0, 0, 3))
+	later := testCertificate(t, time.Now().AddDate(1, 0, 0))
+
+	tests := map[string]struct {
+		cert     string
+		warnDays int
+		expiring int
+	}{
+		"Expires soon":  {cert: soon, warnDays: DefaultWarnDays, expiring: 1},
+		"Expires later": {cert: later, warnDays: DefaultWarnDays, expiring: 0},
+		"Disabled":      {cert: soon, warnDays: 0, expiring: 0},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fsys, _ := initMemCO(t)
+			require.NoError(t, fsys.WriteFile(path.Join(coHome, "prod"), expiringKubeConfig("", test.cert, ""), onlyOwnerAccess))
+			co, err := NewCO("/home", WithFS(fsys), WithWarnDays(test.warnDays))
+			require.NoError(t, err)
+
+			co.ConfigName = "prod"
+			require.NoError(t, co.LinkKubeConfig())
+			assert.Len(t, co.Expiring, test.expiring)
+		})
+	}
+}
@@ -0,0 +1,68 @@
+package internal
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"time"
+)
+
+// Export writes the configs with the given names and their metadata as gzip compressed tar
+// archive to w. The archive contains the configs as <name> and the metadata as .meta/<name>.yaml
+// like the store directory.
+func (co *CO) Export(w io.Writer, names []string) error {
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	store := co.store()
+	fsys := co.filesystem()
+	now := time.Now()
+
+	for _, name := range names {
+		if err := validateName(name); err != nil {
+			return err
+		}
+		data, err := store.Get(name)
+		if err != nil {
+			return err
+		}
+		if err := writeTarFile(tw, name, data, now); err != nil {
+			return fmt.Errorf("failed to export %s: %w", name, err)
+		}
+
+		md, err := fsys.ReadFile(path.Join(co.CObasePath, metadataDirName, name+".yaml"))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return fmt.Errorf("failed to read metadata of %s: %w", name, err)
+		}
+		if err := writeTarFile(tw, path.Join(metadataDirName, name+".yaml"), md, now); err != nil {
+			return fmt.Errorf("failed to export metadata of %s: %w", name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	if err := gz.Close(); err != nil {
+		return fmt.Errorf("failed to write archive: %w", err)
+	}
+	return nil
+}
+
+func writeTarFile(tw *tar.Writer, name string, data []byte, modTime time.Time) error {
+	header := &tar.Header{
+		Name:    name,
+		Mode:    0600,
+		Size:    int64(len(data)),
+		ModTime: modTime,
+	}
+	if err := tw.WriteHeader(header); err != nil {
+		return err
+	}
+	_, err := tw.Write(data)
+	return err
+}
@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"io/fs"
+	"os"
+)
+
+// FS is the set of filesystem operations CO and the directory store rely on. It allows to run
+// CO against an in-memory filesystem (see MemFS) instead of the disk.
+type FS interface {
+	Stat(name string) (fs.FileInfo, error)
+	Lstat(name string) (fs.FileInfo, error)
+	Readlink(name string) (string, error)
+	Symlink(oldname, newname string) error
+	Remove(name string) error
+	ReadDir(name string) ([]fs.DirEntry, error)
+	ReadFile(name string) ([]byte, error)
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	// AppendFile appends data to the file name, creating it with perm if it doesn't exist.
+	AppendFile(name string, data []byte, perm fs.FileMode) error
+	// CreateFile creates the file name with data and perm. It fails with fs.ErrExist if the file
+	// exists.
+	CreateFile(name string, data []byte, perm fs.FileMode) error
+	Chmod(name string, mode fs.FileMode) error
+	Rename(oldpath, newpath string) error
+	Mkdir(name string, perm fs.FileMode) error
+	MkdirAll(name string, perm fs.FileMode) error
+}
+
+// OSFS implements FS with the functions of the os package.
+type OSFS struct{}
+
+func (OSFS) Stat(name string) (fs.FileInfo, error)  { return os.Stat(name) }
+func (OSFS) Lstat(name string) (fs.FileInfo, error) { return os.Lstat(name) }
+func (OSFS) Readlink(name string) (string, error)   { return os.Readlink(name) }
+func (OSFS) Symlink(oldname, newname string) error  { return os.Symlink(oldname, newname) }
+func (OSFS) Remove(name string) error               { return os.Remove(name) }
+func (OSFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	return os.ReadDir(name)
+}
+func (OSFS) ReadFile(name string) ([]byte, error) { return os.ReadFile(name) }
+func (OSFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return os.WriteFile(name, data, perm)
+}
+func (OSFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	return writeOSFile(name, data, os.O_WRONLY|os.O_APPEND|os.O_CREATE, perm)
+}
+func (OSFS) CreateFile(name string, data []byte, perm fs.FileMode) error {
+	return writeOSFile(name, data, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+}
+
+// writeOSFile opens the file name with flag and writes data to it.
+func writeOSFile(name string, data []byte, flag int, perm fs.FileMode) error {
+	f, err := os.OpenFile(name, flag, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
+func (OSFS) Chmod(name string, mode fs.FileMode) error    { return os.Chmod(name, mode) }
+func (OSFS) Rename(oldpath, newpath string) error         { return os.Rename(oldpath, newpath) }
+func (OSFS) Mkdir(name string, perm fs.FileMode) error    { return os.Mkdir(name, perm) }
+func (OSFS) MkdirAll(name string, perm fs.FileMode) error { return os.MkdirAll(name, perm) }
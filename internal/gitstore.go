@@ -0,0 +1,110 @@
+package internal
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os/exec"
+	"path"
+	"strings"
+)
+
+const (
+	gitUserName  = "kubectl-co"
+	gitUserEmail = "kubectl-co@localhost"
+)
+
+// gitStore is a dirStore whose base path is a git working tree. Every change is committed so
+// a team can share the configs through a remote and review the history with plain git.
+type gitStore struct {
+	dirStore
+}
+
+func (s *gitStore) Put(name string, data []byte) error {
+	if err := s.dirStore.Put(name, data); err != nil {
+		return err
+	}
+	return s.commit(fmt.Sprintf("Put %s", name))
+}
+
+func (s *gitStore) Delete(name string) error {
+	if err := s.dirStore.Delete(name); err != nil {
+		return err
+	}
+	return s.commit(fmt.Sprintf("Delete %s", name))
+}
+
+func (s *gitStore) Rename(oldName, newName string) error {
+	if err := s.dirStore.Rename(oldName, newName); err != nil {
+		return err
+	}
+	return s.commit(fmt.Sprintf("Rename %s to %s", oldName, newName))
+}
+
+func (s *gitStore) SetMetadata(name string, md Metadata) error {
+	if err := s.dirStore.SetMetadata(name, md); err != nil {
+		return err
+	}
+	return s.commit(fmt.Sprintf("Update metadata of %s", name))
+}
+
+// initRepo turns the base path into a git repository if it is none yet. The previous link is
+// local state and therefore ignored.
+func (s *gitStore) initRepo() error {
+	if _, err := s.fs.Stat(path.Join(s.basePath, ".git")); err == nil {
+		return nil
+	} else if !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to check git repository: %w", err)
+	}
+	if _, err := s.git("init", "--quiet"); err != nil {
+		return err
+	}
+	ignore := []byte(previousLinkName + "\n")
+	if err := s.fs.WriteFile(path.Join(s.basePath, ".gitignore"), ignore, 0600); err != nil {
+		return fmt.Errorf("failed to write .gitignore: %w", err)
+	}
+	return nil
+}
+
+// commit stages all changes in the working tree except the previous link, the audit logs, the
+// usage, the trash and the backups, which are kept there if the state directory is the store
+// directory, and commits them. They are excluded explicitly as a cloned store may have no
+// .gitignore. Nothing is committed if there are no changes.
+func (s *gitStore) commit(message string) error {
+	if err := s.initRepo(); err != nil {
+		return err
+	}
+	add := []string{"add", "--all", "--", "."}
+	for _, local := range []string{previousLinkName, auditLogName + "*", usageFileName, trashDirName, backupDirName} {
+		add = append(add, ":(exclude)"+local)
+	}
+	if _, err := s.git(add...); err != nil {
+		return err
+	}
+	status, err := s.git("status", "--porcelain", "--untracked-files=no")
+	if err != nil {
+		return err
+	}
+	if strings.TrimSpace(status) == "" {
+		return nil
+	}
+	args := []string{"commit", "--quiet", "--message", message}
+	if email, _ := s.git("config", "user.email"); strings.TrimSpace(email) == "" {
+		args = append([]string{"-c", "user.name=" + gitUserName, "-c", "user.email=" + gitUserEmail}, args...)
+	}
+	_, err = s.git(args...)
+	return err
+}
+
+// git runs git with the given arguments inside the base path and returns its standard output.
+func (s *gitStore) git(args ...string) (string, error) {
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", s.basePath}, args...)...)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return stdout.String(), fmt.Errorf("git %s failed: %w: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.String(), nil
+}
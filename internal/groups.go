@@ -0,0 +1,149 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"slices"
+
+	"github.com/steffakasid/eslog"
+)
+
+// DeleteGroupResult describes what DeleteGroup did.
+type DeleteGroupResult struct {
+	// Deleted are the names of the deleted configs.
+	Deleted []string
+	// SwitchedTo is the config the kube config was linked to because the current config was in
+	// the group. It is empty if the current config wasn't in the group or no config remained,
+	// see Unlinked.
+	SwitchedTo string
+	// Unlinked is set if the kube config link was removed because no config remained to switch
+	// to.
+	Unlinked bool
+}
+
+// Groups returns the stored configs per group. The config names are sorted.
+func (co *CO) Groups() (map[string][]string, error) {
+	if err := co.ListConfigs(); err != nil {
+		return nil, err
+	}
+	store := co.store()
+	groups := map[string][]string{}
+	for _, name := range co.Configs {
+		md, err := store.Metadata(name)
+		if err != nil {
+			return nil, err
+		}
+		for _, group := range md.Groups {
+			groups[group] = append(groups[group], name)
+		}
+	}
+	return groups, nil
+}
+
+// SetGroup adds the config co.ConfigName to group or removes it from group.
+func (co *CO) SetGroup(group string, member bool) error {
+	if err := ValidateGroup(group); err != nil {
+		return err
+	}
+	return co.UpdateMetadata(func(md *Metadata) error {
+		md.Groups = slices.DeleteFunc(md.Groups, func(g string) bool { return g == group })
+		if member {
+			md.Groups = append(md.Groups, group)
+			slices.Sort(md.Groups)
+		}
+		if len(md.Groups) == 0 {
+			md.Groups = nil
+		}
+		return nil
+	})
+}
+
+// DeleteGroup deletes all configs of group. Nothing is deleted unless every protected config of
+// the group is confirmed. If the kube config links to a config of the group it is first linked
+// like by LinkKubeConfig to a config outside the group: the previous config or else the first
+// remaining config. The link is removed if no config remains. A previous link to a config of the
+// group is removed. Failures to delete a config don't stop the deletion of the others. Every
+// deletion is recorded in the audit log.
+func (co *CO) DeleteGroup(group string) (DeleteGroupResult, error) {
+	result := DeleteGroupResult{}
+	if err := ValidateGroup(group); err != nil {
+		return result, err
+	}
+	groups, err := co.Groups()
+	if err != nil {
+		return result, err
+	}
+	members := groups[group]
+	if len(members) == 0 {
+		return result, fmt.Errorf("%w: group %s has no configs", ErrNotFound, group)
+	}
+	store := co.store()
+	for _, name := range members {
+		if err := co.confirmProtected(store.Path(name)); err != nil {
+			return result, err
+		}
+	}
+
+	if current := co.storeName(co.CurrentConfigPath); slices.Contains(members, current) {
+		if err := co.switchFromGroup(members, &result); err != nil {
+			return result, err
+		}
+	}
+	if previous := co.storeName(co.PreviousConifgPath); slices.Contains(members, previous) {
+		if err := co.filesystem().Remove(co.PreviousConfigLink); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return result, fmt.Errorf("failed to remove previous config link: %w", err)
+		}
+		co.PreviousConifgPath = ""
+	}
+
+	errs := []error{}
+	for _, name := range members {
+		err := store.Delete(name)
+		co.audit(AuditEntry{Action: AuditDelete, Config: name}, err)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to delete config %s: %w", name, err))
+			continue
+		}
+		co.updateUsage(name, "", nil)
+		eslog.Debugf("Deleted %s", store.Path(name))
+		result.Deleted = append(result.Deleted, name)
+	}
+	return result, errors.Join(errs...)
+}
+
+// switchFromGroup links the kube config to the previous config if it isn't a member, or else to
+// the first config which isn't. The link is removed if every config is a member.
+func (co *CO) switchFromGroup(members []string, result *DeleteGroupResult) error {
+	target := co.storeName(co.PreviousConifgPath)
+	if target == "" || slices.Contains(members, target) {
+		target = ""
+		for _, name := range co.Configs {
+			if !slices.Contains(members, name) {
+				target = name
+				break
+			}
+		}
+	}
+
+	if target == "" {
+		from := co.CurrentConfigPath
+		err := co.filesystem().Remove(co.KubeConfigPath)
+		co.audit(AuditEntry{Action: AuditLink, From: from}, err)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("failed to remove kube config link: %w", err)
+		}
+		co.CurrentConfigPath = ""
+		result.Unlinked = true
+		return nil
+	}
+	co.ConfigName = target
+	if err := co.LinkKubeConfig(); err != nil {
+		return fmt.Errorf("failed to link kube config before deletion: %w", err)
+	}
+	// LinkKubeConfig pointed the previous link to the former current config
+	co.PreviousConifgPath = co.CurrentConfigPath
+	co.CurrentConfigPath = co.store().Path(target)
+	result.SwitchedTo = target
+	return nil
+}
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"io"
+	"io/fs"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGroups(t *testing.T) {
+	_, co := initMemCO(t)
+
+	groups, err := co.Groups()
+	require.NoError(t, err)
+	assert.Empty(t, groups)
+
+	co.ConfigName = "prod"
+	require.NoError(t, co.SetGroup("customer-a", true))
+	require.NoError(t, co.SetGroup("customer-b", true))
+	require.NoError(t, co.SetGroup("customer-a", true))
+	co.ConfigName = "dev"
+	require.NoError(t, co.SetGroup("customer-a", true))
+
+	md, err := co.Metadata("prod")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"customer-a", "customer-b"}, md.Groups)
+	assert.True(t, md.InGroup("customer-b"))
+	assert.False(t, md.InGroup("customer-c"))
+
+	groups, err = co.Groups()
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]string{"customer-a": {"dev", "prod"}, "customer-b": {"prod"}}, groups)
+
+	require.NoError(t, co.SetGroup("customer-a", false))
+	md, err = co.Metadata("dev")
+	require.NoError(t, err)
+	assert.Nil(t, md.Groups)
+
+	require.ErrorIs(t, co.SetGroup("no spaces", true), ErrInvalid)
+	require.ErrorIs(t, co.SetGroup("", true), ErrInvalid)
+	co.ConfigName = "missing"
+	require.ErrorIs(t, co.SetGroup("customer-a", true), ErrNotFound)
+}
+
+func TestDeleteGroup(t *testing.T) {
+	// initMemCO links the kube config to dev and the previous link to prod
+	setup := func(t *testing.T, members ...string) (*MemFS, *CO) {
+		fsys, co := initMemCO(t)
+		require.NoError(t, fsys.WriteFile("/home/.kube/co/staging", nil, onlyOwnerAccess))
+		for _, name := range members {
+			co.ConfigName = name
+			require.NoError(t, co.SetGroup("customer-a", true))
+		}
+		return fsys, co
+	}
+
+	t.Run("Current and previous in group", func(t *testing.T) {
+		fsys, co := setup(t, "dev", "prod")
+		result, err := co.DeleteGroup("customer-a")
+		require.NoError(t, err)
+		assert.Equal(t, DeleteGroupResult{Deleted: []string{"dev", "prod"}, SwitchedTo: "staging"}, result)
+		target, err := fsys.Readlink(co.KubeConfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/staging", target)
+		_, err = fsys.Lstat(co.PreviousConfigLink)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		require.NoError(t, co.ListConfigs())
+		assert.Equal(t, []string{"staging"}, co.Configs)
+	})
+
+	t.Run("Only previous in group", func(t *testing.T) {
+		fsys, co := setup(t, "prod")
+		result, err := co.DeleteGroup("customer-a")
+		require.NoError(t, err)
+		assert.Equal(t, DeleteGroupResult{Deleted: []string{"prod"}}, result)
+		target, err := fsys.Readlink(co.KubeConfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/dev", target)
+		_, err = fsys.Lstat(co.PreviousConfigLink)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("Current in group switches to previous", func(t *testing.T) {
+		fsys, co := setup(t, "dev")
+		result, err := co.DeleteGroup("customer-a")
+		require.NoError(t, err)
+		assert.Equal(t, "prod", result.SwitchedTo)
+		target, err := fsys.Readlink(co.KubeConfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/prod", target)
+		_, err = fsys.Lstat(co.PreviousConfigLink)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("No config remains", func(t *testing.T) {
+		fsys, co := setup(t, "dev", "prod", "staging")
+		result, err := co.DeleteGroup("customer-a")
+		require.NoError(t, err)
+		assert.True(t, result.Unlinked)
+		assert.Len(t, result.Deleted, 3)
+		_, err = fsys.Lstat(co.KubeConfigPath)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = fsys.Lstat(co.PreviousConfigLink)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("Protected member not confirmed", func(t *testing.T) {
+		_, co := setup(t, "dev", "prod")
+		co.ConfigName = "prod"
+		require.NoError(t, co.SetProtection(true, ""))
+		_, err := co.DeleteGroup("customer-a")
+		require.ErrorIs(t, err, ErrProtected)
+		require.NoError(t, co.ListConfigs())
+		assert.Equal(t, []string{"dev", "prod", "staging"}, co.Configs)
+	})
+
+	t.Run("Empty group", func(t *testing.T) {
+		_, co := setup(t)
+		_, err := co.DeleteGroup("customer-a")
+		require.ErrorIs(t, err, ErrNotFound)
+		_, err = co.DeleteGroup("not valid")
+		require.ErrorIs(t, err, ErrInvalid)
+	})
+}
+
+func TestExport(t *testing.T) {
+	fsys, co := initMemCO(t)
+	require.NoError(t, fsys.WriteFile("/home/.kube/co/prod", []byte("prod-config"), onlyOwnerAccess))
+	co.ConfigName = "prod"
+	require.NoError(t, co.SetGroup("customer-a", true))
+
+	buf := &bytes.Buffer{}
+	require.NoError(t, co.Export(buf, []string{"dev", "prod"}))
+
+	gz, err := gzip.NewReader(buf)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := map[string]string{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		data, err := io.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = string(data)
+	}
+	assert.Len(t, files, 3)
+	assert.Equal(t, "", files["dev"])
+	assert.Equal(t, "prod-config", files["prod"])
+	assert.Contains(t, files[".meta/prod.yaml"], "customer-a")
+
+	require.ErrorIs(t, co.Export(io.Discard, []string{"missing"}), ErrNotFound)
+	require.ErrorIs(t, co.Export(io.Discard, []string{"../x"}), ErrInvalid)
+}
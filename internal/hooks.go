@@ -0,0 +1,183 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"os/exec"
+	"path"
+	"syscall"
+	"time"
+
+	"github.com/steffakasid/eslog"
+)
+
+// Hook names. They are also the file names of the hook scripts in the hook directory.
+const (
+	HookPreSwitch  = "pre-switch"
+	HookPostSwitch = "post-switch"
+)
+
+// DefaultHookTimeout limits the run time of a single hook.
+const DefaultHookTimeout = 30 * time.Second
+
+// hookWaitDelay is the time a hook's output is waited for after it was killed on timeout.
+const hookWaitDelay = time.Second
+
+// Hooks configures the commands run when the kube config is linked to another config.
+//
+// Pre-switch hooks run before the kube config is changed. If one fails or times out the
+// switch is aborted with ErrVetoed. Post-switch hooks run after the switch, their failures
+// are only logged. Global hooks run before the hooks of the config switched to.
+type Hooks struct {
+	// PreSwitch and PostSwitch are shell commands run for every config.
+	PreSwitch  []string
+	PostSwitch []string
+	// Configs holds the shell commands run for a single config, by config name.
+	Configs map[string]ConfigHooks
+	// Dir holds executable hook scripts: <Dir>/pre-switch and <Dir>/post-switch run for every
+	// config, <Dir>/<name>/pre-switch and <Dir>/<name>/post-switch for the config <name>.
+	Dir string
+	// Timeout limits the run time of a single hook. Defaults to DefaultHookTimeout.
+	Timeout time.Duration
+}
+
+// ConfigHooks are the hooks of a single config.
+type ConfigHooks struct {
+	PreSwitch  []string
+	PostSwitch []string
+}
+
+// WithHooks sets the hooks run by LinkKubeConfig.
+func WithHooks(hooks Hooks) Option {
+	return func(co *CO) {
+		co.hooks = hooks
+	}
+}
+
+// hook is a single command to run: a shell command or a script.
+type hook struct {
+	command string
+	script  bool
+}
+
+func (h hook) String() string {
+	return h.command
+}
+
+// hooksFor returns the hooks of the given kind for the config at configPath.
+func (co *CO) hooksFor(kind, configPath string) []hook {
+	commands := co.hooks.PreSwitch
+	configCommands := co.hooks.Configs[co.storeName(configPath)].PreSwitch
+	if kind == HookPostSwitch {
+		commands = co.hooks.PostSwitch
+		configCommands = co.hooks.Configs[co.storeName(configPath)].PostSwitch
+	}
+
+	hooks := []hook{}
+	for _, command := range commands {
+		hooks = append(hooks, hook{command: command})
+	}
+	if script, ok := co.hookScript(kind, ""); ok {
+		hooks = append(hooks, script)
+	}
+	for _, command := range configCommands {
+		hooks = append(hooks, hook{command: command})
+	}
+	if name := co.storeName(configPath); name != "" {
+		if script, ok := co.hookScript(kind, name); ok {
+			hooks = append(hooks, script)
+		}
+	}
+	return hooks
+}
+
+// hookScript returns the executable hook script of the given kind for the config name or, if
+// name is empty, for every config.
+func (co *CO) hookScript(kind, name string) (hook, bool) {
+	if co.hooks.Dir == "" {
+		return hook{}, false
+	}
+	script := path.Join(co.hooks.Dir, name, kind)
+	info, err := co.filesystem().Stat(script)
+	if errors.Is(err, fs.ErrNotExist) {
+		return hook{}, false
+	} else if err != nil {
+		eslog.Warnf("Failed to check hook %s: %s", script, err)
+		return hook{}, false
+	}
+	if info.IsDir() || info.Mode()&0111 == 0 {
+		eslog.Warnf("Ignoring hook %s as it is not executable", script)
+		return hook{}, false
+	}
+	return hook{command: script, script: true}, true
+}
+
+// storeName returns the name of the config at configPath or an empty string if the file is not
+// in the store.
+func (co *CO) storeName(configPath string) string {
+	if configPath == "" || path.Dir(configPath) != path.Clean(co.CObasePath) {
+		return ""
+	}
+	return path.Base(configPath)
+}
+
+// runHooks runs the hooks of the given kind for switching from the config at from to the
+// config at configPath. A failing pre-switch hook returns ErrVetoed, failing post-switch
+// hooks are logged.
+func (co *CO) runHooks(kind, from, configPath string) error {
+	env := append(os.Environ(),
+		"KUBECTL_CO_HOOK="+kind,
+		"KUBECTL_CO_OLD_NAME="+co.storeName(from),
+		"KUBECTL_CO_OLD_PATH="+from,
+		"KUBECTL_CO_NEW_NAME="+co.storeName(configPath),
+		"KUBECTL_CO_NEW_PATH="+configPath,
+		"KUBECTL_CO_KUBECONFIG="+co.KubeConfigPath,
+	)
+	for _, h := range co.hooksFor(kind, configPath) {
+		err := co.runHook(h, env)
+		if err == nil {
+			continue
+		}
+		if kind == HookPreSwitch {
+			return fmt.Errorf("%w: %s hook %q: %w", ErrVetoed, kind, h, err)
+		}
+		eslog.Warnf("%s hook %q failed: %s", kind, h, err)
+	}
+	return nil
+}
+
+func (co *CO) runHook(h hook, env []string) error {
+	timeout := co.hooks.Timeout
+	if timeout <= 0 {
+		timeout = DefaultHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if h.script {
+		cmd = exec.CommandContext(ctx, h.command)
+	} else {
+		cmd = exec.CommandContext(ctx, "sh", "-c", h.command)
+	}
+	cmd.Env = env
+	// the output goes to stderr so the output of kubectl-co stays parsable
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = os.Stderr
+	// on timeout the whole process group is killed, not only the shell
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+	cmd.Cancel = func() error {
+		return syscall.Kill(-cmd.Process.Pid, syscall.SIGKILL)
+	}
+	cmd.WaitDelay = hookWaitDelay
+
+	eslog.Debugf("Running hook %s", h)
+	err := cmd.Run()
+	if ctx.Err() != nil {
+		return fmt.Errorf("timed out after %s", timeout)
+	}
+	return err
+}
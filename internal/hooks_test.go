@@ -0,0 +1,77 @@
+package internal
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHooks(t *testing.T) {
+	out := path.Join(t.TempDir(), "out")
+	record := `echo "$KUBECTL_CO_HOOK $KUBECTL_CO_OLD_NAME $KUBECTL_CO_NEW_NAME $KUBECTL_CO_NEW_PATH" >> ` + out
+
+	fsys, _ := initMemCO(t)
+	co, err := NewCO("/home", WithFS(fsys), WithHooks(Hooks{
+		PreSwitch:  []string{record},
+		PostSwitch: []string{record, "exit 1"},
+		Configs: map[string]ConfigHooks{
+			"prod": {PostSwitch: []string{"echo prod >> " + out}},
+		},
+	}))
+	require.NoError(t, err)
+
+	co.ConfigName = "prod"
+	require.NoError(t, co.LinkKubeConfig(), "failing post-switch hooks don't fail the switch")
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "pre-switch dev prod /home/.kube/co/prod\npost-switch dev prod /home/.kube/co/prod\nprod\n", string(data))
+}
+
+func TestPreSwitchHookVetoes(t *testing.T) {
+	tests := map[string]Hooks{
+		"Failing hook": {PreSwitch: []string{"exit 3"}},
+		"Timeout":      {PreSwitch: []string{"sleep 5"}, Timeout: 100 * time.Millisecond},
+		"Config hook":  {Configs: map[string]ConfigHooks{"prod": {PreSwitch: []string{"false"}}}},
+	}
+
+	for name, hooks := range tests {
+		t.Run(name, func(t *testing.T) {
+			fsys, _ := initMemCO(t)
+			co, err := NewCO("/home", WithFS(fsys), WithHooks(hooks))
+			require.NoError(t, err)
+
+			co.ConfigName = "prod"
+			err = co.LinkKubeConfig()
+			require.ErrorIs(t, err, ErrVetoed)
+			assert.Equal(t, ExitError, ExitCode(err))
+
+			target, err := fsys.Readlink("/home/.kube/config")
+			require.NoError(t, err)
+			assert.Equal(t, "/home/.kube/co/dev", target)
+		})
+	}
+}
+
+func TestHookScripts(t *testing.T) {
+	co := initCO(t)
+	hooksDir := t.TempDir()
+	out := path.Join(t.TempDir(), "out")
+	require.NoError(t, os.WriteFile(path.Join(co.CObasePath, "dev"), nil, 0600))
+	require.NoError(t, os.WriteFile(path.Join(hooksDir, HookPreSwitch), []byte("#!/bin/sh\necho global >> "+out+"\n"), 0700))
+	require.NoError(t, os.Mkdir(path.Join(hooksDir, "dev"), 0700))
+	require.NoError(t, os.WriteFile(path.Join(hooksDir, "dev", HookPreSwitch), []byte("#!/bin/sh\necho \"$KUBECTL_CO_NEW_NAME\" >> "+out+"\n"), 0700))
+	require.NoError(t, os.WriteFile(path.Join(hooksDir, HookPostSwitch), []byte("not executable"), 0600))
+
+	co.hooks = Hooks{Dir: hooksDir}
+	co.ConfigName = "dev"
+	require.NoError(t, co.LinkKubeConfig())
+
+	data, err := os.ReadFile(out)
+	require.NoError(t, err)
+	assert.Equal(t, "global\ndev\n", string(data))
+}
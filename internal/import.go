@@ -0,0 +1,271 @@
+package internal
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/steffakasid/eslog"
+)
+
+// importSettleTime is the time a watched file must not change before it is imported, so
+// downloads in progress aren't read.
+const importSettleTime = 500 * time.Millisecond
+
+// partialDownloadSuffixes are the suffixes browsers add to files being downloaded.
+var partialDownloadSuffixes = []string{".crdownload", ".part", ".partial", ".download", ".tmp"}
+
+// invalidNameChars matches the characters replaced in names derived from a kubeconfig.
+var invalidNameChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// ImportResult is the outcome of importing a single file.
+type ImportResult struct {
+	// Source is the path of the imported file.
+	Source string
+	// Name is the name of the added config or, for a duplicate, of the config with the same
+	// content.
+	Name string
+	// Duplicate is set if the store already has a config with the same content and nothing
+	// was added.
+	Duplicate bool
+	// Err is set if the file couldn't be imported.
+	Err error
+}
+
+// importer adds kubeconfig files to the store, skipping the ones already stored.
+type importer struct {
+	co           *CO
+	deleteSource bool
+	// hashes maps the SHA-256 of every stored config to its name.
+	hashes map[[sha256.Size]byte]string
+}
+
+// newImporter returns an importer knowing the contents of the stored configs.
+func (co *CO) newImporter(deleteSource bool) (*importer, error) {
+	im := &importer{co: co, deleteSource: deleteSource}
+	return im, im.loadHashes()
+}
+
+// loadHashes reads the hashes of the stored configs.
+func (im *importer) loadHashes() error {
+	if err := im.co.ListConfigs(); err != nil {
+		return err
+	}
+	store := im.co.store()
+	im.hashes = map[[sha256.Size]byte]string{}
+	for _, name := range im.co.Configs {
+		data, err := store.Get(name)
+		if err != nil {
+			return err
+		}
+		im.hashes[sha256.Sum256(data)] = name
+	}
+	return nil
+}
+
+// Import adds the kubeconfig files in dir to the store. Subdirectories, hidden files, partial
+// downloads and files which aren't kubeconfigs are skipped. The names are derived from the
+// current context of the kubeconfig, see importName, and get a number appended if they are
+// taken. Files with the same content as a stored config are reported as Duplicate. With
+// deleteSource the imported and duplicate files are removed from dir. The returned error is only
+// set if dir can't be read, failed imports are reported in the Err field of their result.
+func (co *CO) Import(ctx context.Context, dir string, deleteSource bool) ([]ImportResult, error) {
+	im, err := co.newImporter(deleteSource)
+	if err != nil {
+		return nil, err
+	}
+	return im.importDir(ctx, dir)
+}
+
+func (im *importer) importDir(ctx context.Context, dir string) ([]ImportResult, error) {
+	entries, err := im.co.filesystem().ReadDir(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: directory %s does not exist", ErrNotFound, dir)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read directory %s: %w", dir, err)
+	}
+	results := []ImportResult{}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
+		}
+		if result, ok := im.importFile(ctx, path.Join(dir, entry.Name())); ok {
+			results = append(results, result)
+		}
+	}
+	return results, nil
+}
+
+// importFile imports the file at source. It returns false if the file was skipped because it
+// is hidden, still downloading or not a kubeconfig.
+func (im *importer) importFile(ctx context.Context, source string) (ImportResult, bool) {
+	base := path.Base(source)
+	if strings.HasPrefix(base, ".") || slices.ContainsFunc(partialDownloadSuffixes, func(suffix string) bool {
+		return strings.HasSuffix(base, suffix)
+	}) {
+		return ImportResult{}, false
+	}
+	fsys := im.co.filesystem()
+	info, err := fsys.Stat(source)
+	if err != nil || !info.Mode().IsRegular() || info.Size() > maxConfigSize {
+		return ImportResult{}, false
+	}
+	data, err := fsys.ReadFile(source)
+	if err != nil {
+		return ImportResult{Source: source, Err: fmt.Errorf("failed to read %s: %w", source, err)}, true
+	}
+	kubeConfig, err := ParseKubeConfig(data)
+	if err != nil || !kubeConfig.valid() {
+		eslog.Debugf("Skipping %s, it is no kubeconfig", source)
+		return ImportResult{}, false
+	}
+
+	result := ImportResult{Source: source}
+	hash := sha256.Sum256(data)
+	if name, ok := im.hashes[hash]; ok {
+		result.Name = name
+		result.Duplicate = true
+	} else {
+		result.Name, result.Err = im.uniqueName(importName(kubeConfig, base))
+		if result.Err != nil {
+			return result, true
+		}
+		im.co.ConfigName = result.Name
+		if result.Err = im.co.AddConfig(ctx, source); result.Err != nil {
+			return result, true
+		}
+		im.hashes[hash] = result.Name
+	}
+	if im.deleteSource {
+		if err := fsys.Remove(source); err != nil {
+			result.Err = fmt.Errorf("failed to delete %s: %w", source, err)
+		}
+	}
+	return result, true
+}
+
+// importName derives a config name from the current context of kubeConfig, the cluster of its
+// first context or its first cluster, in that order. Characters which aren't letters, digits,
+// dots, dashes or underscores are replaced by dashes. fileName is used if there is no name.
+func importName(kubeConfig *KubeConfig, fileName string) string {
+	name := kubeConfig.CurrentContext
+	if name == "" && len(kubeConfig.Contexts) > 0 {
+		name = kubeConfig.Contexts[0].Context.Cluster
+	}
+	if name == "" && len(kubeConfig.Clusters) > 0 {
+		name = kubeConfig.Clusters[0].Name
+	}
+	if name == "" {
+		name = strings.TrimSuffix(fileName, path.Ext(fileName))
+	}
+	name = strings.TrimLeft(invalidNameChars.ReplaceAllString(name, "-"), ".-")
+	if name == "" || name == previousLinkName {
+		name = "imported"
+	}
+	return name
+}
+
+// uniqueName returns name or, if a config or alias with that name exists or the name is
+// reserved, name with the lowest free number appended.
+func (im *importer) uniqueName(name string) (string, error) {
+	aliases, err := im.co.Aliases()
+	if err != nil {
+		return "", err
+	}
+	candidate := name
+	for i := 2; ; i++ {
+		_, isAlias := aliases[candidate]
+		reserved := slices.Contains(im.co.reservedNames, candidate)
+		_, err := im.co.filesystem().Lstat(im.co.store().Path(candidate))
+		if errors.Is(err, fs.ErrNotExist) && !isAlias && !reserved {
+			return candidate, nil
+		} else if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return "", fmt.Errorf("failed to check config %s: %w", candidate, err)
+		}
+		candidate = name + "-" + strconv.Itoa(i)
+	}
+}
+
+// WatchImports imports the kubeconfig files in dir like Import and then every file created in
+// or moved to dir, once it didn't change for a moment, until ctx is done. Every result is
+// passed to report. It needs the OS filesystem as it uses inotify or its counterparts.
+func (co *CO) WatchImports(ctx context.Context, dir string, deleteSource bool, report func(ImportResult)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create watcher: %w", err)
+	}
+	defer watcher.Close()
+	if err := watcher.Add(dir); err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return fmt.Errorf("%w: directory %s does not exist", ErrNotFound, dir)
+		}
+		return fmt.Errorf("failed to watch %s: %w", dir, err)
+	}
+
+	im, err := co.newImporter(deleteSource)
+	if err != nil {
+		return err
+	}
+	results, err := im.importDir(ctx, dir)
+	if err != nil {
+		return err
+	}
+	for _, result := range results {
+		report(result)
+	}
+
+	// changed holds the time of the last change of the files not imported yet
+	changed := map[string]time.Time{}
+	ticker := time.NewTicker(importSettleTime / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if event.Has(fsnotify.Create) || event.Has(fsnotify.Write) {
+				changed[event.Name] = time.Now()
+			} else if event.Has(fsnotify.Remove) || event.Has(fsnotify.Rename) {
+				delete(changed, event.Name)
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			eslog.Warnf("Error watching %s: %s", dir, err)
+		case now := <-ticker.C:
+			settled := []string{}
+			for name, t := range changed {
+				if now.Sub(t) >= importSettleTime {
+					settled = append(settled, name)
+				}
+			}
+			if len(settled) == 0 {
+				continue
+			}
+			// other commands may have changed the store in the meantime
+			if err := im.loadHashes(); err != nil {
+				return err
+			}
+			slices.Sort(settled)
+			for _, name := range settled {
+				delete(changed, name)
+				if result, ok := im.importFile(ctx, name); ok {
+					report(result)
+				}
+			}
+		}
+	}
+}
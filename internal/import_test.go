@@ -0,0 +1,168 @@
+package internal
+
+import (
+	"context"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func kubeConfigData(currentContext, cluster string) []byte {
+	return []byte(`apiVersion: v1
+kind: Config
+current-context: ` + currentContext + `
+clusters:
+- name: ` + cluster + `
+  cluster:
+    server: https://` + cluster + `.example.com
+contexts:
+- name: ` + currentContext + `
+  context:
+    cluster: ` + cluster + `
+    user: admin
+`)
+}
+
+func TestImport(t *testing.T) {
+	downloads := "/home/Downloads"
+
+	t.Run("Success", func(t *testing.T) {
+		setAuditIdentity(t, "alice")
+		fsys, co := initMemCO(t)
+		require.NoError(t, fsys.MkdirAll(downloads+"/nested", onlyOwnerAccess))
+		files := map[string][]byte{
+			"a1b2c3.yaml":              kubeConfigData("arn:aws:eks:eu-central-1:1:cluster/prod", "prod"),
+			"d4e5f6.yaml":              kubeConfigData("staging", "staging"),
+			"copy.yaml":                kubeConfigData("staging", "staging"),
+			"dev.yaml":                 kubeConfigData("dev", "dev"),
+			"notes.txt":                []byte("no kubeconfig"),
+			"manifest.yaml":            []byte("kind: Deployment\nmetadata:\n  name: web\n"),
+			".hidden":                  kubeConfigData("hidden", "hidden"),
+			"download.yaml.crdownload": kubeConfigData("partial", "partial"),
+			"nested/cfg.yaml":          kubeConfigData("nested", "nested"),
+		}
+		for name, data := range files {
+			require.NoError(t, fsys.WriteFile(path.Join(downloads, name), data, 0600))
+		}
+
+		results, err := co.Import(t.Context(), downloads, false)
+		require.NoError(t, err)
+		require.Len(t, results, 4)
+		assert.Equal(t, ImportResult{Source: downloads + "/a1b2c3.yaml", Name: "arn-aws-eks-eu-central-1-1-cluster-prod"}, results[0])
+		assert.Equal(t, ImportResult{Source: downloads + "/copy.yaml", Name: "staging"}, results[1])
+		assert.Equal(t, ImportResult{Source: downloads + "/d4e5f6.yaml", Name: "staging", Duplicate: true}, results[2])
+		assert.Equal(t, ImportResult{Source: downloads + "/dev.yaml", Name: "dev-2"}, results[3], "dev is taken")
+
+		data, err := fsys.ReadFile("/home/.kube/co/staging")
+		require.NoError(t, err)
+		assert.Equal(t, files["copy.yaml"], data)
+		_, err = fsys.Stat(downloads + "/copy.yaml")
+		assert.NoError(t, err, "the source is kept")
+
+		results, err = co.Import(t.Context(), downloads, false)
+		require.NoError(t, err)
+		for _, result := range results {
+			assert.True(t, result.Duplicate, result.Source)
+		}
+		entries, err := co.AuditLog(AuditFilter{Action: AuditAdd})
+		require.NoError(t, err)
+		assert.Len(t, entries, 3)
+	})
+
+	t.Run("Delete source", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		require.NoError(t, fsys.MkdirAll(downloads, onlyOwnerAccess))
+		require.NoError(t, fsys.WriteFile(downloads+"/new.yaml", kubeConfigData("new", "new"), 0600))
+		require.NoError(t, fsys.WriteFile(downloads+"/dup.yaml", nil, 0600))
+		require.NoError(t, fsys.WriteFile("/home/.kube/co/prod", kubeConfigData("prod", "prod"), 0600))
+		require.NoError(t, fsys.WriteFile(downloads+"/prod.yaml", kubeConfigData("prod", "prod"), 0600))
+
+		results, err := co.Import(t.Context(), downloads, true)
+		require.NoError(t, err)
+		require.Len(t, results, 2, "the empty file is no kubeconfig")
+		assert.Equal(t, "new", results[0].Name)
+		assert.Equal(t, "prod", results[1].Name)
+		assert.True(t, results[1].Duplicate)
+		for _, name := range []string{"new.yaml", "prod.yaml"} {
+			_, err = fsys.Stat(downloads + "/" + name)
+			assert.Error(t, err, name)
+		}
+		_, err = fsys.Stat(downloads + "/dup.yaml")
+		assert.NoError(t, err)
+	})
+
+	t.Run("Missing directory", func(t *testing.T) {
+		_, co := initMemCO(t)
+		_, err := co.Import(t.Context(), "/missing", false)
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestImportName(t *testing.T) {
+	tests := map[string]struct {
+		kubeConfig *KubeConfig
+		want       string
+	}{
+		"Current context": {&KubeConfig{CurrentContext: "admin@prod"}, "admin-prod"},
+		"Cluster of first context": {&KubeConfig{Contexts: []NamedContext{{Name: "c", Context: Context{Cluster: "gke_proj_zone_prod"}}}},
+			"gke_proj_zone_prod"},
+		"First cluster": {&KubeConfig{Clusters: []NamedCluster{{Name: "https://k8s:6443"}}}, "https-k8s-6443"},
+		"File name":     {&KubeConfig{}, "a1b2c3"},
+		"Reserved":      {&KubeConfig{CurrentContext: "previous"}, "imported"},
+		"Hidden":        {&KubeConfig{CurrentContext: "..."}, "imported"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.want, importName(test.kubeConfig, "a1b2c3.yaml"))
+		})
+	}
+}
+
+func TestWatchImports(t *testing.T) {
+	t.Setenv("KUBECONFIG", "")
+	home := t.TempDir()
+	downloads := t.TempDir()
+	require.NoError(t, os.WriteFile(path.Join(downloads, "existing.yaml"), kubeConfigData("existing", "existing"), 0600))
+	co, err := NewCO(home)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var mu sync.Mutex
+	results := []ImportResult{}
+	done := make(chan error)
+	go func() {
+		done <- co.WatchImports(ctx, downloads, true, func(result ImportResult) {
+			mu.Lock()
+			defer mu.Unlock()
+			results = append(results, result)
+		})
+	}()
+	imported := func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		names := []string{}
+		for _, result := range results {
+			names = append(names, result.Name)
+		}
+		return names
+	}
+
+	assert.Eventually(t, func() bool { return len(imported()) == 1 }, 5*time.Second, 10*time.Millisecond)
+	require.NoError(t, os.WriteFile(path.Join(downloads, "new.yaml.part"), kubeConfigData("new", "new"), 0600))
+	require.NoError(t, os.Rename(path.Join(downloads, "new.yaml.part"), path.Join(downloads, "new.yaml")))
+	assert.Eventually(t, func() bool { return len(imported()) == 2 }, 5*time.Second, 10*time.Millisecond)
+	cancel()
+	require.NoError(t, <-done)
+
+	assert.Equal(t, []string{"existing", "new"}, imported())
+	_, err = os.Stat(path.Join(home, ".kube", "co", "new"))
+	assert.NoError(t, err)
+	_, err = os.Stat(path.Join(downloads, "new.yaml"))
+	assert.Error(t, err, "the source is deleted")
+}
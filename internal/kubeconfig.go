@@ -0,0 +1,134 @@
+package internal
+
+import (
+	"encoding/base64"
+	"fmt"
+	"path"
+
+	"go.yaml.in/yaml/v3"
+)
+
+// KubeConfig is the part of a kubeconfig file kubectl-co needs to reach the clusters.
+type KubeConfig struct {
+	CurrentContext string         `yaml:"current-context"`
+	Clusters       []NamedCluster `yaml:"clusters"`
+	Users          []NamedUser    `yaml:"users"`
+	Contexts       []NamedContext `yaml:"contexts"`
+}
+
+type NamedCluster struct {
+	Name    string  `yaml:"name"`
+	Cluster Cluster `yaml:"cluster"`
+}
+
+type Cluster struct {
+	Server                   string `yaml:"server"`
+	TLSServerName            string `yaml:"tls-server-name"`
+	InsecureSkipTLSVerify    bool   `yaml:"insecure-skip-tls-verify"`
+	CertificateAuthority     string `yaml:"certificate-authority"`
+	CertificateAuthorityData string `yaml:"certificate-authority-data"`
+	ProxyURL                 string `yaml:"proxy-url"`
+}
+
+type NamedUser struct {
+	Name string   `yaml:"name"`
+	User AuthInfo `yaml:"user"`
+}
+
+// AuthInfo holds the static credentials of a user. Exec and auth provider plugins are not
+// supported.
+type AuthInfo struct {
+	ClientCertificate     string `yaml:"client-certificate"`
+	ClientCertificateData string `yaml:"client-certificate-data"`
+	ClientKey             string `yaml:"client-key"`
+	ClientKeyData         string `yaml:"client-key-data"`
+	Token                 string `yaml:"token"`
+	TokenFile             string `yaml:"tokenFile"`
+	Username              string `yaml:"username"`
+	Password              string `yaml:"password"`
+}
+
+type NamedContext struct {
+	Name    string  `yaml:"name"`
+	Context Context `yaml:"context"`
+}
+
+type Context struct {
+	Cluster   string `yaml:"cluster"`
+	User      string `yaml:"user"`
+	Namespace string `yaml:"namespace"`
+}
+
+// ParseKubeConfig parses the kubeconfig in data. Errors wrap ErrInvalid.
+func ParseKubeConfig(data []byte) (*KubeConfig, error) {
+	kubeConfig := &KubeConfig{}
+	if err := yaml.Unmarshal(data, kubeConfig); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse kubeconfig: %w", ErrInvalid, err)
+	}
+	return kubeConfig, nil
+}
+
+// valid reports whether the kubeconfig has clusters or contexts, which tells it apart from
+// other YAML files.
+func (k *KubeConfig) valid() bool {
+	return len(k.Clusters) > 0 || len(k.Contexts) > 0
+}
+
+// Cluster returns the cluster with the given name.
+func (k *KubeConfig) Cluster(name string) (Cluster, bool) {
+	for _, cluster := range k.Clusters {
+		if cluster.Name == name {
+			return cluster.Cluster, true
+		}
+	}
+	return Cluster{}, false
+}
+
+// Context returns the context with the given name.
+func (k *KubeConfig) Context(name string) (Context, bool) {
+	for _, context := range k.Contexts {
+		if context.Name == name {
+			return context.Context, true
+		}
+	}
+	return Context{}, false
+}
+
+// User returns the user with the given name.
+func (k *KubeConfig) User(name string) (AuthInfo, bool) {
+	for _, user := range k.Users {
+		if user.Name == name {
+			return user.User, true
+		}
+	}
+	return AuthInfo{}, false
+}
+
+// HasCredentials reports whether the user has static credentials to authenticate with.
+func (a AuthInfo) HasCredentials() bool {
+	return a.Token != "" || a.TokenFile != "" || a.Username != "" ||
+		((a.ClientCertificate != "" || a.ClientCertificateData != "") && (a.ClientKey != "" || a.ClientKeyData != ""))
+}
+
+// readData returns the decoded inline data or, if it is empty, the content of file. Relative
+// file paths are resolved against dir like kubectl does.
+func readData(fsys FS, dir, data, file string) ([]byte, error) {
+	if data != "" {
+		decoded, err := base64.StdEncoding.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("%w: failed to decode base64 data: %w", ErrInvalid, err)
+		}
+		return decoded, nil
+	}
+	if file == "" {
+		return nil, nil
+	}
+	if !path.IsAbs(file) {
+		file = path.Join(dir, file)
+	}
+	content, err := fsys.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", file, err)
+	}
+	return content, nil
+}
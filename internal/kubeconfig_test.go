@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKubeConfig(t *testing.T) {
+	t.Run("Clusters and users", func(t *testing.T) {
+		kubeConfig, err := ParseKubeConfig(testKubeConfig("https://localhost:6443", "Q0E=", "token"))
+		require.NoError(t, err)
+		assert.Equal(t, "test", kubeConfig.CurrentContext)
+
+		cluster, ok := kubeConfig.Cluster("test")
+		require.True(t, ok)
+		assert.Equal(t, "https://localhost:6443", cluster.Server)
+		_, ok = kubeConfig.Cluster("missing")
+		assert.False(t, ok)
+
+		user, ok := kubeConfig.User("test")
+		require.True(t, ok)
+		assert.Equal(t, "token", user.Token)
+		assert.True(t, user.HasCredentials())
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := ParseKubeConfig([]byte("clusters: ["))
+		require.ErrorIs(t, err, ErrInvalid)
+	})
+}
+
+func TestAuthInfoHasCredentials(t *testing.T) {
+	assert.False(t, AuthInfo{}.HasCredentials())
+	assert.False(t, AuthInfo{ClientCertificateData: "cert"}.HasCredentials())
+	assert.True(t, AuthInfo{ClientCertificateData: "cert", ClientKey: "key.pem"}.HasCredentials())
+	assert.True(t, AuthInfo{TokenFile: "token"}.HasCredentials())
+	assert.True(t, AuthInfo{Username: "admin"}.HasCredentials())
+}
+
+func TestReadData(t *testing.T) {
+	fsys := NewMemFS()
+	require.NoError(t, fsys.MkdirAll("/home/.kube/co", onlyOwnerAccess))
+	require.NoError(t, fsys.WriteFile("/home/.kube/co/ca.pem", []byte("file"), onlyOwnerAccess))
+
+	data, err := readData(fsys, "/home/.kube/co", "aW5saW5l", "ca.pem")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("inline"), data)
+
+	data, err = readData(fsys, "/home/.kube/co", "", "ca.pem")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("file"), data)
+
+	data, err = readData(fsys, "/other", "", "/home/.kube/co/ca.pem")
+	require.NoError(t, err)
+	assert.Equal(t, []byte("file"), data)
+
+	data, err = readData(fsys, "/home", "", "")
+	require.NoError(t, err)
+	assert.Nil(t, data)
+
+	_, err = readData(fsys, "/home", "not base64!", "")
+	require.ErrorIs(t, err, ErrInvalid)
+	_, err = readData(fsys, "/home", "", "missing.pem")
+	require.Error(t, err)
+}
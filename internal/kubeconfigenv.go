@@ -0,0 +1,223 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/steffakasid/eslog"
+)
+
+// WithKubeConfigEnv replaces the value of the KUBECONFIG environment variable read by NewCO.
+func WithKubeConfigEnv(value string) Option {
+	return func(co *CO) {
+		co.KubeConfigEnv = splitKubeConfigEnv(value)
+	}
+}
+
+// splitKubeConfigEnv splits a KUBECONFIG value into its files. Empty entries are dropped like
+// kubectl does.
+func splitKubeConfigEnv(value string) []string {
+	files := []string{}
+	for _, file := range filepath.SplitList(value) {
+		if file != "" {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// JoinKubeConfigEnv returns the KUBECONFIG value listing files.
+func JoinKubeConfigEnv(files []string) string {
+	return strings.Join(files, string(filepath.ListSeparator))
+}
+
+// kubeConfigEnvDefault returns the KUBECONFIG value of the process.
+func kubeConfigEnvDefault() []string {
+	return splitKubeConfigEnv(os.Getenv("KUBECONFIG"))
+}
+
+// KubeConfigIgnored reports whether KUBECONFIG is set and doesn't list the kube config link,
+// so kubectl doesn't read the config it links to.
+func (co *CO) KubeConfigIgnored() bool {
+	if len(co.KubeConfigEnv) == 0 {
+		return false
+	}
+	return !slices.ContainsFunc(co.KubeConfigEnv, co.isKubeConfigLink)
+}
+
+func (co *CO) isKubeConfigLink(file string) bool {
+	return path.Clean(file) == path.Clean(co.KubeConfigPath)
+}
+
+// EffectiveConfigPath returns the file kubectl takes the current context from. Without
+// KUBECONFIG it is the file the kube config links to. Otherwise it is the first file of
+// KUBECONFIG which sets a current context, as kubectl merges the files and the first value
+// wins. If none sets one it is the first existing file and if none exists it is empty. The kube
+// config link is resolved to its target.
+func (co *CO) EffectiveConfigPath() string {
+	if len(co.KubeConfigEnv) == 0 {
+		return co.CurrentConfigPath
+	}
+	effective := co.effectiveKubeConfigEnv()
+	if co.isKubeConfigLink(effective) {
+		return co.CurrentConfigPath
+	}
+	return effective
+}
+
+// effectiveKubeConfigEnv returns the file of KUBECONFIG kubectl takes the current context from.
+// The result is kept until KubeConfigEnv changes.
+func (co *CO) effectiveKubeConfigEnv() string {
+	if len(co.KubeConfigEnv) == 0 {
+		return ""
+	}
+	if co.effectiveEnv != nil && slices.Equal(co.effectiveEnv.files, co.KubeConfigEnv) {
+		return co.effectiveEnv.file
+	}
+	first := ""
+	effective := ""
+	for _, file := range co.KubeConfigEnv {
+		data, err := co.filesystem().ReadFile(file)
+		if err != nil {
+			// kubectl ignores missing files of KUBECONFIG
+			continue
+		}
+		if first == "" {
+			first = file
+		}
+		if kubeConfig, err := ParseKubeConfig(data); err == nil && kubeConfig.CurrentContext != "" {
+			effective = file
+			break
+		}
+	}
+	if effective == "" {
+		effective = first
+	}
+	co.effectiveEnv = &effectiveEnv{files: slices.Clone(co.KubeConfigEnv), file: effective}
+	return effective
+}
+
+// effectiveEnv caches the result of effectiveKubeConfigEnv for files.
+type effectiveEnv struct {
+	files []string
+	file  string
+}
+
+// warnKubeConfigIgnored warns that switching the kube config link has no effect on kubectl.
+func (co *CO) warnKubeConfigIgnored() {
+	if co.KubeConfigIgnored() {
+		eslog.Warnf("KUBECONFIG is set to %s, kubectl ignores %s. Unset KUBECONFIG or use the env mode",
+			JoinKubeConfigEnv(co.KubeConfigEnv), co.KubeConfigPath)
+	}
+}
+
+// SwitchKubeConfigEnv switches to co.ConfigName or, if it is empty, to the previous config
+// without touching the kube config link. Instead it sets KubeConfigEnv to the files the
+// KUBECONFIG environment variable has to list, which the caller has to export in the shell: the
+// selected config followed by the files of KUBECONFIG which aren't stored configs. Like
+// LinkKubeConfig it asks for confirmation of protected configs, runs the hooks, records the
+// config replaced as previous config and the time of use, warns about expiring credentials and
+// records the switch in the audit log.
+func (co *CO) SwitchKubeConfigEnv() error {
+	from := co.EffectiveConfigPath()
+	configToUse, err := co.switchKubeConfigEnv(from)
+	co.audit(AuditEntry{Action: AuditLink, Config: co.ConfigName, From: from, To: configToUse}, err)
+	return err
+}
+
+// switchKubeConfigEnv does the work of SwitchKubeConfigEnv and returns the config switched to,
+// which is empty if it couldn't be determined.
+func (co *CO) switchKubeConfigEnv(from string) (string, error) {
+	configToUse, err := co.configToUse()
+	if err != nil {
+		return "", err
+	}
+	if err := co.confirmProtected(configToUse); err != nil {
+		return configToUse, err
+	}
+	if err := co.runHooks(HookPreSwitch, from, configToUse); err != nil {
+		return configToUse, err
+	}
+
+	files := []string{configToUse}
+	for _, file := range co.KubeConfigEnv {
+		if co.storeName(path.Clean(file)) == "" && file != configToUse {
+			files = append(files, file)
+		}
+	}
+	if co.storeName(from) != "" && from != configToUse {
+		if err := co.relink(co.PreviousConfigLink, from); err != nil {
+			return configToUse, fmt.Errorf("failed to create symlink for previous config: %w", err)
+		}
+		co.PreviousConifgPath = from
+	}
+	co.KubeConfigEnv = files
+	co.touch(configToUse, false)
+	co.warnExpiring(configToUse)
+	co.warnTemporary(configToUse)
+	return configToUse, co.runHooks(HookPostSwitch, from, configToUse)
+}
+
+// MergeConfigs returns the KUBECONFIG files with the given configs appended, so kubectl merges
+// their clusters, users and contexts while the current context stays the same. Without
+// KUBECONFIG the kube config link is the first file.
+func (co *CO) MergeConfigs(names ...string) ([]string, error) {
+	files := co.KubeConfigEnv
+	if len(files) == 0 {
+		files = []string{co.KubeConfigPath}
+	}
+	files = slices.Clone(files)
+	for _, name := range names {
+		configPath, err := co.existingConfigPath(name)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(files, configPath) {
+			files = append(files, configPath)
+		}
+	}
+	return files, nil
+}
+
+// UnmergeConfigs returns the KUBECONFIG files without the given configs. The configs don't need
+// to exist anymore. An empty result or one only listing the kube config link means KUBECONFIG
+// can be unset.
+func (co *CO) UnmergeConfigs(names ...string) ([]string, error) {
+	remove := []string{}
+	for _, name := range names {
+		resolved, err := co.ResolveName(name)
+		if err != nil {
+			return nil, err
+		}
+		remove = append(remove, co.store().Path(resolved))
+	}
+	files := []string{}
+	for _, file := range co.KubeConfigEnv {
+		if !slices.Contains(remove, path.Clean(file)) {
+			files = append(files, file)
+		}
+	}
+	return files, nil
+}
+
+// existingConfigPath resolves name and returns the path of the config. It returns ErrNotFound if
+// the config doesn't exist.
+func (co *CO) existingConfigPath(name string) (string, error) {
+	resolved, err := co.ResolveName(name)
+	if err != nil {
+		return "", err
+	}
+	configPath := co.store().Path(resolved)
+	if _, err := co.filesystem().Stat(configPath); errors.Is(err, fs.ErrNotExist) {
+		return "", co.notFound(resolved)
+	} else if err != nil {
+		return "", fmt.Errorf("failed to check config file %s: %w", configPath, err)
+	}
+	return configPath, nil
+}
@@ -0,0 +1,85 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEffectiveConfigPath(t *testing.T) {
+	fsys, _ := initMemCO(t)
+	require.NoError(t, fsys.WriteFile("/home/.kube/co/ctx", []byte("current-context: admin\n"), onlyOwnerAccess))
+	require.NoError(t, fsys.Mkdir("/tmp", 0700))
+	require.NoError(t, fsys.WriteFile("/tmp/plain", nil, 0600))
+
+	tests := map[string]struct {
+		env     string
+		want    string
+		ignored bool
+	}{
+		"Unset":                  {env: "", want: "/home/.kube/co/dev"},
+		"Single file":            {env: "/tmp/plain", want: "/tmp/plain", ignored: true},
+		"First current context":  {env: "/tmp/plain:/home/.kube/co/ctx", want: "/home/.kube/co/ctx", ignored: true},
+		"First existing file":    {env: "/tmp/missing::/tmp/plain:/home/.kube/co/prod", want: "/tmp/plain", ignored: true},
+		"Kube config link":       {env: "/home/.kube/config:/tmp/plain", want: "/home/.kube/co/dev"},
+		"Link after the context": {env: "/home/.kube/co/ctx:/home/.kube/config", want: "/home/.kube/co/ctx"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			co, err := NewCO("/home", WithFS(fsys), WithKubeConfigEnv(test.env))
+			require.NoError(t, err)
+			assert.Equal(t, test.want, co.EffectiveConfigPath())
+			assert.Equal(t, test.ignored, co.KubeConfigIgnored())
+		})
+	}
+}
+
+func TestSwitchKubeConfigEnv(t *testing.T) {
+	fsys, _ := initMemCO(t)
+	require.NoError(t, fsys.Mkdir("/tmp", 0700))
+	require.NoError(t, fsys.WriteFile("/tmp/extra", nil, 0600))
+	co, err := NewCO("/home", WithFS(fsys), WithKubeConfigEnv("/home/.kube/co/dev:/tmp/extra"))
+	require.NoError(t, err)
+
+	co.ConfigName = "prod"
+	require.NoError(t, co.SwitchKubeConfigEnv())
+	assert.Equal(t, []string{"/home/.kube/co/prod", "/tmp/extra"}, co.KubeConfigEnv)
+	target, err := fsys.Readlink("/home/.kube/config")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/.kube/co/dev", target, "the kube config link is left alone")
+	target, err = fsys.Readlink("/home/.kube/co/previous")
+	require.NoError(t, err)
+	assert.Equal(t, "/home/.kube/co/dev", target)
+
+	co.ConfigName = ""
+	require.NoError(t, co.SwitchKubeConfigEnv())
+	assert.Equal(t, []string{"/home/.kube/co/dev", "/tmp/extra"}, co.KubeConfigEnv)
+
+	co.ConfigName = "missing"
+	assert.ErrorIs(t, co.SwitchKubeConfigEnv(), ErrNotFound)
+}
+
+func TestMergeConfigs(t *testing.T) {
+	fsys, co := initMemCO(t)
+
+	files, err := co.MergeConfigs("prod", "prod")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/home/.kube/config", "/home/.kube/co/prod"}, files)
+
+	_, err = co.MergeConfigs("missing")
+	assert.ErrorIs(t, err, ErrNotFound)
+
+	co, err = NewCO("/home", WithFS(fsys), WithKubeConfigEnv("/tmp/extra:/home/.kube/co/prod:/home/.kube/co/gone"))
+	require.NoError(t, err)
+	files, err = co.MergeConfigs("dev")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/tmp/extra", "/home/.kube/co/prod", "/home/.kube/co/gone", "/home/.kube/co/dev"}, files)
+
+	files, err = co.UnmergeConfigs("prod", "gone")
+	require.NoError(t, err)
+	assert.Equal(t, []string{"/tmp/extra"}, files)
+
+	_, err = co.UnmergeConfigs("../prod")
+	assert.ErrorIs(t, err, ErrInvalid)
+}
@@ -0,0 +1,434 @@
+package internal
+
+import (
+	"io/fs"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// maxSymlinkHops limits how many symlinks are followed while resolving a path.
+const maxSymlinkHops = 40
+
+// MemFS is an in-memory FS. Only absolute, slash separated paths are supported. Errors of
+// single operations can be injected with Fail and FailAfter to test error handling.
+type MemFS struct {
+	mu     sync.Mutex
+	nodes  map[string]*memNode
+	faults []*memFault
+}
+
+type memNode struct {
+	mode    fs.FileMode
+	data    []byte
+	target  string
+	modTime time.Time
+}
+
+type memFault struct {
+	op    string
+	name  string
+	after int
+	err   error
+}
+
+// NewMemFS returns an empty MemFS containing only the root directory.
+func NewMemFS() *MemFS {
+	return &MemFS{
+		nodes: map[string]*memNode{"/": {mode: fs.ModeDir | 0755, modTime: time.Now()}},
+	}
+}
+
+// Fail makes every call of the operation op (the FS method name, e.g. "Symlink") on name
+// return err. An empty name matches all paths.
+func (m *MemFS) Fail(op, name string, err error) {
+	m.FailAfter(op, name, 0, err)
+}
+
+// FailAfter lets the first n matching calls of op on name succeed and fails all further calls with err.
+func (m *MemFS) FailAfter(op, name string, n int, err error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.faults = append(m.faults, &memFault{op: op, name: clean(name), after: n, err: err})
+}
+
+func clean(name string) string {
+	if name == "" {
+		return ""
+	}
+	return path.Clean(name)
+}
+
+// fault returns the injected error for the operation, if any.
+func (m *MemFS) fault(op, name string) error {
+	name = clean(name)
+	for _, f := range m.faults {
+		if f.op != op || (f.name != "" && f.name != name) {
+			continue
+		}
+		if f.after > 0 {
+			f.after--
+			continue
+		}
+		return &fs.PathError{Op: strings.ToLower(op), Path: name, Err: f.err}
+	}
+	return nil
+}
+
+// resolve walks name and follows symlinks in every directory component. The last component is
+// only followed if followLast is set. It returns the resolved path and its node, which is nil if
+// the last component does not exist.
+func (m *MemFS) resolve(name string, followLast bool) (string, *memNode, error) {
+	current := "/"
+	parts := strings.Split(strings.TrimPrefix(path.Clean(name), "/"), "/")
+	hops := 0
+	for i := 0; i < len(parts); i++ {
+		if parts[i] == "" {
+			continue
+		}
+		next := path.Join(current, parts[i])
+		node, ok := m.nodes[next]
+		last := i == len(parts)-1
+		if !ok {
+			if last {
+				return next, nil, nil
+			}
+			return "", nil, syscall.ENOENT
+		}
+		if node.mode&fs.ModeSymlink != 0 && (!last || followLast) {
+			hops++
+			if hops > maxSymlinkHops {
+				return "", nil, syscall.ELOOP
+			}
+			target := node.target
+			if !path.IsAbs(target) {
+				target = path.Join(current, target)
+			}
+			rest := append(strings.Split(strings.TrimPrefix(path.Clean(target), "/"), "/"), parts[i+1:]...)
+			parts = rest
+			current = "/"
+			i = -1
+			continue
+		}
+		if !last && !node.mode.IsDir() {
+			return "", nil, syscall.ENOTDIR
+		}
+		current = next
+		if last {
+			return current, node, nil
+		}
+	}
+	return current, m.nodes[current], nil
+}
+
+// parentExists checks that the parent of the resolved path is a directory.
+func (m *MemFS) parentExists(resolved string) error {
+	parent, ok := m.nodes[path.Dir(resolved)]
+	if !ok {
+		return syscall.ENOENT
+	}
+	if !parent.mode.IsDir() {
+		return syscall.ENOTDIR
+	}
+	return nil
+}
+
+func (m *MemFS) stat(op, name string, followLast bool) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fault(op, name); err != nil {
+		return nil, err
+	}
+	resolved, node, err := m.resolve(name, followLast)
+	if err != nil {
+		return nil, &fs.PathError{Op: strings.ToLower(op), Path: name, Err: err}
+	}
+	if node == nil {
+		return nil, &fs.PathError{Op: strings.ToLower(op), Path: name, Err: syscall.ENOENT}
+	}
+	return &memFileInfo{name: path.Base(resolved), node: node}, nil
+}
+
+func (m *MemFS) Stat(name string) (fs.FileInfo, error) {
+	return m.stat("Stat", name, true)
+}
+
+func (m *MemFS) Lstat(name string) (fs.FileInfo, error) {
+	return m.stat("Lstat", name, false)
+}
+
+func (m *MemFS) Readlink(name string) (string, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fault("Readlink", name); err != nil {
+		return "", err
+	}
+	_, node, err := m.resolve(name, false)
+	if err == nil && node == nil {
+		err = syscall.ENOENT
+	} else if err == nil && node.mode&fs.ModeSymlink == 0 {
+		err = syscall.EINVAL
+	}
+	if err != nil {
+		return "", &fs.PathError{Op: "readlink", Path: name, Err: err}
+	}
+	return node.target, nil
+}
+
+func (m *MemFS) Symlink(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fault("Symlink", newname); err != nil {
+		return err
+	}
+	resolved, node, err := m.resolve(newname, false)
+	if err == nil && node != nil {
+		err = syscall.EEXIST
+	}
+	if err == nil {
+		err = m.parentExists(resolved)
+	}
+	if err != nil {
+		return &os.LinkError{Op: "symlink", Old: oldname, New: newname, Err: err}
+	}
+	m.nodes[resolved] = &memNode{mode: fs.ModeSymlink | 0777, target: oldname, modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fault("Remove", name); err != nil {
+		return err
+	}
+	resolved, node, err := m.resolve(name, false)
+	if err == nil && node == nil {
+		err = syscall.ENOENT
+	} else if err == nil && node.mode.IsDir() && len(m.children(resolved)) > 0 {
+		err = syscall.ENOTEMPTY
+	}
+	if err != nil {
+		return &fs.PathError{Op: "remove", Path: name, Err: err}
+	}
+	delete(m.nodes, resolved)
+	return nil
+}
+
+// children returns the direct children of the directory dir in alphabetical order.
+func (m *MemFS) children(dir string) []string {
+	names := []string{}
+	for p := range m.nodes {
+		if p != "/" && path.Dir(p) == dir {
+			names = append(names, path.Base(p))
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (m *MemFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fault("ReadDir", name); err != nil {
+		return nil, err
+	}
+	resolved, node, err := m.resolve(name, true)
+	if err == nil && node == nil {
+		err = syscall.ENOENT
+	} else if err == nil && !node.mode.IsDir() {
+		err = syscall.ENOTDIR
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	entries := []fs.DirEntry{}
+	for _, child := range m.children(resolved) {
+		info := &memFileInfo{name: child, node: m.nodes[path.Join(resolved, child)]}
+		entries = append(entries, fs.FileInfoToDirEntry(info))
+	}
+	return entries, nil
+}
+
+func (m *MemFS) ReadFile(name string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fault("ReadFile", name); err != nil {
+		return nil, err
+	}
+	_, node, err := m.resolve(name, true)
+	if err == nil && node == nil {
+		err = syscall.ENOENT
+	} else if err == nil && node.mode.IsDir() {
+		err = syscall.EISDIR
+	}
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	return append([]byte{}, node.data...), nil
+}
+
+// WriteFile creates or truncates the file. Like os.WriteFile perm is only used for new files.
+func (m *MemFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	return m.writeFile("WriteFile", name, data, perm, 0)
+}
+
+func (m *MemFS) AppendFile(name string, data []byte, perm fs.FileMode) error {
+	return m.writeFile("AppendFile", name, data, perm, os.O_APPEND)
+}
+
+func (m *MemFS) CreateFile(name string, data []byte, perm fs.FileMode) error {
+	return m.writeFile("CreateFile", name, data, perm, os.O_EXCL)
+}
+
+// writeFile replaces the data of the file name or, with os.O_APPEND, appends to it. With
+// os.O_EXCL it fails if the file exists.
+func (m *MemFS) writeFile(op, name string, data []byte, perm fs.FileMode, flag int) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fault(op, name); err != nil {
+		return err
+	}
+	resolved, node, err := m.resolve(name, true)
+	if err == nil && node != nil && flag&os.O_EXCL != 0 {
+		err = fs.ErrExist
+	}
+	if err == nil && node != nil && node.mode.IsDir() {
+		err = syscall.EISDIR
+	}
+	if err == nil && node == nil {
+		err = m.parentExists(resolved)
+	}
+	if err != nil {
+		return &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+	if node == nil {
+		node = &memNode{mode: perm.Perm()}
+		m.nodes[resolved] = node
+	}
+	if flag&os.O_APPEND != 0 {
+		node.data = append(node.data, data...)
+	} else {
+		node.data = append([]byte{}, data...)
+	}
+	node.modTime = time.Now()
+	return nil
+}
+
+func (m *MemFS) Chmod(name string, mode fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fault("Chmod", name); err != nil {
+		return err
+	}
+	_, node, err := m.resolve(name, true)
+	if err == nil && node == nil {
+		err = syscall.ENOENT
+	}
+	if err != nil {
+		return &fs.PathError{Op: "chmod", Path: name, Err: err}
+	}
+	node.mode = node.mode.Type() | mode.Perm()
+	return nil
+}
+
+// Rename moves oldpath and, for directories, everything below it to newpath.
+func (m *MemFS) Rename(oldpath, newpath string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fault("Rename", oldpath); err != nil {
+		return err
+	}
+	from, node, err := m.resolve(oldpath, false)
+	if err == nil && node == nil {
+		err = syscall.ENOENT
+	}
+	var to string
+	if err == nil {
+		to, _, err = m.resolve(newpath, false)
+	}
+	if err == nil {
+		err = m.parentExists(to)
+	}
+	if err != nil {
+		return &os.LinkError{Op: "rename", Old: oldpath, New: newpath, Err: err}
+	}
+	moved := map[string]*memNode{}
+	for p, n := range m.nodes {
+		if p == from || strings.HasPrefix(p, from+"/") {
+			moved[to+strings.TrimPrefix(p, from)] = n
+			delete(m.nodes, p)
+		}
+	}
+	for p, n := range moved {
+		m.nodes[p] = n
+	}
+	return nil
+}
+
+func (m *MemFS) Mkdir(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fault("Mkdir", name); err != nil {
+		return err
+	}
+	return m.mkdir(name, perm)
+}
+
+func (m *MemFS) mkdir(name string, perm fs.FileMode) error {
+	resolved, node, err := m.resolve(name, false)
+	if err == nil && node != nil {
+		err = syscall.EEXIST
+	}
+	if err == nil {
+		err = m.parentExists(resolved)
+	}
+	if err != nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+	}
+	m.nodes[resolved] = &memNode{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()}
+	return nil
+}
+
+func (m *MemFS) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if err := m.fault("MkdirAll", name); err != nil {
+		return err
+	}
+	current := "/"
+	for _, part := range strings.Split(strings.TrimPrefix(path.Clean(name), "/"), "/") {
+		if part == "" {
+			continue
+		}
+		current = path.Join(current, part)
+		_, node, err := m.resolve(current, true)
+		if err != nil {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: err}
+		}
+		if node == nil {
+			if err := m.mkdir(current, perm); err != nil {
+				return err
+			}
+		} else if !node.mode.IsDir() {
+			return &fs.PathError{Op: "mkdir", Path: name, Err: syscall.ENOTDIR}
+		}
+	}
+	return nil
+}
+
+// memFileInfo implements fs.FileInfo for a memNode.
+type memFileInfo struct {
+	name string
+	node *memNode
+}
+
+func (i *memFileInfo) Name() string       { return i.name }
+func (i *memFileInfo) Size() int64        { return int64(len(i.node.data)) }
+func (i *memFileInfo) Mode() fs.FileMode  { return i.node.mode }
+func (i *memFileInfo) ModTime() time.Time { return i.node.modTime }
+func (i *memFileInfo) IsDir() bool        { return i.node.mode.IsDir() }
+func (i *memFileInfo) Sys() any           { return nil }
@@ -0,0 +1,161 @@
+package internal
+
+import (
+	"io/fs"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMemFS(t *testing.T) {
+	t.Run("Files and directories", func(t *testing.T) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.MkdirAll("/a/b", 0700))
+		require.NoError(t, fsys.WriteFile("/a/b/file", []byte("content"), 0600))
+
+		fi, err := fsys.Stat("/a/b")
+		require.NoError(t, err)
+		assert.True(t, fi.IsDir())
+
+		got, err := fsys.ReadFile("/a/b/file")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("content"), got)
+
+		err = fsys.Mkdir("/a/b", 0700)
+		require.ErrorIs(t, err, fs.ErrExist)
+		err = fsys.WriteFile("/missing/file", nil, 0600)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("WriteFile keeps mode", func(t *testing.T) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.WriteFile("/file", nil, 0644))
+		require.NoError(t, fsys.WriteFile("/file", []byte("new"), 0600))
+
+		fi, err := fsys.Stat("/file")
+		require.NoError(t, err)
+		assert.Equal(t, fs.FileMode(0644), fi.Mode())
+		require.NoError(t, fsys.Chmod("/file", 0600))
+		fi, err = fsys.Stat("/file")
+		require.NoError(t, err)
+		assert.Equal(t, fs.FileMode(0600), fi.Mode())
+	})
+
+	t.Run("AppendFile", func(t *testing.T) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.AppendFile("/file", []byte("a"), 0600))
+		require.NoError(t, fsys.AppendFile("/file", []byte("b"), 0644))
+
+		got, err := fsys.ReadFile("/file")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("ab"), got)
+		fi, err := fsys.Stat("/file")
+		require.NoError(t, err)
+		assert.Equal(t, fs.FileMode(0600), fi.Mode())
+		err = fsys.AppendFile("/missing/file", nil, 0600)
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("CreateFile", func(t *testing.T) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.CreateFile("/file", []byte("a"), 0600))
+		require.ErrorIs(t, fsys.CreateFile("/file", []byte("b"), 0600), fs.ErrExist)
+
+		got, err := fsys.ReadFile("/file")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("a"), got)
+	})
+
+	t.Run("Symlinks", func(t *testing.T) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.MkdirAll("/dir", 0700))
+		require.NoError(t, fsys.WriteFile("/dir/target", []byte("content"), 0600))
+		require.NoError(t, fsys.Symlink("/dir/target", "/link"))
+		require.NoError(t, fsys.Symlink("dir", "/dirlink"))
+
+		target, err := fsys.Readlink("/link")
+		require.NoError(t, err)
+		assert.Equal(t, "/dir/target", target)
+
+		fi, err := fsys.Lstat("/link")
+		require.NoError(t, err)
+		assert.NotZero(t, fi.Mode()&fs.ModeSymlink)
+
+		got, err := fsys.ReadFile("/dirlink/target")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("content"), got)
+
+		err = fsys.Symlink("/dir/target", "/link")
+		require.ErrorIs(t, err, fs.ErrExist)
+		_, err = fsys.Readlink("/dir/target")
+		require.ErrorIs(t, err, syscall.EINVAL)
+
+		require.NoError(t, fsys.Remove("/dir/target"))
+		_, err = fsys.Stat("/link")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = fsys.Lstat("/link")
+		require.NoError(t, err)
+	})
+
+	t.Run("Symlink loop", func(t *testing.T) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.Symlink("/b", "/a"))
+		require.NoError(t, fsys.Symlink("/a", "/b"))
+
+		_, err := fsys.Stat("/a")
+		require.ErrorIs(t, err, syscall.ELOOP)
+	})
+
+	t.Run("Remove", func(t *testing.T) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.MkdirAll("/dir", 0700))
+		require.NoError(t, fsys.WriteFile("/dir/file", nil, 0600))
+
+		require.ErrorIs(t, fsys.Remove("/dir"), syscall.ENOTEMPTY)
+		require.NoError(t, fsys.Remove("/dir/file"))
+		require.NoError(t, fsys.Remove("/dir"))
+		require.ErrorIs(t, fsys.Remove("/dir"), fs.ErrNotExist)
+	})
+
+	t.Run("ReadDir", func(t *testing.T) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.MkdirAll("/dir/sub", 0700))
+		require.NoError(t, fsys.WriteFile("/dir/b", nil, 0600))
+		require.NoError(t, fsys.WriteFile("/dir/a", nil, 0600))
+
+		entries, err := fsys.ReadDir("/dir")
+		require.NoError(t, err)
+		names := []string{}
+		for _, entry := range entries {
+			names = append(names, entry.Name())
+		}
+		assert.Equal(t, []string{"a", "b", "sub"}, names)
+		assert.True(t, entries[2].IsDir())
+	})
+
+	t.Run("Rename directory", func(t *testing.T) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.MkdirAll("/old/sub", 0700))
+		require.NoError(t, fsys.WriteFile("/old/sub/file", []byte("content"), 0600))
+
+		require.NoError(t, fsys.Rename("/old", "/new"))
+		got, err := fsys.ReadFile("/new/sub/file")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("content"), got)
+		_, err = fsys.Stat("/old")
+		require.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("Faults", func(t *testing.T) {
+		fsys := NewMemFS()
+		fsys.FailAfter("WriteFile", "/file", 1, syscall.EACCES)
+		fsys.Fail("Mkdir", "", syscall.EROFS)
+
+		require.NoError(t, fsys.WriteFile("/file", nil, 0600))
+		require.ErrorIs(t, fsys.WriteFile("/file", nil, 0600), syscall.EACCES)
+		require.NoError(t, fsys.WriteFile("/other", nil, 0600))
+		require.ErrorIs(t, fsys.Mkdir("/dir", 0700), syscall.EROFS)
+	})
+}
@@ -0,0 +1,241 @@
+package internal
+
+import (
+	"fmt"
+	"maps"
+	"path"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/steffakasid/eslog"
+)
+
+// EnvironmentLabel is the label used as environment of configs without an explicit environment.
+const EnvironmentLabel = "env"
+
+var (
+	labelKeyPattern     = regexp.MustCompile(`^([a-zA-Z0-9]([-a-zA-Z0-9_.]*[a-zA-Z0-9])?/)?[a-zA-Z0-9]([-a-zA-Z0-9_.]*[a-zA-Z0-9])?$`)
+	labelValuePattern   = regexp.MustCompile(`^([a-zA-Z0-9]([-a-zA-Z0-9_.]*[a-zA-Z0-9])?)?$`)
+	variableNamePattern = regexp.MustCompile(`^[a-zA-Z_][a-zA-Z0-9_]*$`)
+)
+
+// Env returns the environment of the config. If no environment is set the env label is used.
+func (md Metadata) Env() string {
+	if md.Environment != "" {
+		return md.Environment
+	}
+	return md.Labels[EnvironmentLabel]
+}
+
+// SelectorLabels returns the labels selectors are matched against. The env label defaults to
+// the environment.
+func (md Metadata) SelectorLabels() map[string]string {
+	labels := maps.Clone(md.Labels)
+	if labels == nil {
+		labels = map[string]string{}
+	}
+	if _, ok := labels[EnvironmentLabel]; !ok && md.Environment != "" {
+		labels[EnvironmentLabel] = md.Environment
+	}
+	return labels
+}
+
+// Validate checks the label and annotation keys, the label values, the group names, the
+// aliases and the variable names. Errors wrap ErrInvalid.
+func (md Metadata) Validate() error {
+	for _, group := range md.Groups {
+		if err := ValidateGroup(group); err != nil {
+			return err
+		}
+	}
+	for _, alias := range md.Aliases {
+		if err := validateName(alias); err != nil {
+			return fmt.Errorf("alias: %w", err)
+		}
+	}
+	for key, value := range md.Labels {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("%w: label key %q", ErrInvalid, key)
+		}
+		if !labelValuePattern.MatchString(value) {
+			return fmt.Errorf("%w: value %q of label %s", ErrInvalid, value, key)
+		}
+	}
+	for key := range md.Annotations {
+		if !labelKeyPattern.MatchString(key) {
+			return fmt.Errorf("%w: annotation key %q", ErrInvalid, key)
+		}
+	}
+	for name := range md.Variables {
+		if !variableNamePattern.MatchString(name) {
+			return fmt.Errorf("%w: variable name %q", ErrInvalid, name)
+		}
+	}
+	return nil
+}
+
+// ValidateGroup checks the name of a group. Errors wrap ErrInvalid.
+func ValidateGroup(group string) error {
+	if group == "" || !labelValuePattern.MatchString(group) {
+		return fmt.Errorf("%w: group name %q", ErrInvalid, group)
+	}
+	return nil
+}
+
+// InGroup reports whether the config belongs to group.
+func (md Metadata) InGroup(group string) bool {
+	return slices.Contains(md.Groups, group)
+}
+
+// UpdateMetadata calls update with the metadata of the config co.ConfigName and stores the
+// result if it is valid. The change is recorded in the audit log.
+func (co *CO) UpdateMetadata(update func(md *Metadata) error) error {
+	err := co.updateMetadata(co.ConfigName, update)
+	co.audit(AuditEntry{Action: AuditEdit, Config: co.ConfigName}, err)
+	return err
+}
+
+func (co *CO) updateMetadata(name string, update func(md *Metadata) error) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	store := co.store()
+	md, err := store.Metadata(name)
+	if err != nil {
+		return err
+	}
+	if !md.LastUsed.IsZero() || md.SwitchCount != 0 {
+		// the store drops the usage kept in the metadata by older versions
+		co.updateUsage(name, name, func(*usage) {})
+	}
+	if err := update(&md); err != nil {
+		return err
+	}
+	if err := md.Validate(); err != nil {
+		return err
+	}
+	if err := store.SetMetadata(name, md); err != nil {
+		return fmt.Errorf("failed to update metadata of %s: %w", name, err)
+	}
+	return nil
+}
+
+// touch records the creation of the config at configPath in its metadata or, unless
+// setCreated is set, the time of use and the switch in the usage file. Failures are only logged
+// as the usage is informational.
+func (co *CO) touch(configPath string, setCreated bool) {
+	if path.Dir(configPath) != path.Clean(co.CObasePath) {
+		return
+	}
+	name := path.Base(configPath)
+	now := time.Now().UTC().Truncate(time.Second)
+	if !setCreated {
+		co.updateUsage(name, name, func(u *usage) {
+			u.LastUsed = now
+			u.SwitchCount++
+		})
+		return
+	}
+	err := co.updateMetadata(name, func(md *Metadata) error {
+		if md.Created.IsZero() {
+			md.Created = now
+		}
+		return nil
+	})
+	if err != nil {
+		eslog.Warnf("Failed to record timestamps of %s: %s", configPath, err)
+	}
+}
+
+// Selector filters configs by their labels. It is parsed from a comma separated list of
+// requirements: key=value, key==value, key!=value, key (the label exists) and !key (it doesn't).
+type Selector []requirement
+
+type requirement struct {
+	key    string
+	value  string
+	negate bool
+	exists bool
+}
+
+// ParseSelector parses a selector like "env=prod,team!=payments". An empty selector matches
+// everything. Errors wrap ErrInvalid.
+func ParseSelector(selector string) (Selector, error) {
+	parsed := Selector{}
+	for part := range strings.SplitSeq(selector, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		req := requirement{}
+		switch {
+		case strings.Contains(part, "!="):
+			req.key, req.value, _ = strings.Cut(part, "!=")
+			req.negate = true
+		case strings.Contains(part, "=="):
+			req.key, req.value, _ = strings.Cut(part, "==")
+		case strings.Contains(part, "="):
+			req.key, req.value, _ = strings.Cut(part, "=")
+		case strings.HasPrefix(part, "!"):
+			req.key, req.exists, req.negate = part[1:], true, true
+		default:
+			req.key, req.exists = part, true
+		}
+		req.key, req.value = strings.TrimSpace(req.key), strings.TrimSpace(req.value)
+		if !labelKeyPattern.MatchString(req.key) || !labelValuePattern.MatchString(req.value) {
+			return nil, fmt.Errorf("%w: selector %q", ErrInvalid, part)
+		}
+		parsed = append(parsed, req)
+	}
+	return parsed, nil
+}
+
+// Matches reports whether labels fulfill all requirements of the selector.
+func (s Selector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		value, ok := labels[req.key]
+		matches := ok
+		if !req.exists {
+			matches = ok && value == req.value
+		}
+		if matches == req.negate {
+			return false
+		}
+	}
+	return true
+}
+
+// FormatLabels returns the labels as sorted, comma separated key=value pairs.
+func FormatLabels(labels map[string]string) string {
+	pairs := make([]string, 0, len(labels))
+	for key, value := range labels {
+		pairs = append(pairs, key+"="+value)
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, ",")
+}
+
+// ParseLabelChanges parses kubectl style label arguments. key=value sets a label, key- removes
+// it. Errors wrap ErrInvalid.
+func ParseLabelChanges(args []string) (map[string]string, []string, error) {
+	set := map[string]string{}
+	remove := []string{}
+	for _, arg := range args {
+		if key, value, ok := strings.Cut(arg, "="); ok {
+			set[key] = value
+		} else if key, ok := strings.CutSuffix(arg, "-"); ok && key != "" {
+			remove = append(remove, key)
+		} else {
+			return nil, nil, fmt.Errorf("%w: %q must be key=value or key-", ErrInvalid, arg)
+		}
+	}
+	for _, key := range remove {
+		if _, ok := set[key]; ok {
+			return nil, nil, fmt.Errorf("%w: %s is set and removed", ErrInvalid, key)
+		}
+	}
+	return set, remove, nil
+}
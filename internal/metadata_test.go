@@ -0,0 +1,155 @@
+package internal
+
+import (
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMetadataEnv(t *testing.T) {
+	assert.Equal(t, "", Metadata{}.Env())
+	assert.Equal(t, "prod", Metadata{Labels: map[string]string{"env": "prod"}}.Env())
+	assert.Equal(t, "live", Metadata{Environment: "live", Labels: map[string]string{"env": "prod"}}.Env())
+
+	assert.Equal(t, map[string]string{}, Metadata{}.SelectorLabels())
+	assert.Equal(t, map[string]string{"env": "live", "team": "ops"}, Metadata{Environment: "live", Labels: map[string]string{"team": "ops"}}.SelectorLabels())
+	assert.Equal(t, map[string]string{"env": "prod"}, Metadata{Environment: "live", Labels: map[string]string{"env": "prod"}}.SelectorLabels())
+}
+
+func TestMetadataValidate(t *testing.T) {
+	tests := map[string]struct {
+		md      Metadata
+		wantErr bool
+	}{
+		"Empty":              {md: Metadata{}},
+		"Valid":              {md: Metadata{Labels: map[string]string{"env": "prod", "example.com/team": "pay-ments", "empty": ""}, Annotations: map[string]string{"note": "any value, really"}}},
+		"Label key space":    {md: Metadata{Labels: map[string]string{"my key": "x"}}, wantErr: true},
+		"Label value comma":  {md: Metadata{Labels: map[string]string{"env": "a,b"}}, wantErr: true},
+		"Label key equals":   {md: Metadata{Labels: map[string]string{"a=b": "x"}}, wantErr: true},
+		"Annotation key bad": {md: Metadata{Annotations: map[string]string{"-x": "y"}}, wantErr: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			err := test.md.Validate()
+			if test.wantErr {
+				require.ErrorIs(t, err, ErrInvalid)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestSelector(t *testing.T) {
+	labels := map[string]string{"env": "prod", "team": "payments"}
+	tests := map[string]bool{
+		"":                        true,
+		"env=prod":                true,
+		"env==prod":               true,
+		"env=dev":                 false,
+		"env!=dev":                true,
+		"env!=prod":               false,
+		"team":                    true,
+		"owner":                   false,
+		"!owner":                  true,
+		"!team":                   false,
+		"env=prod, team=payments": true,
+		"env=prod,team=ops":       false,
+		"owner!=me":               true,
+	}
+
+	for selector, want := range tests {
+		t.Run(selector, func(t *testing.T) {
+			parsed, err := ParseSelector(selector)
+			require.NoError(t, err)
+			assert.Equal(t, want, parsed.Matches(labels))
+		})
+	}
+
+	for _, selector := range []string{"=prod", "env=a b", "!", "env in (a,b)"} {
+		_, err := ParseSelector(selector)
+		require.ErrorIs(t, err, ErrInvalid, selector)
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	assert.Equal(t, "", FormatLabels(nil))
+	assert.Equal(t, "env=prod,team=ops", FormatLabels(map[string]string{"team": "ops", "env": "prod"}))
+}
+
+func TestParseLabelChanges(t *testing.T) {
+	set, remove, err := ParseLabelChanges([]string{"env=prod", "team-", "empty="})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "prod", "empty": ""}, set)
+	assert.Equal(t, []string{"team"}, remove)
+
+	for _, args := range [][]string{{"env"}, {"-"}, {"env=prod", "env-"}} {
+		_, _, err := ParseLabelChanges(args)
+		require.ErrorIs(t, err, ErrInvalid, args)
+	}
+}
+
+func TestUpdateMetadata(t *testing.T) {
+	_, co := initMemCO(t)
+	co.ConfigName = "dev"
+	require.NoError(t, co.UpdateMetadata(func(md *Metadata) error {
+		md.Description = "Development"
+		md.Labels = map[string]string{"env": "dev"}
+		return nil
+	}))
+	md, err := co.Metadata("dev")
+	require.NoError(t, err)
+	assert.Equal(t, "Development", md.Description)
+
+	require.ErrorIs(t, co.UpdateMetadata(func(md *Metadata) error {
+		md.Labels["bad key"] = "x"
+		return nil
+	}), ErrInvalid)
+
+	boom := errors.New("boom")
+	require.ErrorIs(t, co.UpdateMetadata(func(md *Metadata) error { return boom }), boom)
+
+	md, err = co.Metadata("dev")
+	require.NoError(t, err)
+	assert.Equal(t, map[string]string{"env": "dev"}, md.Labels)
+
+	co.ConfigName = "missing"
+	require.ErrorIs(t, co.UpdateMetadata(func(md *Metadata) error { return nil }), ErrNotFound)
+}
+
+func TestTimestamps(t *testing.T) {
+	fsys, co := initMemCO(t)
+	before := time.Now().Add(-time.Second)
+
+	co.ConfigName = "new"
+	require.NoError(t, co.AddConfig(t.Context(), ""))
+	md, err := co.Metadata("new")
+	require.NoError(t, err)
+	assert.True(t, md.Created.After(before))
+	assert.True(t, md.LastUsed.IsZero())
+	created := md.Created
+
+	require.NoError(t, co.LinkKubeConfig())
+	md, err = co.Metadata("new")
+	require.NoError(t, err)
+	assert.Equal(t, created, md.Created)
+	assert.True(t, md.LastUsed.After(before))
+
+	t.Run("Overwrite is refused", func(t *testing.T) {
+		require.ErrorIs(t, co.AddConfig(t.Context(), ""), ErrExists)
+		md, err := co.Metadata("new")
+		require.NoError(t, err)
+		assert.Equal(t, created, md.Created)
+	})
+
+	t.Run("Failing metadata doesn't fail the switch", func(t *testing.T) {
+		fsys.Fail("WriteFile", "/home/.kube/co/.meta/prod.yaml", syscall.EACCES)
+		co.ConfigName = "prod"
+		require.NoError(t, co.LinkKubeConfig())
+	})
+}
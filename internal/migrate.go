@@ -0,0 +1,252 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/steffakasid/eslog"
+)
+
+// Migrate moves the configs, their metadata and the other contents of the store directory from
+// to the store directory of co and the previous link, the audit logs, the usage, the trash and the backups
+// to the state directory. The kube config and previous links pointing into from are pointed to
+// the new location and from is removed if it is empty afterwards. Nothing is moved if an entry
+// already exists at the new location (ErrExists). Entries which can't be renamed, e.g. because
+// the new location is on another filesystem, are copied and removed. If an entry can't be moved
+// or a link can't be relinked, the entries moved so far are moved back and the kube config and
+// previous links are restored. It returns the names of the moved entries.
+func (co *CO) Migrate(from string) ([]string, error) {
+	from = path.Clean(from)
+	if from == path.Clean(co.CObasePath) {
+		return nil, fmt.Errorf("%w: %s is already the store directory, configure another one to migrate to", ErrInvalid, from)
+	}
+	fsys := co.filesystem()
+	entries, err := fsys.ReadDir(from)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: store directory %s does not exist", ErrNotFound, from)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read store directory %s: %w", from, err)
+	}
+
+	oldPrevious := path.Join(from, previousLinkName)
+	previous, err := co.linkTarget(oldPrevious)
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("failed to read previous config link: %w", err)
+	}
+
+	names := []string{}
+	targets := map[string]string{}
+	for _, entry := range entries {
+		if entry.Name() == previousLinkName {
+			continue
+		}
+		target := path.Join(co.CObasePath, entry.Name())
+		if strings.HasPrefix(entry.Name(), auditLogName) || entry.Name() == usageFileName || entry.Name() == trashDirName || entry.Name() == backupDirName {
+			target = path.Join(co.StateDir, entry.Name())
+		}
+		if target == path.Join(from, entry.Name()) {
+			continue
+		}
+		if _, err := fsys.Lstat(target); err == nil {
+			return nil, fmt.Errorf("%w: %s", ErrExists, target)
+		}
+		names = append(names, entry.Name())
+		targets[entry.Name()] = target
+	}
+
+	links, err := co.saveLinks(co.KubeConfigPath, oldPrevious, co.PreviousConfigLink)
+	if err != nil {
+		return nil, err
+	}
+	currentPath := co.CurrentConfigPath
+	rollback := func(moved []string) {
+		co.rollbackMigration(from, moved, targets, links)
+		co.CurrentConfigPath = currentPath
+	}
+
+	for i, name := range names {
+		if err := moveEntry(fsys, path.Join(from, name), targets[name]); err != nil {
+			rollback(names[:i])
+			return nil, fmt.Errorf("failed to move %s: %w", name, err)
+		}
+	}
+
+	if co.CurrentConfigPath != "" && path.Dir(co.CurrentConfigPath) == from {
+		newPath := path.Join(co.CObasePath, path.Base(co.CurrentConfigPath))
+		if err := co.relink(co.KubeConfigPath, newPath); err != nil {
+			rollback(names)
+			return nil, fmt.Errorf("failed to relink kube config: %w", err)
+		}
+		co.CurrentConfigPath = newPath
+	}
+	if previous != "" {
+		if path.Dir(previous) == from {
+			previous = path.Join(co.CObasePath, path.Base(previous))
+		}
+		if err := fsys.Remove(oldPrevious); err != nil {
+			rollback(names)
+			return nil, fmt.Errorf("failed to remove previous config link: %w", err)
+		}
+		if err := co.relink(co.PreviousConfigLink, previous); err != nil {
+			rollback(names)
+			return nil, fmt.Errorf("failed to relink previous config: %w", err)
+		}
+		co.PreviousConifgPath = previous
+	}
+
+	if err := fsys.Remove(from); err != nil {
+		eslog.Debugf("Keeping %s: %s", from, err)
+	}
+	return names, nil
+}
+
+// savedLink is a link as it was before a migration. target is empty if the link didn't exist.
+type savedLink struct {
+	link   string
+	target string
+}
+
+// saveLinks records the stored targets of the symbolic links links. Missing links are recorded
+// as well, regular files are skipped since a migration doesn't touch them.
+func (co *CO) saveLinks(links ...string) ([]savedLink, error) {
+	saved := []savedLink{}
+	for _, link := range links {
+		if slices.ContainsFunc(saved, func(s savedLink) bool { return s.link == link }) {
+			continue
+		}
+		fi, err := co.filesystem().Lstat(link)
+		if errors.Is(err, fs.ErrNotExist) {
+			saved = append(saved, savedLink{link: link})
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to read link %s: %w", link, err)
+		} else if fi.Mode()&fs.ModeSymlink == 0 {
+			continue
+		}
+		target, err := co.filesystem().Readlink(link)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read link %s: %w", link, err)
+		}
+		saved = append(saved, savedLink{link: link, target: target})
+	}
+	return saved, nil
+}
+
+// rollbackMigration moves the entries names back from their targets to from and restores the
+// links saved before the migration. Failures are only logged, the error which caused the
+// rollback is more relevant.
+func (co *CO) rollbackMigration(from string, names []string, targets map[string]string, links []savedLink) {
+	for i := len(names) - 1; i >= 0; i-- {
+		if err := moveEntry(co.filesystem(), targets[names[i]], path.Join(from, names[i])); err != nil {
+			eslog.Warnf("Failed to move %s back to %s: %s", targets[names[i]], from, err)
+		}
+	}
+	for _, link := range links {
+		if err := co.restoreLink(link); err != nil {
+			eslog.Warnf("Failed to restore link %s: %s", link.link, err)
+		}
+	}
+}
+
+// restoreLink points link back to its saved target or removes it if it didn't exist. The link
+// is created next to it and renamed over it, so it isn't lost if creating it fails.
+func (co *CO) restoreLink(link savedLink) error {
+	fsys := co.filesystem()
+	if link.target == "" {
+		if err := fsys.Remove(link.link); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return err
+		}
+		return nil
+	}
+	tmp := link.link + ".restore"
+	if err := fsys.Remove(tmp); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	if err := fsys.Symlink(link.target, tmp); err != nil {
+		return err
+	}
+	return fsys.Rename(tmp, link.link)
+}
+
+// moveEntry renames src to dst. If that fails, e.g. with EXDEV because dst is on another
+// filesystem, src is copied to dst and removed afterwards. A partial copy is removed again, the
+// complete copy is kept if src can't be removed completely.
+func moveEntry(fsys FS, src, dst string) error {
+	renameErr := fsys.Rename(src, dst)
+	if renameErr == nil {
+		return nil
+	}
+	eslog.Debugf("Copying %s to %s: %s", src, dst, renameErr)
+	if err := copyTree(fsys, src, dst); err != nil {
+		if _, statErr := fsys.Lstat(dst); statErr == nil {
+			if rmErr := removeTree(fsys, dst); rmErr != nil {
+				eslog.Warnf("Failed to remove partial copy %s: %s", dst, rmErr)
+			}
+		}
+		return errors.Join(renameErr, err)
+	}
+	if err := removeTree(fsys, src); err != nil {
+		eslog.Warnf("Failed to remove %s after copying it to %s: %s", src, dst, err)
+	}
+	return nil
+}
+
+// copyTree copies the file, symbolic link or directory src with everything below it to dst.
+func copyTree(fsys FS, src, dst string) error {
+	info, err := fsys.Lstat(src)
+	if err != nil {
+		return err
+	}
+	switch {
+	case info.Mode()&fs.ModeSymlink != 0:
+		target, err := fsys.Readlink(src)
+		if err != nil {
+			return err
+		}
+		return fsys.Symlink(target, dst)
+	case info.IsDir():
+		if err := fsys.Mkdir(dst, info.Mode().Perm()); err != nil {
+			return err
+		}
+		entries, err := fsys.ReadDir(src)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := copyTree(fsys, path.Join(src, entry.Name()), path.Join(dst, entry.Name())); err != nil {
+				return err
+			}
+		}
+		return nil
+	default:
+		data, err := fsys.ReadFile(src)
+		if err != nil {
+			return err
+		}
+		return fsys.CreateFile(dst, data, info.Mode().Perm())
+	}
+}
+
+// removeTree removes name and, for directories, everything below it.
+func removeTree(fsys FS, name string) error {
+	info, err := fsys.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.IsDir() {
+		entries, err := fsys.ReadDir(name)
+		if err != nil {
+			return err
+		}
+		for _, entry := range entries {
+			if err := removeTree(fsys, path.Join(name, entry.Name())); err != nil {
+				return err
+			}
+		}
+	}
+	return fsys.Remove(name)
+}
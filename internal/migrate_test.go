@@ -0,0 +1,162 @@
+package internal
+
+import (
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	storeDir := "/home/.local/share/kubectl-co"
+	stateDir := "/home/.local/state/kubectl-co"
+
+	t.Run("Success", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "dev"
+		require.NoError(t, co.UpdateMetadata(func(md *Metadata) error {
+			md.Description = "development"
+			return nil
+		}))
+
+		co, err := NewCO("/home", WithFS(fsys), WithStoreDir(storeDir), WithStateDir(stateDir))
+		require.NoError(t, err)
+		assert.Equal(t, stateDir+"/previous", co.PreviousConfigLink)
+		moved, err := co.Migrate("/home/.kube/co")
+		require.NoError(t, err)
+		assert.Equal(t, []string{".audit.log", ".meta", "dev", "prod"}, moved)
+		_, err = fsys.Stat(stateDir + "/.audit.log")
+		assert.NoError(t, err, "the audit log is moved to the state directory")
+
+		target, err := fsys.Readlink("/home/.kube/config")
+		require.NoError(t, err)
+		assert.Equal(t, storeDir+"/dev", target)
+		target, err = fsys.Readlink(stateDir + "/previous")
+		require.NoError(t, err)
+		assert.Equal(t, storeDir+"/prod", target)
+		_, err = fsys.Lstat("/home/.kube/co")
+		assert.Error(t, err, "the old store directory is removed")
+
+		co, err = NewCO("/home", WithFS(fsys), WithStoreDir(storeDir), WithStateDir(stateDir))
+		require.NoError(t, err)
+		require.NoError(t, co.ListConfigs())
+		assert.Equal(t, []string{"dev", "prod"}, co.Configs)
+		md, err := co.Metadata("dev")
+		require.NoError(t, err)
+		assert.Equal(t, "development", md.Description)
+		assert.Equal(t, storeDir+"/prod", co.PreviousConifgPath)
+	})
+
+	t.Run("Existing config", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		require.NoError(t, fsys.MkdirAll(storeDir, onlyOwnerAccess))
+		require.NoError(t, fsys.WriteFile(storeDir+"/prod", nil, onlyOwnerAccess))
+		co, err := NewCO("/home", WithFS(fsys), WithStoreDir(storeDir))
+		require.NoError(t, err)
+		_, err = co.Migrate("/home/.kube/co")
+		assert.ErrorIs(t, err, ErrExists)
+		_, err = fsys.Lstat("/home/.kube/co/dev")
+		assert.NoError(t, err, "nothing is moved")
+	})
+
+	t.Run("Same directory", func(t *testing.T) {
+		_, co := initMemCO(t)
+		_, err := co.Migrate("/home/.kube/co/")
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+
+	t.Run("Missing directory", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		co, err := NewCO("/home", WithFS(fsys), WithStoreDir(storeDir))
+		require.NoError(t, err)
+		_, err = co.Migrate("/home/old")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestMigrateAcrossFilesystems(t *testing.T) {
+	storeDir := "/home/.local/share/kubectl-co"
+	stateDir := "/home/.local/state/kubectl-co"
+
+	t.Run("Entries are copied", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		co.ConfigName = "dev"
+		require.NoError(t, co.UpdateMetadata(func(md *Metadata) error {
+			md.Description = "development"
+			return nil
+		}))
+		fsys.Fail("Rename", "", syscall.EXDEV)
+
+		co, err := NewCO("/home", WithFS(fsys), WithStoreDir(storeDir), WithStateDir(stateDir))
+		require.NoError(t, err)
+		moved, err := co.Migrate("/home/.kube/co")
+		require.NoError(t, err)
+		assert.Equal(t, []string{".audit.log", ".meta", "dev", "prod"}, moved)
+		_, err = fsys.Lstat("/home/.kube/co")
+		assert.Error(t, err, "the old store directory is removed")
+
+		target, err := fsys.Readlink("/home/.kube/config")
+		require.NoError(t, err)
+		assert.Equal(t, storeDir+"/dev", target)
+		md, err := co.Metadata("dev")
+		require.NoError(t, err)
+		assert.Equal(t, "development", md.Description)
+	})
+
+	t.Run("Failed copy is rolled back", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		fsys.Fail("Rename", "/home/.kube/co/prod", syscall.EXDEV)
+		fsys.Fail("CreateFile", storeDir+"/prod", syscall.ENOSPC)
+
+		co, err := NewCO("/home", WithFS(fsys), WithStoreDir(storeDir), WithStateDir(stateDir))
+		require.NoError(t, err)
+		_, err = co.Migrate("/home/.kube/co")
+		require.ErrorIs(t, err, syscall.ENOSPC)
+
+		for _, name := range []string{"dev", "prod"} {
+			_, err = fsys.Lstat("/home/.kube/co/" + name)
+			assert.NoError(t, err, "%s is moved back", name)
+		}
+		entries, err := fsys.ReadDir(storeDir)
+		require.NoError(t, err)
+		assert.Empty(t, entries, "nothing is left in the new store directory")
+		target, err := fsys.Readlink("/home/.kube/config")
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/dev", target)
+	})
+}
+
+func TestMigrateRelinkFailure(t *testing.T) {
+	storeDir := "/home/.local/share/kubectl-co"
+	stateDir := "/home/.local/state/kubectl-co"
+
+	for name, link := range map[string]string{
+		"Kube config":   "/home/.kube/config",
+		"Previous link": stateDir + "/previous",
+	} {
+		t.Run(name, func(t *testing.T) {
+			fsys, _ := initMemCO(t)
+			fsys.FailAfter("Symlink", link, 0, syscall.EACCES)
+
+			co, err := NewCO("/home", WithFS(fsys), WithStoreDir(storeDir), WithStateDir(stateDir))
+			require.NoError(t, err)
+			_, err = co.Migrate("/home/.kube/co")
+			require.ErrorIs(t, err, syscall.EACCES)
+			assert.Equal(t, "/home/.kube/co/dev", co.CurrentConfigPath)
+
+			target, err := fsys.Readlink("/home/.kube/config")
+			require.NoError(t, err, "the kube config link is restored")
+			assert.Equal(t, "/home/.kube/co/dev", target)
+			target, err = fsys.Readlink("/home/.kube/co/previous")
+			require.NoError(t, err, "the previous link is restored")
+			assert.Equal(t, "/home/.kube/co/prod", target)
+			_, err = fsys.Lstat(stateDir + "/previous")
+			assert.Error(t, err, "no previous link is left in the state directory")
+			for _, name := range []string{"dev", "prod"} {
+				_, err = fsys.Lstat("/home/.kube/co/" + name)
+				assert.NoError(t, err, "%s is moved back", name)
+			}
+		})
+	}
+}
@@ -0,0 +1,143 @@
+package internal
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"time"
+
+	"github.com/steffakasid/eslog"
+)
+
+// PromptInfo describes the current config for a shell prompt.
+type PromptInfo struct {
+	// Name is the name of the current config. It is empty if the kube config is not linked to
+	// a stored config.
+	Name      string `json:"name"`
+	Path      string `json:"path"`
+	Context   string `json:"context"`
+	Namespace string `json:"namespace"`
+	// Environment and Color are taken from the metadata, see Metadata.Env.
+	Environment string `json:"environment"`
+	Color       string `json:"color"`
+	Protected   bool   `json:"protected"`
+	// Expiry is the credential of the current context expiring first. It is nil if the
+	// context has no embedded credentials with an expiry date.
+	Expiry *Expiry `json:"expiry"`
+}
+
+// promptCache is the cached PromptInfo of a config. It is valid as long as the kube config
+// links to the same file and neither the file nor its metadata changed.
+type promptCache struct {
+	Path             string     `json:"path"`
+	ConfigModTime    time.Time  `json:"configModTime"`
+	ConfigSize       int64      `json:"configSize"`
+	MetadataModTime  time.Time  `json:"metadataModTime"`
+	Info             PromptInfo `json:"info"`
+	ProtectedFlagSet bool       `json:"protectedFlagSet"`
+}
+
+// promptCachePath returns the path of the prompt cache next to the kubectl caches.
+func (co *CO) promptCachePath() string {
+	return path.Join(path.Dir(co.KubeConfigPath), "cache", "kubectl-co", "prompt.json")
+}
+
+// PromptInfo returns the information about the current config shown in a shell prompt. It is
+// meant to run on every prompt: the result is cached and only recomputed if the kube config
+// links to another file or the config or its metadata changed.
+func (co *CO) PromptInfo() (PromptInfo, error) {
+	configPath := co.EffectiveConfigPath()
+	name := co.storeName(configPath)
+	if name == "" {
+		return PromptInfo{Path: configPath}, nil
+	}
+
+	fsys := co.filesystem()
+	configInfo, err := fsys.Stat(configPath)
+	if err != nil {
+		return PromptInfo{}, fmt.Errorf("failed to read %s: %w", configPath, err)
+	}
+	key := promptCache{Path: configPath, ConfigModTime: configInfo.ModTime(), ConfigSize: configInfo.Size()}
+	if metadataInfo, err := fsys.Stat(path.Join(co.CObasePath, metadataDirName, name+".yaml")); err == nil {
+		key.MetadataModTime = metadataInfo.ModTime()
+	}
+
+	cache, ok := co.readPromptCache(key)
+	if !ok {
+		cache, err = co.promptCache(key, name)
+		if err != nil {
+			return PromptInfo{}, err
+		}
+		co.writePromptCache(cache)
+	}
+	info := cache.Info
+	info.Protected = cache.ProtectedFlagSet || (info.Environment != "" && slices.Contains(co.protectedEnvironments, info.Environment))
+	return info, nil
+}
+
+// promptCache computes the PromptInfo of the config name for the cache key.
+func (co *CO) promptCache(key promptCache, name string) (promptCache, error) {
+	md, err := co.store().Metadata(name)
+	if err != nil {
+		return promptCache{}, err
+	}
+	data, err := co.filesystem().ReadFile(key.Path)
+	if err != nil {
+		return promptCache{}, fmt.Errorf("failed to read %s: %w", key.Path, err)
+	}
+	kubeConfig, err := ParseKubeConfig(data)
+	if err != nil {
+		return promptCache{}, err
+	}
+
+	key.ProtectedFlagSet = md.Protected
+	key.Info = PromptInfo{
+		Name:        name,
+		Path:        key.Path,
+		Context:     kubeConfig.CurrentContext,
+		Environment: md.Env(),
+		Color:       md.Color,
+	}
+	context, _ := kubeConfig.Context(kubeConfig.CurrentContext)
+	key.Info.Namespace = context.Namespace
+	for _, expiry := range kubeConfig.Expiries() {
+		if (expiry.Kind == ExpiryCertificateAuthority && expiry.Name == context.Cluster) ||
+			(expiry.Kind != ExpiryCertificateAuthority && expiry.Name == context.User) {
+			key.Info.Expiry = &expiry
+			break
+		}
+	}
+	return key, nil
+}
+
+func (co *CO) readPromptCache(key promptCache) (promptCache, bool) {
+	data, err := co.filesystem().ReadFile(co.promptCachePath())
+	if err != nil {
+		return promptCache{}, false
+	}
+	cache := promptCache{}
+	if err := json.Unmarshal(data, &cache); err != nil {
+		eslog.Debugf("Ignoring invalid prompt cache: %s", err)
+		return promptCache{}, false
+	}
+	valid := cache.Path == key.Path && cache.ConfigSize == key.ConfigSize &&
+		cache.ConfigModTime.Equal(key.ConfigModTime) && cache.MetadataModTime.Equal(key.MetadataModTime)
+	return cache, valid
+}
+
+// writePromptCache stores the cache. Failures are only logged as the cache is an optimization.
+func (co *CO) writePromptCache(cache promptCache) {
+	fsys := co.filesystem()
+	cachePath := co.promptCachePath()
+	data, err := json.Marshal(cache)
+	if err == nil {
+		err = fsys.MkdirAll(path.Dir(cachePath), onlyOwnerAccess)
+	}
+	if err == nil {
+		err = fsys.WriteFile(cachePath, data, 0600)
+	}
+	if err != nil {
+		eslog.Debugf("Failed to write prompt cache: %s", err)
+	}
+}
@@ -0,0 +1,93 @@
+package internal
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPromptInfo(t *testing.T) {
+	fsys, co := initMemCO(t)
+	certExpiry := time.Now().AddDate(0, 0, 3).Truncate(time.Second)
+	kubeConfig := `current-context: admin
+clusters:
+- name: cluster
+  cluster:
+    server: https://localhost:6443
+users:
+- name: admin
+  user:
+    client-certificate-data: ` + testCertificate(t, certExpiry) + `
+- name: other
+  user:
+    client-certificate-data: ` + testCertificate(t, time.Now().AddDate(0, 0, 1)) + `
+contexts:
+- name: admin
+  context:
+    cluster: cluster
+    user: admin
+    namespace: payments
+`
+	require.NoError(t, fsys.WriteFile("/home/.kube/co/dev", []byte(kubeConfig), onlyOwnerAccess))
+	co.ConfigName = "dev"
+	require.NoError(t, co.UpdateMetadata(func(md *Metadata) error {
+		md.Environment = "prod"
+		md.Color = "cyan"
+		return nil
+	}))
+
+	info, err := co.PromptInfo()
+	require.NoError(t, err)
+	assert.Equal(t, "dev", info.Name)
+	assert.Equal(t, "/home/.kube/co/dev", info.Path)
+	assert.Equal(t, "admin", info.Context)
+	assert.Equal(t, "payments", info.Namespace)
+	assert.Equal(t, "prod", info.Environment)
+	assert.Equal(t, "cyan", info.Color)
+	assert.True(t, info.Protected)
+	require.NotNil(t, info.Expiry)
+	assert.Equal(t, "admin", info.Expiry.Name)
+	assert.True(t, certExpiry.Equal(info.Expiry.NotAfter))
+
+	t.Run("Cached", func(t *testing.T) {
+		data, err := fsys.ReadFile(co.promptCachePath())
+		require.NoError(t, err)
+		cache := promptCache{}
+		require.NoError(t, json.Unmarshal(data, &cache))
+		cache.Info.Namespace = "from-cache"
+		data, err = json.Marshal(cache)
+		require.NoError(t, err)
+		require.NoError(t, fsys.WriteFile(co.promptCachePath(), data, 0600))
+
+		info, err := co.PromptInfo()
+		require.NoError(t, err)
+		assert.Equal(t, "from-cache", info.Namespace)
+
+		time.Sleep(time.Millisecond)
+		require.NoError(t, co.UpdateMetadata(func(md *Metadata) error {
+			md.Environment = "dev"
+			return nil
+		}))
+		info, err = co.PromptInfo()
+		require.NoError(t, err)
+		assert.Equal(t, "payments", info.Namespace)
+		assert.False(t, info.Protected)
+	})
+
+	t.Run("Invalid cache", func(t *testing.T) {
+		require.NoError(t, fsys.WriteFile(co.promptCachePath(), []byte("{"), 0600))
+		info, err := co.PromptInfo()
+		require.NoError(t, err)
+		assert.Equal(t, "payments", info.Namespace)
+	})
+
+	t.Run("Not linked to a stored config", func(t *testing.T) {
+		co.CurrentConfigPath = "/elsewhere/config"
+		info, err := co.PromptInfo()
+		require.NoError(t, err)
+		assert.Equal(t, PromptInfo{Path: "/elsewhere/config"}, info)
+	})
+}
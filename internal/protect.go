@@ -0,0 +1,82 @@
+package internal
+
+import (
+	"fmt"
+	"path"
+	"slices"
+)
+
+// DefaultProtectedEnvironments are the environments whose configs are protected unless
+// WithProtectedEnvironments is used.
+var DefaultProtectedEnvironments = []string{"prod", "production"}
+
+// WithProtectedEnvironments sets the environments whose configs are protected. Without
+// environments only configs with the protected flag are protected.
+func WithProtectedEnvironments(environments ...string) Option {
+	return func(co *CO) {
+		co.protectedEnvironments = environments
+	}
+}
+
+// WithConfirm sets the function asked before switching to or deleting a protected config.
+// Returning false aborts with ErrProtected. Without it protected configs can't be switched
+// to or deleted.
+func WithConfirm(confirm func(name string) bool) Option {
+	return func(co *CO) {
+		co.confirm = confirm
+	}
+}
+
+// IsProtected reports whether the config with the given name is protected, either by its
+// protected flag or by its environment (see Metadata.Env).
+func (co *CO) IsProtected(name string) (bool, error) {
+	md, err := co.store().Metadata(name)
+	if err != nil {
+		return false, err
+	}
+	return md.Protected || (md.Env() != "" && slices.Contains(co.protectedEnvironments, md.Env())), nil
+}
+
+// SetProtection sets the protected flag of the config co.ConfigName. If environment is not
+// empty the environment is set as well.
+func (co *CO) SetProtection(protected bool, environment string) error {
+	err := co.setProtection(protected, environment)
+	co.audit(AuditEntry{Action: AuditEdit, Config: co.ConfigName}, err)
+	return err
+}
+
+func (co *CO) setProtection(protected bool, environment string) error {
+	if err := validateName(co.ConfigName); err != nil {
+		return err
+	}
+	store := co.store()
+	md, err := store.Metadata(co.ConfigName)
+	if err != nil {
+		return err
+	}
+	md.Protected = protected
+	if environment != "" {
+		md.Environment = environment
+	}
+	if err := store.SetMetadata(co.ConfigName, md); err != nil {
+		return fmt.Errorf("failed to set protection of %s: %w", co.ConfigName, err)
+	}
+	return nil
+}
+
+// confirmProtected asks for confirmation if configPath is a protected config of the store.
+// Files outside the store are never protected.
+func (co *CO) confirmProtected(configPath string) error {
+	if path.Dir(configPath) != path.Clean(co.CObasePath) {
+		return nil
+	}
+	name := path.Base(configPath)
+	protected, err := co.IsProtected(name)
+	if err != nil {
+		return fmt.Errorf("failed to check protection of %s: %w", name, err)
+	}
+	if protected && (co.confirm == nil || !co.confirm(name)) {
+		return fmt.Errorf("%w: %s was not confirmed", ErrProtected, name)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsProtected(t *testing.T) {
+	tests := map[string]struct {
+		md           Metadata
+		environments []string
+		want         bool
+	}{
+		"Unprotected":            {md: Metadata{}, environments: DefaultProtectedEnvironments, want: false},
+		"Protected flag":         {md: Metadata{Protected: true}, environments: DefaultProtectedEnvironments, want: true},
+		"Protected environment":  {md: Metadata{Environment: "prod"}, environments: DefaultProtectedEnvironments, want: true},
+		"Other environment":      {md: Metadata{Environment: "dev"}, environments: DefaultProtectedEnvironments, want: false},
+		"Configured environment": {md: Metadata{Environment: "staging"}, environments: []string{"staging"}, want: true},
+		"No environments":        {md: Metadata{Environment: "prod"}, environments: nil, want: false},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			fsys, _ := initMemCO(t)
+			co, err := NewCO("/home", WithFS(fsys), WithProtectedEnvironments(test.environments...))
+			require.NoError(t, err)
+			require.NoError(t, co.store().SetMetadata("prod", test.md))
+
+			protected, err := co.IsProtected("prod")
+			require.NoError(t, err)
+			assert.Equal(t, test.want, protected)
+		})
+	}
+}
+
+func TestSetProtection(t *testing.T) {
+	_, co := initMemCO(t)
+	require.NoError(t, co.store().SetMetadata("prod", Metadata{Annotations: map[string]string{"team": "ops"}}))
+
+	co.ConfigName = "prod"
+	require.NoError(t, co.SetProtection(true, "prod"))
+	md, err := co.store().Metadata("prod")
+	require.NoError(t, err)
+	assert.Equal(t, Metadata{Annotations: map[string]string{"team": "ops"}, Protected: true, Environment: "prod"}, md)
+
+	require.NoError(t, co.SetProtection(false, ""))
+	md, err = co.store().Metadata("prod")
+	require.NoError(t, err)
+	assert.False(t, md.Protected)
+	assert.Equal(t, "prod", md.Environment)
+
+	co.ConfigName = "missing"
+	require.ErrorIs(t, co.SetProtection(true, ""), ErrNotFound)
+	co.ConfigName = "../prod"
+	require.ErrorIs(t, co.SetProtection(true, ""), ErrInvalid)
+}
+
+func TestProtectedSwitchAndDelete(t *testing.T) {
+	newCO := func(t *testing.T, confirm func(string) bool) (*MemFS, *CO) {
+		fsys, _ := initMemCO(t)
+		co, err := NewCO("/home", WithFS(fsys), WithConfirm(confirm))
+		require.NoError(t, err)
+		require.NoError(t, co.store().SetMetadata("prod", Metadata{Protected: true}))
+		return fsys, co
+	}
+
+	t.Run("Switch confirmed", func(t *testing.T) {
+		asked := []string{}
+		fsys, co := newCO(t, func(name string) bool {
+			asked = append(asked, name)
+			return true
+		})
+		co.ConfigName = "prod"
+		require.NoError(t, co.LinkKubeConfig())
+		assert.Equal(t, []string{"prod"}, asked)
+		target, err := fsys.Readlink(co.KubeConfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/prod", target)
+	})
+
+	t.Run("Switch declined", func(t *testing.T) {
+		fsys, co := newCO(t, func(string) bool { return false })
+		co.ConfigName = "prod"
+		require.ErrorIs(t, co.LinkKubeConfig(), ErrProtected)
+		target, err := fsys.Readlink(co.KubeConfigPath)
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/dev", target)
+	})
+
+	t.Run("Switch without confirm", func(t *testing.T) {
+		_, co := newCO(t, nil)
+		co.ConfigName = "prod"
+		require.ErrorIs(t, co.LinkKubeConfig(), ErrProtected)
+	})
+
+	t.Run("Previous is protected", func(t *testing.T) {
+		_, co := newCO(t, nil)
+		require.ErrorIs(t, co.LinkKubeConfig(), ErrProtected)
+	})
+
+	t.Run("Unprotected needs no confirmation", func(t *testing.T) {
+		_, co := newCO(t, nil)
+		co.ConfigName = "dev"
+		require.NoError(t, co.LinkKubeConfig())
+	})
+
+	t.Run("Delete declined", func(t *testing.T) {
+		fsys, co := newCO(t, func(string) bool { return false })
+		co.ConfigName = "prod"
+		require.ErrorIs(t, co.DeleteConfig(), ErrProtected)
+		_, err := fsys.Stat("/home/.kube/co/prod")
+		require.NoError(t, err)
+	})
+
+	t.Run("Delete confirmed", func(t *testing.T) {
+		fsys, co := newCO(t, func(string) bool { return true })
+		co.ConfigName = "prod"
+		require.NoError(t, co.LinkKubeConfig())
+		co.ConfigName = "prod"
+		co.CurrentConfigPath = "/home/.kube/co/prod"
+		co.PreviousConifgPath = "/home/.kube/co/dev"
+		require.NoError(t, co.DeleteConfig())
+		_, err := fsys.Stat("/home/.kube/co/prod")
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,94 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+)
+
+// Relink rewrites the kube config link, the previous link and the links in the store directory
+// in place, so they point to their targets relative to their directory if relative is set and
+// with absolute paths otherwise. A kube config or previous link to a missing file is pointed to
+// the config of the same name in the store, which repairs links of a home directory restored to
+// another path. It returns the paths of the rewritten links.
+func (co *CO) Relink(relative bool) ([]string, error) {
+	rewritten := []string{}
+	for _, link := range []string{co.KubeConfigPath, co.PreviousConfigLink} {
+		target, changed, err := co.relinkOne(link, relative, true)
+		if err != nil {
+			return rewritten, err
+		}
+		switch link {
+		case co.KubeConfigPath:
+			if target != "" {
+				co.CurrentConfigPath = target
+			}
+		case co.PreviousConfigLink:
+			if target != "" {
+				co.PreviousConifgPath = target
+			}
+		}
+		if changed {
+			rewritten = append(rewritten, link)
+		}
+	}
+
+	entries, err := co.filesystem().ReadDir(co.CObasePath)
+	if err != nil {
+		return rewritten, fmt.Errorf("failed to read store directory %s: %w", co.CObasePath, err)
+	}
+	for _, entry := range entries {
+		link := path.Join(co.CObasePath, entry.Name())
+		if entry.Type()&fs.ModeSymlink == 0 || link == co.PreviousConfigLink {
+			continue
+		}
+		_, changed, err := co.relinkOne(link, relative, false)
+		if err != nil {
+			return rewritten, err
+		}
+		if changed {
+			rewritten = append(rewritten, link)
+		}
+	}
+	return rewritten, nil
+}
+
+// relinkOne rewrites link if its target isn't stored in the requested form. With repair a
+// target which doesn't exist is replaced by the config of the same name in the store if that
+// exists. It returns the resolved target, which is empty if link isn't a symlink, and whether
+// link was rewritten.
+func (co *CO) relinkOne(link string, relative, repair bool) (string, bool, error) {
+	fi, err := co.filesystem().Lstat(link)
+	if errors.Is(err, fs.ErrNotExist) || (err == nil && fi.Mode()&fs.ModeSymlink == 0) {
+		return "", false, nil
+	} else if err != nil {
+		return "", false, fmt.Errorf("failed to read link %s: %w", link, err)
+	}
+	stored, err := co.filesystem().Readlink(link)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read link %s: %w", link, err)
+	}
+	target, err := co.linkTarget(link)
+	if err != nil {
+		return "", false, fmt.Errorf("failed to read link %s: %w", link, err)
+	}
+
+	if _, err := co.filesystem().Stat(target); repair && errors.Is(err, fs.ErrNotExist) {
+		moved := co.store().Path(path.Base(target))
+		if _, err := co.filesystem().Stat(moved); err == nil {
+			target = moved
+		}
+	}
+	want := linkPath(link, target, relative)
+	if want == stored {
+		return target, false, nil
+	}
+	if err := co.filesystem().Remove(link); err != nil {
+		return "", false, fmt.Errorf("failed to rewrite link %s: %w", link, err)
+	}
+	if err := co.filesystem().Symlink(want, link); err != nil {
+		return "", false, fmt.Errorf("failed to rewrite link %s: %w", link, err)
+	}
+	return target, true, nil
+}
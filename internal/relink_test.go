@@ -0,0 +1,71 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRelativeLinks(t *testing.T) {
+	fsys, _ := initMemCO(t)
+	co, err := NewCO("/home", WithFS(fsys), WithRelativeLinks(true))
+	require.NoError(t, err)
+
+	co.ConfigName = "prod"
+	require.NoError(t, co.LinkKubeConfig())
+	target, err := fsys.Readlink("/home/.kube/config")
+	require.NoError(t, err)
+	assert.Equal(t, "co/prod", target)
+	target, err = fsys.Readlink("/home/.kube/co/previous")
+	require.NoError(t, err)
+	assert.Equal(t, "dev", target)
+
+	co, err = NewCO("/home", WithFS(fsys))
+	require.NoError(t, err)
+	assert.Equal(t, "/home/.kube/co/prod", co.CurrentConfigPath, "relative links are resolved")
+	assert.Equal(t, "/home/.kube/co/dev", co.PreviousConifgPath)
+}
+
+func TestRelink(t *testing.T) {
+	t.Run("Relative and back", func(t *testing.T) {
+		fsys, co := initMemCO(t)
+		require.NoError(t, fsys.Mkdir("/tmp", 0700))
+		require.NoError(t, fsys.WriteFile("/tmp/shared", nil, 0600))
+		require.NoError(t, fsys.Symlink("/tmp/shared", "/home/.kube/co/shared"))
+
+		rewritten, err := co.Relink(true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/home/.kube/config", "/home/.kube/co/previous", "/home/.kube/co/shared"}, rewritten)
+		target, err := fsys.Readlink("/home/.kube/co/shared")
+		require.NoError(t, err)
+		assert.Equal(t, "../../../tmp/shared", target)
+
+		rewritten, err = co.Relink(true)
+		require.NoError(t, err)
+		assert.Empty(t, rewritten, "relative links are left alone")
+
+		rewritten, err = co.Relink(false)
+		require.NoError(t, err)
+		assert.Len(t, rewritten, 3)
+		target, err = fsys.Readlink("/home/.kube/config")
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/dev", target)
+	})
+
+	t.Run("Relocated home", func(t *testing.T) {
+		fsys, _ := initMemCO(t)
+		require.NoError(t, fsys.Remove("/home/.kube/config"))
+		require.NoError(t, fsys.Symlink("/old/home/.kube/co/prod", "/home/.kube/config"))
+		co, err := NewCO("/home", WithFS(fsys))
+		require.NoError(t, err)
+
+		rewritten, err := co.Relink(true)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/home/.kube/config", "/home/.kube/co/previous"}, rewritten)
+		target, err := fsys.Readlink("/home/.kube/config")
+		require.NoError(t, err)
+		assert.Equal(t, "co/prod", target)
+		assert.Equal(t, "/home/.kube/co/prod", co.CurrentConfigPath)
+	})
+}
@@ -0,0 +1,164 @@
+package internal
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+// StdinSource is the source of AddConfig reading the config from standard input.
+const StdinSource = "-"
+
+// DefaultFetchTimeout limits the time spent on fetching a config from a URL.
+const DefaultFetchTimeout = 30 * time.Second
+
+// maxConfigSize is the size of the largest config read from standard input, a URL or, by
+// Import, a file.
+const maxConfigSize = 1 << 20
+
+// WithStdin lets AddConfig read the source StdinSource from r instead of os.Stdin.
+func WithStdin(r io.Reader) Option {
+	return func(co *CO) {
+		co.stdin = r
+	}
+}
+
+// WithFetchTimeout limits the time AddConfig spends on fetching a config from an http(s) URL.
+// Zero keeps DefaultFetchTimeout.
+func WithFetchTimeout(timeout time.Duration) Option {
+	return func(co *CO) {
+		co.fetchTimeout = timeout
+	}
+}
+
+// isURLSource reports whether source is an http(s) or file URL.
+func isURLSource(source string) bool {
+	for _, scheme := range []string{"http://", "https://", "file://"} {
+		if strings.HasPrefix(strings.ToLower(source), scheme) {
+			return true
+		}
+	}
+	return false
+}
+
+// readSource returns the content of the config source: standard input for StdinSource, the
+// response body for an http(s) URL or the file for a file URL or path. Configs read from
+// standard input or a URL must be kubeconfigs.
+func (co *CO) readSource(ctx context.Context, source string) ([]byte, error) {
+	var data []byte
+	var err error
+	switch {
+	case source == StdinSource:
+		stdin := co.stdin
+		if stdin == nil {
+			stdin = os.Stdin
+		}
+		data, err = readLimited(stdin)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config from stdin: %w", err)
+		}
+	case isURLSource(source):
+		data, err = co.fetch(ctx, source)
+		if err != nil {
+			return nil, err
+		}
+	default:
+		data, err := co.filesystem().ReadFile(source)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input config file: %w", err)
+		}
+		return data, nil
+	}
+
+	kubeConfig, err := ParseKubeConfig(data)
+	if err != nil {
+		return nil, err
+	}
+	if !kubeConfig.valid() {
+		return nil, fmt.Errorf("%w: %s is no kubeconfig, it has neither clusters nor contexts", ErrInvalid, sourceName(source))
+	}
+	return data, nil
+}
+
+// fetch returns the config at the http(s) or file URL rawURL. The download is canceled when
+// ctx is done or the fetch timeout passed.
+func (co *CO) fetch(ctx context.Context, rawURL string) ([]byte, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: URL %s: %w", ErrInvalid, sourceName(rawURL), err)
+	}
+	if strings.EqualFold(u.Scheme, "file") {
+		if u.Host != "" && u.Host != "localhost" {
+			return nil, fmt.Errorf("%w: file URL %s must not have a host", ErrInvalid, rawURL)
+		}
+		data, err := co.filesystem().ReadFile(u.Path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read input config file: %w", err)
+		}
+		return data, nil
+	}
+
+	timeout := co.fetchTimeout
+	if timeout <= 0 {
+		timeout = DefaultFetchTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, fmt.Errorf("%w: URL %s: %w", ErrInvalid, sourceName(rawURL), err)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		// the url.Error repeats the URL including the query
+		if urlErr := (*url.Error)(nil); errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return nil, fmt.Errorf("failed to fetch %s: %w", sourceName(rawURL), err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", sourceName(rawURL), resp.Status)
+	}
+	data, err := readLimited(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %w", sourceName(rawURL), err)
+	}
+	return data, nil
+}
+
+// readLimited reads r up to maxConfigSize bytes.
+func readLimited(r io.Reader) ([]byte, error) {
+	data, err := io.ReadAll(io.LimitReader(r, maxConfigSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxConfigSize {
+		return nil, fmt.Errorf("%w: config is bigger than %d bytes", ErrInvalid, maxConfigSize)
+	}
+	return data, nil
+}
+
+// sourceName returns source for messages and the audit log. The password and query of URLs are
+// left out as they often hold credentials.
+func sourceName(source string) string {
+	if source == StdinSource {
+		return "stdin"
+	}
+	if !isURLSource(source) {
+		return source
+	}
+	u, err := url.Parse(source)
+	if err != nil {
+		return "invalid URL"
+	}
+	u.RawQuery = ""
+	u.Fragment = ""
+	return u.Redacted()
+}
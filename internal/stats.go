@@ -0,0 +1,205 @@
+package internal
+
+import (
+	"cmp"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/steffakasid/eslog"
+	"go.yaml.in/yaml/v3"
+)
+
+// Orders of SortConfigs.
+const (
+	// SortName sorts configs alphabetically.
+	SortName = "name"
+	// SortRecent sorts the most recently used configs first.
+	SortRecent = "recent"
+	// SortFrequent sorts the most often switched to configs first.
+	SortFrequent = "frequent"
+)
+
+// SortOrders lists the orders of SortConfigs.
+var SortOrders = []string{SortName, SortRecent, SortFrequent}
+
+// usageFileName is the file in the state directory the usage of the configs is kept in. Usage
+// is local state like the previous link, so it isn't kept in the metadata, which the git store
+// commits. It is hidden so it isn't listed as config if the state directory is the store
+// directory.
+const usageFileName = ".usage.yaml"
+
+// usage is when and how often a config was switched to.
+type usage struct {
+	LastUsed    time.Time `yaml:"lastUsed,omitempty"`
+	SwitchCount int       `yaml:"switchCount,omitempty"`
+}
+
+// DefaultUnusedDays is the number of days after which Stats reports a config as unused.
+const DefaultUnusedDays = 30
+
+// ValidateSort checks the order by. An empty order is the order by name. Errors wrap ErrInvalid.
+func ValidateSort(by string) error {
+	if by != "" && !slices.Contains(SortOrders, by) {
+		return fmt.Errorf("%w: sort order %q, use one of %s", ErrInvalid, by, strings.Join(SortOrders, ", "))
+	}
+	return nil
+}
+
+// CompareUsage compares the configs a and b with the metadata mdA and mdB for the order by.
+// Ties are broken by the more recent use and then by name, so never used configs come last.
+func CompareUsage(by, a string, mdA Metadata, b string, mdB Metadata) int {
+	byName := cmp.Compare(a, b)
+	byRecent := mdB.LastUsed.Compare(mdA.LastUsed)
+	switch by {
+	case SortRecent:
+		return cmp.Or(byRecent, byName)
+	case SortFrequent:
+		return cmp.Or(cmp.Compare(mdB.SwitchCount, mdA.SwitchCount), byRecent, byName)
+	}
+	return byName
+}
+
+// SortConfigs sorts co.Configs, as read by ListConfigs, in the order by.
+func (co *CO) SortConfigs(by string) error {
+	if err := ValidateSort(by); err != nil {
+		return err
+	}
+	if by == "" || by == SortName {
+		slices.Sort(co.Configs)
+		return nil
+	}
+	metadata := map[string]Metadata{}
+	for _, name := range co.Configs {
+		md, err := co.Metadata(name)
+		if err != nil {
+			return err
+		}
+		metadata[name] = md
+	}
+	slices.SortStableFunc(co.Configs, func(a, b string) int {
+		return CompareUsage(by, a, metadata[a], b, metadata[b])
+	})
+	return nil
+}
+
+// ConfigStats is the usage of a config.
+type ConfigStats struct {
+	Name        string    `json:"name"`
+	SwitchCount int       `json:"switchCount"`
+	LastUsed    time.Time `json:"lastUsed,omitzero"`
+	Created     time.Time `json:"created,omitzero"`
+	// Unused is set if the config wasn't used within the days passed to Stats. Configs which
+	// were never used count from their creation. Configs stored by versions which didn't record
+	// either are never marked, as their use is unknown.
+	Unused bool `json:"unused"`
+}
+
+// Stats returns the usage of all configs in the order by. Configs not used within unusedDays
+// before now are marked as unused and are candidates for a cleanup.
+func (co *CO) Stats(by string, unusedDays int, now time.Time) ([]ConfigStats, error) {
+	if err := co.ListConfigs(); err != nil {
+		return nil, err
+	}
+	if err := co.SortConfigs(by); err != nil {
+		return nil, err
+	}
+	cutoff := now.AddDate(0, 0, -unusedDays)
+	stats := make([]ConfigStats, 0, len(co.Configs))
+	for _, name := range co.Configs {
+		md, err := co.Metadata(name)
+		if err != nil {
+			return nil, err
+		}
+		since := md.LastUsed
+		if since.IsZero() {
+			since = md.Created
+		}
+		stats = append(stats, ConfigStats{
+			Name:        name,
+			SwitchCount: md.SwitchCount,
+			LastUsed:    md.LastUsed,
+			Created:     md.Created,
+			Unused:      !since.IsZero() && since.Before(cutoff),
+		})
+	}
+	return stats, nil
+}
+
+// usagePath returns the path of the usage file.
+func (co *CO) usagePath() string {
+	return path.Join(co.StateDir, usageFileName)
+}
+
+// readUsage returns the usage of the configs by name.
+func (co *CO) readUsage() (map[string]usage, error) {
+	usages := map[string]usage{}
+	data, err := co.filesystem().ReadFile(co.usagePath())
+	if errors.Is(err, fs.ErrNotExist) {
+		return usages, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read usage: %w", err)
+	}
+	if err := yaml.Unmarshal(data, &usages); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse usage %s: %w", ErrInvalid, co.usagePath(), err)
+	}
+	return usages, nil
+}
+
+// withUsage sets the usage of the config name in md. Metadata written by older versions holds
+// the usage itself, it is kept until the config is switched to.
+func (co *CO) withUsage(name string, md Metadata) (Metadata, error) {
+	usages, err := co.readUsage()
+	if err != nil {
+		return md, err
+	}
+	if u, ok := usages[name]; ok {
+		md.LastUsed = u.LastUsed
+		md.SwitchCount = u.SwitchCount
+	}
+	return md, nil
+}
+
+// updateUsage calls update with the usage of the config oldName and stores the result as usage
+// of newName. An empty newName removes the usage. Failures are only logged as the usage is
+// informational.
+func (co *CO) updateUsage(oldName, newName string, update func(u *usage)) {
+	err := func() error {
+		usages, err := co.readUsage()
+		if err != nil {
+			return err
+		}
+		u, ok := usages[oldName]
+		if !ok && newName != "" {
+			// take over the usage kept in the metadata by older versions, which a rename has
+			// moved already
+			md, err := co.store().Metadata(newName)
+			if err != nil {
+				return err
+			}
+			u = usage{LastUsed: md.LastUsed, SwitchCount: md.SwitchCount}
+		} else if !ok {
+			return nil
+		}
+		delete(usages, oldName)
+		if newName != "" {
+			update(&u)
+			usages[newName] = u
+		}
+		data, err := yaml.Marshal(usages)
+		if err != nil {
+			return fmt.Errorf("failed to marshal usage: %w", err)
+		}
+		if err := co.filesystem().WriteFile(co.usagePath(), data, 0600); err != nil {
+			return fmt.Errorf("failed to write usage: %w", err)
+		}
+		return nil
+	}()
+	if err != nil {
+		eslog.Warnf("Failed to record usage of %s: %s", oldName, err)
+	}
+}
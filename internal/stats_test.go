@@ -0,0 +1,129 @@
+package internal
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSortConfigs(t *testing.T) {
+	fsys, _ := initMemCO(t)
+	require.NoError(t, fsys.WriteFile("/home/.kube/co/staging", nil, onlyOwnerAccess))
+	require.NoError(t, fsys.WriteFile("/home/.kube/co/legacy", nil, onlyOwnerAccess))
+	now := time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC)
+	co, err := NewCO("/home", WithFS(fsys))
+	require.NoError(t, err)
+	setMetadata := func(name string, md Metadata) {
+		co.ConfigName = name
+		require.NoError(t, co.UpdateMetadata(func(old *Metadata) error {
+			*old = md
+			return nil
+		}))
+	}
+	setUsage := func(name string, switchCount int, lastUsed time.Time) {
+		co.updateUsage(name, name, func(u *usage) {
+			*u = usage{LastUsed: lastUsed, SwitchCount: switchCount}
+		})
+	}
+	setMetadata("dev", Metadata{Created: now.AddDate(0, 0, -100)})
+	setUsage("dev", 5, now.AddDate(0, 0, -40))
+	setUsage("prod", 2, now.AddDate(0, 0, -1))
+	setMetadata("staging", Metadata{Created: now.AddDate(0, 0, -2)})
+
+	tests := map[string][]string{
+		"":           {"dev", "legacy", "prod", "staging"},
+		SortName:     {"dev", "legacy", "prod", "staging"},
+		SortRecent:   {"prod", "dev", "legacy", "staging"},
+		SortFrequent: {"dev", "prod", "legacy", "staging"},
+	}
+	for by, want := range tests {
+		t.Run("Sort "+by, func(t *testing.T) {
+			require.NoError(t, co.ListConfigs())
+			require.NoError(t, co.SortConfigs(by))
+			assert.Equal(t, want, co.Configs)
+		})
+	}
+	assert.ErrorIs(t, co.SortConfigs("size"), ErrInvalid)
+
+	t.Run("Stats", func(t *testing.T) {
+		stats, err := co.Stats(SortFrequent, 30, now)
+		require.NoError(t, err)
+		require.Len(t, stats, 4)
+		assert.Equal(t, ConfigStats{Name: "dev", SwitchCount: 5, LastUsed: now.AddDate(0, 0, -40),
+			Created: now.AddDate(0, 0, -100), Unused: true}, stats[0])
+		assert.False(t, stats[1].Unused)
+		assert.Equal(t, ConfigStats{Name: "legacy"}, stats[2], "configs without timestamps are unknown")
+		assert.False(t, stats[3].Unused, "never used configs count from their creation")
+	})
+}
+
+func TestSwitchCount(t *testing.T) {
+	_, co := initMemCO(t)
+	for _, name := range []string{"prod", "dev", "prod"} {
+		co.ConfigName = name
+		require.NoError(t, co.LinkKubeConfig())
+	}
+	md, err := co.Metadata("prod")
+	require.NoError(t, err)
+	assert.Equal(t, 2, md.SwitchCount)
+	assert.False(t, md.LastUsed.IsZero())
+
+	t.Run("Usage isn't kept in the metadata", func(t *testing.T) {
+		stored, err := co.store().Metadata("prod")
+		require.NoError(t, err)
+		assert.Zero(t, stored.SwitchCount)
+		assert.True(t, stored.LastUsed.IsZero())
+	})
+
+	t.Run("Usage follows renames and deletes", func(t *testing.T) {
+		co.ConfigName = "prod"
+		require.NoError(t, co.RenameConfig("production"))
+		md, err := co.Metadata("production")
+		require.NoError(t, err)
+		assert.Equal(t, 2, md.SwitchCount)
+
+		co.ConfigName = "production"
+		require.NoError(t, co.DeleteConfig())
+		usages, err := co.readUsage()
+		require.NoError(t, err)
+		assert.NotContains(t, usages, "production")
+	})
+}
+
+func TestLegacyUsage(t *testing.T) {
+	fsys, co := initMemCO(t)
+	lastUsed := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+	require.NoError(t, fsys.MkdirAll("/home/.kube/co/.meta", onlyOwnerAccess))
+	require.NoError(t, fsys.WriteFile("/home/.kube/co/.meta/prod.yaml",
+		[]byte("description: Production\nlastUsed: 2024-05-10T12:00:00Z\nswitchCount: 7\n"), 0600))
+
+	md, err := co.Metadata("prod")
+	require.NoError(t, err)
+	assert.Equal(t, 7, md.SwitchCount)
+	assert.Equal(t, lastUsed, md.LastUsed)
+
+	t.Run("Taken over by a metadata change", func(t *testing.T) {
+		co.ConfigName = "prod"
+		require.NoError(t, co.UpdateMetadata(func(md *Metadata) error {
+			md.Owner = "alice"
+			return nil
+		}))
+		data, err := fsys.ReadFile("/home/.kube/co/.meta/prod.yaml")
+		require.NoError(t, err)
+		assert.NotContains(t, string(data), "switchCount")
+		md, err := co.Metadata("prod")
+		require.NoError(t, err)
+		assert.Equal(t, 7, md.SwitchCount)
+		assert.Equal(t, lastUsed, md.LastUsed)
+	})
+
+	t.Run("Counted by a switch", func(t *testing.T) {
+		co.ConfigName = "prod"
+		require.NoError(t, co.LinkKubeConfig())
+		md, err := co.Metadata("prod")
+		require.NoError(t, err)
+		assert.Equal(t, 8, md.SwitchCount)
+	})
+}
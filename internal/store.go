@@ -0,0 +1,227 @@
+package internal
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"go.yaml.in/yaml/v3"
+)
+
+const (
+	// StoreBackendDir keeps every config as a plain file inside the CO base path.
+	StoreBackendDir = "dir"
+	// StoreBackendGit keeps the CO base path as a git working tree and commits every change.
+	StoreBackendGit = "git"
+
+	previousLinkName = "previous"
+	metadataDirName  = ".meta"
+)
+
+// Metadata holds additional information stored next to a config.
+type Metadata struct {
+	Description string `yaml:"description,omitempty"`
+	// Labels are used to select configs, e.g. env=prod.
+	Labels map[string]string `yaml:"labels,omitempty"`
+	// Groups are the names of the groups the config belongs to, e.g. customer-a.
+	Groups []string `yaml:"groups,omitempty"`
+	// Aliases are short names the config can be switched to or deleted by.
+	Aliases []string `yaml:"aliases,omitempty"`
+	// Variables are environment variables the config needs, e.g. AWS_PROFILE.
+	Variables   map[string]string `yaml:"variables,omitempty"`
+	Annotations map[string]string `yaml:"annotations,omitempty"`
+	// Color is the color the config is listed in. It overrides the color of the environment.
+	Color   string    `yaml:"color,omitempty"`
+	Owner   string    `yaml:"owner,omitempty"`
+	Created time.Time `yaml:"created,omitempty"`
+	// LastUsed and SwitchCount are the usage of the config. They are kept in the state
+	// directory and only set by CO.Metadata. Stores drop them from the metadata, which older
+	// versions kept them in.
+	LastUsed time.Time `yaml:"lastUsed,omitempty"`
+	// SwitchCount is the number of times the config was switched to.
+	SwitchCount int `yaml:"switchCount,omitempty"`
+	// Protected requires a confirmation before switching to or deleting the config.
+	Protected bool `yaml:"protected,omitempty"`
+	// Environment names the kind of cluster, e.g. prod. Configs of protected environments
+	// are protected as well.
+	Environment string `yaml:"environment,omitempty"`
+	// Expires is the time a temporary config is moved to the trash, see CO.CollectExpired.
+	Expires time.Time `yaml:"expires,omitempty"`
+}
+
+// Store is the place where configs are kept. Every config is addressed by its name.
+type Store interface {
+	// List returns the names of all stored configs in alphabetical order.
+	List() ([]string, error)
+	// Get returns the content of the config with the given name.
+	Get(name string) ([]byte, error)
+	// Put creates or replaces the config with the given name.
+	Put(name string, data []byte) error
+	// Delete removes the config with the given name and its metadata.
+	Delete(name string) error
+	// Rename moves the config and its metadata to a new name.
+	Rename(oldName, newName string) error
+	// Metadata returns the metadata of the config. Configs without metadata return an empty Metadata.
+	Metadata(name string) (Metadata, error)
+	// SetMetadata replaces the metadata of the config.
+	SetMetadata(name string, md Metadata) error
+	// Path returns the filesystem path kubectl can read the config from.
+	Path(name string) string
+}
+
+// NewStore returns the Store implementation for the given backend rooted at basePath.
+// An empty backend selects the directory store. The git backend only works on OSFS as it
+// runs the git binary.
+func NewStore(backend string, fsys FS, basePath string) (Store, error) {
+	switch backend {
+	case "", StoreBackendDir:
+		return &dirStore{fs: fsys, basePath: basePath}, nil
+	case StoreBackendGit:
+		if _, ok := fsys.(OSFS); !ok {
+			return nil, fmt.Errorf("%w: store backend %q requires the OS filesystem", ErrInvalid, backend)
+		}
+		return &gitStore{dirStore: dirStore{fs: fsys, basePath: basePath}}, nil
+	default:
+		return nil, fmt.Errorf("%w: unknown store backend %q", ErrInvalid, backend)
+	}
+}
+
+// dirStore keeps configs as files in basePath and metadata as YAML sidecar files in basePath/.meta.
+type dirStore struct {
+	fs       FS
+	basePath string
+}
+
+// validateName makes sure a config name can be used as a file name inside the store.
+func validateName(name string) error {
+	if name == "" || name == "." || name == ".." || strings.ContainsRune(name, '/') {
+		return fmt.Errorf("%w: config name %q", ErrInvalid, name)
+	}
+	if name == previousLinkName || strings.HasPrefix(name, ".") {
+		return fmt.Errorf("%w: config name %q is reserved", ErrInvalid, name)
+	}
+	return nil
+}
+
+func (s *dirStore) Path(name string) string {
+	return path.Join(s.basePath, name)
+}
+
+func (s *dirStore) metadataPath(name string) string {
+	return path.Join(s.basePath, metadataDirName, name+".yaml")
+}
+
+// List reads the base path and returns all entries except the previous link and hidden files.
+func (s *dirStore) List() ([]string, error) {
+	entries, err := s.fs.ReadDir(s.basePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config directory %s: %w", s.basePath, err)
+	}
+	configs := []string{}
+	for _, entry := range entries {
+		if entry.Name() == previousLinkName || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+		configs = append(configs, entry.Name())
+	}
+	sort.Strings(configs)
+	return configs, nil
+}
+
+func (s *dirStore) Get(name string) ([]byte, error) {
+	data, err := s.fs.ReadFile(s.Path(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %s", ErrNotFound, name)
+	} else if err != nil {
+		return nil, fmt.Errorf("failed to read config %s: %w", name, err)
+	}
+	return data, nil
+}
+
+// Put writes the config with owner-only access. The permissions are set explicitly
+// because os.WriteFile keeps the mode of an already existing file.
+func (s *dirStore) Put(name string, data []byte) error {
+	if err := validateName(name); err != nil {
+		return err
+	}
+	configPath := s.Path(name)
+	if err := s.fs.WriteFile(configPath, data, onlyOwnerAccess); err != nil {
+		return fmt.Errorf("failed to write config file: %w", err)
+	}
+	if err := s.fs.Chmod(configPath, onlyOwnerAccess); err != nil {
+		return fmt.Errorf("failed to set permissions on config file: %w", err)
+	}
+	return nil
+}
+
+func (s *dirStore) Delete(name string) error {
+	if err := s.fs.Remove(s.Path(name)); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	} else if err != nil {
+		return fmt.Errorf("failed to delete config file %s: %w", s.Path(name), err)
+	}
+	err := s.fs.Remove(s.metadataPath(name))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete metadata of %s: %w", name, err)
+	}
+	return nil
+}
+
+// Rename moves the config file and its metadata. It refuses to overwrite an existing config.
+func (s *dirStore) Rename(oldName, newName string) error {
+	if err := validateName(newName); err != nil {
+		return err
+	}
+	if _, err := s.fs.Lstat(s.Path(oldName)); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, oldName)
+	}
+	if _, err := s.fs.Lstat(s.Path(newName)); err == nil {
+		return fmt.Errorf("%w: %s", ErrExists, newName)
+	}
+	if err := s.fs.Rename(s.Path(oldName), s.Path(newName)); err != nil {
+		return fmt.Errorf("failed to rename config %s: %w", oldName, err)
+	}
+	err := s.fs.Rename(s.metadataPath(oldName), s.metadataPath(newName))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to rename metadata of %s: %w", oldName, err)
+	}
+	return nil
+}
+
+func (s *dirStore) Metadata(name string) (Metadata, error) {
+	md := Metadata{}
+	data, err := s.fs.ReadFile(s.metadataPath(name))
+	if errors.Is(err, fs.ErrNotExist) {
+		return md, nil
+	} else if err != nil {
+		return md, fmt.Errorf("failed to read metadata of %s: %w", name, err)
+	}
+	if err := yaml.Unmarshal(data, &md); err != nil {
+		return md, fmt.Errorf("%w: failed to parse metadata of %s: %w", ErrInvalid, name, err)
+	}
+	return md, nil
+}
+
+func (s *dirStore) SetMetadata(name string, md Metadata) error {
+	if _, err := s.fs.Lstat(s.Path(name)); errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("%w: %s", ErrNotFound, name)
+	}
+	metadataDir := path.Join(s.basePath, metadataDirName)
+	if err := s.fs.MkdirAll(metadataDir, onlyOwnerAccess); err != nil {
+		return fmt.Errorf("failed to create metadata directory: %w", err)
+	}
+	md.LastUsed = time.Time{}
+	md.SwitchCount = 0
+	data, err := yaml.Marshal(md)
+	if err != nil {
+		return fmt.Errorf("failed to marshal metadata of %s: %w", name, err)
+	}
+	if err := s.fs.WriteFile(s.metadataPath(name), data, 0600); err != nil {
+		return fmt.Errorf("failed to write metadata of %s: %w", name, err)
+	}
+	return nil
+}
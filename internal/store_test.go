@@ -0,0 +1,237 @@
+package internal
+
+import (
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// storeConformance runs the behaviour every Store implementation must provide.
+func storeConformance(t *testing.T, newStore func(t *testing.T) (Store, FS)) {
+	t.Run("Put and Get", func(t *testing.T) {
+		store, _ := newStore(t)
+		content := []byte("kind: Config\n")
+		require.NoError(t, store.Put("dev", content))
+
+		got, err := store.Get("dev")
+		require.NoError(t, err)
+		assert.Equal(t, content, got)
+	})
+
+	t.Run("Put replaces", func(t *testing.T) {
+		store, _ := newStore(t)
+		require.NoError(t, store.Put("dev", []byte("old")))
+		require.NoError(t, store.Put("dev", []byte("new")))
+
+		got, err := store.Get("dev")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("new"), got)
+	})
+
+	t.Run("Put owner only", func(t *testing.T) {
+		store, fsys := newStore(t)
+		require.NoError(t, store.Put("dev", nil))
+
+		fi, err := fsys.Stat(store.Path("dev"))
+		require.NoError(t, err)
+		assert.Equal(t, os.FileMode(onlyOwnerAccess), fi.Mode().Perm())
+	})
+
+	t.Run("Put invalid names", func(t *testing.T) {
+		store, _ := newStore(t)
+		for _, name := range []string{"", ".", "..", "a/b", "previous", ".hidden"} {
+			assert.ErrorIs(t, store.Put(name, nil), ErrInvalid, name)
+		}
+	})
+
+	t.Run("Get not found", func(t *testing.T) {
+		store, _ := newStore(t)
+		_, err := store.Get("missing")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("List", func(t *testing.T) {
+		store, _ := newStore(t)
+		require.NoError(t, store.Put("prod", nil))
+		require.NoError(t, store.Put("dev", nil))
+		require.NoError(t, store.SetMetadata("dev", Metadata{Annotations: map[string]string{"a": "b"}}))
+
+		configs, err := store.List()
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dev", "prod"}, configs)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		store, _ := newStore(t)
+		require.NoError(t, store.Put("dev", nil))
+		require.NoError(t, store.SetMetadata("dev", Metadata{Annotations: map[string]string{"a": "b"}}))
+		require.NoError(t, store.Delete("dev"))
+
+		_, err := store.Get("dev")
+		require.ErrorIs(t, err, ErrNotFound)
+		require.NoError(t, store.Put("dev", nil))
+		md, err := store.Metadata("dev")
+		require.NoError(t, err)
+		assert.Empty(t, md.Annotations)
+	})
+
+	t.Run("Delete not found", func(t *testing.T) {
+		store, _ := newStore(t)
+		require.ErrorIs(t, store.Delete("missing"), ErrNotFound)
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		store, _ := newStore(t)
+		require.NoError(t, store.Put("old", []byte("content")))
+		require.NoError(t, store.SetMetadata("old", Metadata{Annotations: map[string]string{"a": "b"}}))
+		require.NoError(t, store.Rename("old", "new"))
+
+		_, err := store.Get("old")
+		require.ErrorIs(t, err, ErrNotFound)
+		got, err := store.Get("new")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("content"), got)
+		md, err := store.Metadata("new")
+		require.NoError(t, err)
+		assert.Equal(t, "b", md.Annotations["a"])
+	})
+
+	t.Run("Rename not found", func(t *testing.T) {
+		store, _ := newStore(t)
+		require.ErrorIs(t, store.Rename("missing", "new"), ErrNotFound)
+	})
+
+	t.Run("Rename existing target", func(t *testing.T) {
+		store, _ := newStore(t)
+		require.NoError(t, store.Put("a", nil))
+		require.NoError(t, store.Put("b", nil))
+		err := store.Rename("a", "b")
+		require.ErrorIs(t, err, ErrExists)
+	})
+
+	t.Run("Metadata empty", func(t *testing.T) {
+		store, _ := newStore(t)
+		require.NoError(t, store.Put("dev", nil))
+		md, err := store.Metadata("dev")
+		require.NoError(t, err)
+		assert.Empty(t, md.Annotations)
+	})
+
+	t.Run("SetMetadata not found", func(t *testing.T) {
+		store, _ := newStore(t)
+		err := store.SetMetadata("missing", Metadata{})
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+}
+
+func TestDirStore(t *testing.T) {
+	storeConformance(t, func(t *testing.T) (Store, FS) {
+		store, err := NewStore(StoreBackendDir, OSFS{}, t.TempDir())
+		require.NoError(t, err)
+		return store, OSFS{}
+	})
+}
+
+func TestDirStoreMemFS(t *testing.T) {
+	storeConformance(t, func(t *testing.T) (Store, FS) {
+		fsys := NewMemFS()
+		require.NoError(t, fsys.MkdirAll("/home/.kube/co", onlyOwnerAccess))
+		store, err := NewStore(StoreBackendDir, fsys, "/home/.kube/co")
+		require.NoError(t, err)
+		return store, fsys
+	})
+}
+
+func TestGitStore(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+	storeConformance(t, func(t *testing.T) (Store, FS) {
+		store, err := NewStore(StoreBackendGit, OSFS{}, t.TempDir())
+		require.NoError(t, err)
+		return store, OSFS{}
+	})
+
+	t.Run("Commits changes", func(t *testing.T) {
+		basePath := t.TempDir()
+		store, err := NewStore(StoreBackendGit, OSFS{}, basePath)
+		require.NoError(t, err)
+		require.NoError(t, store.Put("dev", nil))
+		require.NoError(t, store.Rename("dev", "staging"))
+
+		out, err := exec.Command("git", "-C", basePath, "log", "--format=%s").Output()
+		require.NoError(t, err)
+		assert.Equal(t, "Rename dev to staging\nPut dev\n", string(out))
+		assert.FileExists(t, path.Join(basePath, ".gitignore"))
+	})
+
+	t.Run("Audit log, trash and backups are not committed", func(t *testing.T) {
+		basePath := t.TempDir()
+		store, err := NewStore(StoreBackendGit, OSFS{}, basePath)
+		require.NoError(t, err)
+		require.NoError(t, os.WriteFile(path.Join(basePath, auditLogName), []byte("{}\n"), 0600))
+		require.NoError(t, os.MkdirAll(path.Join(basePath, trashDirName, "tmp-20260101T000000Z"), 0700))
+		require.NoError(t, os.WriteFile(path.Join(basePath, trashDirName, "tmp-20260101T000000Z", "tmp"), nil, 0600))
+		require.NoError(t, os.MkdirAll(path.Join(basePath, backupDirName), 0700))
+		require.NoError(t, os.WriteFile(path.Join(basePath, backupDirName, "dev-20260101T000000Z"), nil, 0600))
+		require.NoError(t, store.Put("dev", nil))
+		require.NoError(t, store.SetMetadata("dev", Metadata{}))
+
+		out, err := exec.Command("git", "-C", basePath, "ls-files").Output()
+		require.NoError(t, err)
+		assert.Equal(t, ".gitignore\n.meta/dev.yaml\ndev\n", string(out))
+	})
+
+	t.Run("Previous link of a clone is not committed", func(t *testing.T) {
+		basePath := t.TempDir()
+		require.NoError(t, exec.Command("git", "-C", basePath, "init", "--quiet").Run())
+		require.NoError(t, os.WriteFile(path.Join(basePath, "dev"), nil, 0600))
+		require.NoError(t, os.Symlink(path.Join(basePath, "dev"), path.Join(basePath, previousLinkName)))
+		store, err := NewStore(StoreBackendGit, OSFS{}, basePath)
+		require.NoError(t, err)
+		require.NoError(t, store.Put("prod", nil))
+
+		out, err := exec.Command("git", "-C", basePath, "ls-files").Output()
+		require.NoError(t, err)
+		assert.Equal(t, "dev\nprod\n", string(out))
+	})
+
+	t.Run("Switching does not commit", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", "")
+		home := t.TempDir()
+		co, err := NewCO(home, WithBackend(StoreBackendGit))
+		require.NoError(t, err)
+		for _, name := range []string{"dev", "prod"} {
+			co.ConfigName = name
+			require.NoError(t, co.AddConfig(t.Context(), ""))
+		}
+		for _, name := range []string{"dev", "prod", "dev"} {
+			co.ConfigName = name
+			require.NoError(t, co.LinkKubeConfig())
+		}
+
+		out, err := exec.Command("git", "-C", co.CObasePath, "log", "--format=%s").Output()
+		require.NoError(t, err)
+		assert.Equal(t, "Update metadata of prod\nPut prod\nUpdate metadata of dev\nPut dev\n", string(out))
+		md, err := co.Metadata("dev")
+		require.NoError(t, err)
+		assert.Equal(t, 2, md.SwitchCount)
+	})
+}
+
+func TestNewStore(t *testing.T) {
+	t.Run("Unknown backend", func(t *testing.T) {
+		_, err := NewStore("s3", OSFS{}, t.TempDir())
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown store backend")
+	})
+	t.Run("Git requires OSFS", func(t *testing.T) {
+		_, err := NewStore(StoreBackendGit, NewMemFS(), "/co")
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "requires the OS filesystem")
+	})
+}
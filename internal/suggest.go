@@ -0,0 +1,95 @@
+package internal
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions limits the number of names suggested for a missing config.
+const maxSuggestions = 5
+
+// WithPrefixMatch sets whether a unique prefix of a config name or alias resolves to the config.
+// It is disabled by default, so prefixes are only suggested when the config is not found.
+func WithPrefixMatch(enabled bool) Option {
+	return func(co *CO) {
+		co.prefixMatch = enabled
+	}
+}
+
+// Suggestions returns the config names and aliases close to name: names starting with name
+// first, then names containing name or within a small edit distance, closest first.
+func (co *CO) Suggestions(name string) []string {
+	if err := co.ListConfigs(); err != nil {
+		return nil
+	}
+	candidates := append([]string{}, co.Configs...)
+	if aliases, err := co.Aliases(); err == nil {
+		for alias := range aliases {
+			candidates = append(candidates, alias)
+		}
+	}
+
+	lower := strings.ToLower(name)
+	maxDistance := 1 + len(name)/4
+	distances := map[string]int{}
+	for _, candidate := range candidates {
+		candidateLower := strings.ToLower(candidate)
+		distance := editDistance(lower, candidateLower)
+		switch {
+		case strings.HasPrefix(candidateLower, lower):
+			distances[candidate] = 0
+		case strings.Contains(candidateLower, lower):
+			distances[candidate] = min(distance, maxDistance)
+		case distance <= maxDistance:
+			distances[candidate] = distance
+		}
+	}
+
+	suggestions := make([]string, 0, len(distances))
+	for candidate := range distances {
+		suggestions = append(suggestions, candidate)
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if distances[suggestions[i]] != distances[suggestions[j]] {
+			return distances[suggestions[i]] < distances[suggestions[j]]
+		}
+		return suggestions[i] < suggestions[j]
+	})
+	if len(suggestions) > maxSuggestions {
+		suggestions = suggestions[:maxSuggestions]
+	}
+	return suggestions
+}
+
+// notFound returns the ErrNotFound error for the config name including suggestions of similar
+// names.
+func (co *CO) notFound(name string) error {
+	err := fmt.Errorf("config '%s' does not exist: %w", name, ErrNotFound)
+	if suggestions := co.Suggestions(name); len(suggestions) > 0 {
+		return fmt.Errorf("%w. Did you mean %s?", err, strings.Join(suggestions, ", "))
+	}
+	return err
+}
+
+// editDistance returns the Levenshtein distance of a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	previous := make([]int, len(rb)+1)
+	current := make([]int, len(rb)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		current[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(rb)]
+}
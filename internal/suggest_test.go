@@ -0,0 +1,67 @@
+package internal
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestEditDistance(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want int
+	}{
+		{a: "", b: "", want: 0},
+		{a: "dev", b: "", want: 3},
+		{a: "staging", b: "staging", want: 0},
+		{a: "stagign", b: "staging", want: 2},
+		{a: "prd", b: "prod", want: 1},
+		{a: "kitten", b: "sitting", want: 3},
+		{a: "prüd", b: "prod", want: 1},
+	}
+
+	for _, test := range tests {
+		assert.Equal(t, test.want, editDistance(test.a, test.b), "%s %s", test.a, test.b)
+	}
+}
+
+func TestSuggestions(t *testing.T) {
+	_, co := initAliasCO(t)
+	co.ConfigName = "arn-aws-eks-staging"
+	require.NoError(t, co.AddAlias("stg"))
+
+	tests := map[string][]string{
+		"prd":     {"prod"},
+		"PROD":    {"prod"},
+		"sandbx":  {"sandbox"},
+		"staging": {"arn-aws-eks-staging"},
+		"eks":     {"arn-aws-eks-stable", "arn-aws-eks-staging"},
+		"st":      {"stg", "arn-aws-eks-stable", "arn-aws-eks-staging"},
+		"zzzzzz":  {},
+	}
+
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, want, co.Suggestions(name))
+		})
+	}
+}
+
+func TestNotFoundSuggestions(t *testing.T) {
+	_, co := initAliasCO(t, WithPrefixMatch(false))
+
+	co.ConfigName = "sand"
+	err := co.LinkKubeConfig()
+	require.ErrorIs(t, err, ErrNotFound)
+	assert.EqualError(t, err, "config 'sand' does not exist: config not found. Did you mean sandbox?")
+
+	co.ConfigName = "prd"
+	err = co.DeleteConfig()
+	require.ErrorIs(t, err, ErrNotFound)
+	assert.Contains(t, err.Error(), "Did you mean prod?")
+
+	co.ConfigName = "zzzzzz"
+	err = co.LinkKubeConfig()
+	assert.EqualError(t, err, "config 'zzzzzz' does not exist: config not found")
+}
@@ -0,0 +1,348 @@
+package internal
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/steffakasid/eslog"
+	"go.yaml.in/yaml/v3"
+)
+
+// backupDirName is the directory in the state directory the previous versions of updated
+// configs are kept in. It is hidden so it isn't listed as config if the state directory is the
+// store directory.
+const backupDirName = ".backup"
+
+// UpdateResult describes what UpdateConfig did.
+type UpdateResult struct {
+	// Name is the resolved name of the updated config.
+	Name string
+	// Diff is the unified diff from the stored to the updated config. The values of the users,
+	// which hold the credentials, are replaced by a hash.
+	Diff string
+	// Changed is set if the update changes the config.
+	Changed bool
+	// Backup is the path of the copy of the previous version. It is empty if nothing was written.
+	Backup string
+}
+
+// BackupDir returns the directory the previous versions of updated configs are kept in.
+func (co *CO) BackupDir() string {
+	return path.Join(co.StateDir, backupDirName)
+}
+
+// UpdateConfig merges the kubeconfig at source into the stored config co.ConfigName, see
+// mergeKubeConfigs. source is read like by AddConfig. The previous version is copied to a file
+// in BackupDir before the merged config is written. With dryRun nothing is written and only
+// the diff is returned. The update is recorded in the audit log.
+func (co *CO) UpdateConfig(ctx context.Context, source string, dryRun bool) (UpdateResult, error) {
+	result, err := co.updateConfig(ctx, source, dryRun, time.Now())
+	if !dryRun {
+		name := result.Name
+		if name == "" {
+			name = co.ConfigName
+		}
+		co.audit(AuditEntry{Action: AuditUpdate, Config: name, From: sourceName(source)}, err)
+	}
+	return result, err
+}
+
+func (co *CO) updateConfig(ctx context.Context, source string, dryRun bool, now time.Time) (UpdateResult, error) {
+	result := UpdateResult{}
+	// like deleting, overwriting a config only accepts exact names
+	name, err := co.ResolveAlias(co.ConfigName)
+	if err != nil {
+		return result, err
+	}
+	result.Name = name
+	store := co.store()
+	stored, err := store.Get(name)
+	if errors.Is(err, ErrNotFound) {
+		return result, co.notFound(name)
+	} else if err != nil {
+		return result, err
+	}
+
+	input, err := co.readSource(ctx, source)
+	if err != nil {
+		return result, err
+	}
+	if kubeConfig, err := ParseKubeConfig(input); err != nil {
+		return result, err
+	} else if !kubeConfig.valid() {
+		return result, fmt.Errorf("%w: %s is no kubeconfig, it has neither clusters nor contexts", ErrInvalid, sourceName(source))
+	}
+
+	// the stored config is formatted like the merged one, so the diff only shows the changes
+	before, err := formatKubeConfig(stored)
+	if err != nil {
+		return result, fmt.Errorf("failed to read stored config %s: %w", name, err)
+	}
+	merged, err := mergeKubeConfigs(stored, input)
+	if err != nil {
+		return result, err
+	}
+	if bytes.Equal(before, merged) {
+		return result, nil
+	}
+	result.Changed = true
+	result.Diff, err = diffConfigs(name, before, merged)
+	if err != nil || dryRun {
+		return result, err
+	}
+
+	if err := co.filesystem().MkdirAll(co.BackupDir(), onlyOwnerAccess); err != nil {
+		return result, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+	backup, err := co.writeBackup(name, stored, now)
+	if err != nil {
+		return result, fmt.Errorf("failed to back up %s: %w", name, err)
+	}
+	result.Backup = backup
+	if err := store.Put(name, merged); err != nil {
+		return result, fmt.Errorf("failed to write config file: %w", err)
+	}
+	eslog.Debugf("Updated %s, the previous version is kept in %s", name, backup)
+	return result, nil
+}
+
+// writeBackup writes data to a new file in BackupDir named after the config name and now and
+// returns its path. A number is appended if several backups are written in the same second, so
+// none is overwritten.
+func (co *CO) writeBackup(name string, data []byte, now time.Time) (string, error) {
+	base := path.Join(co.BackupDir(), fmt.Sprintf("%s-%s", name, now.UTC().Format(trashTimeLayout)))
+	backup := base
+	for i := 2; ; i++ {
+		err := co.filesystem().CreateFile(backup, data, 0600)
+		if !errors.Is(err, fs.ErrExist) {
+			return backup, err
+		}
+		backup = base + "-" + strconv.Itoa(i)
+	}
+}
+
+// mergeKubeConfigs merges the kubeconfig update into stored and returns the result:
+//   - clusters, users and contexts of update which aren't in stored are added
+//   - users in both are replaced by the one of update, so refreshed credentials replace the old
+//     ones entirely
+//   - the fields of clusters and contexts in both are set to the ones of update, fields only
+//     stored has are kept, and so is the namespace of a stored context
+//   - entries only stored has and all other top-level fields of stored, like current-context and
+//     preferences, are kept. Top-level fields stored doesn't have are taken from update.
+//
+// Comments and the order of stored are kept. An empty stored config is replaced by update.
+func mergeKubeConfigs(stored, update []byte) ([]byte, error) {
+	var dst, src yaml.Node
+	if err := yaml.Unmarshal(stored, &dst); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse stored config: %w", ErrInvalid, err)
+	}
+	if err := yaml.Unmarshal(update, &src); err != nil {
+		return nil, fmt.Errorf("%w: failed to parse kubeconfig: %w", ErrInvalid, err)
+	}
+	if len(src.Content) == 0 || src.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: kubeconfig is no mapping", ErrInvalid)
+	}
+	if len(dst.Content) == 0 {
+		return encodeKubeConfig(&src)
+	}
+	if dst.Content[0].Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("%w: stored config is no mapping", ErrInvalid)
+	}
+
+	dstMap, srcMap := dst.Content[0], src.Content[0]
+	for i := 0; i+1 < len(srcMap.Content); i += 2 {
+		key, value := srcMap.Content[i].Value, srcMap.Content[i+1]
+		switch key {
+		case "clusters":
+			mergeNamedList(dstMap, key, value, func(dst, src *yaml.Node) { mergeFields(dst, src, "cluster") })
+		case "contexts":
+			mergeNamedList(dstMap, key, value, func(dst, src *yaml.Node) { mergeFields(dst, src, "context", "namespace") })
+		case "users":
+			mergeNamedList(dstMap, key, value, func(dst, src *yaml.Node) { setMappingValue(dst, "user", mappingValue(src, "user")) })
+		default:
+			if mappingValue(dstMap, key) == nil {
+				setMappingValue(dstMap, key, value)
+			}
+		}
+	}
+	return encodeKubeConfig(&dst)
+}
+
+// mergeNamedList merges the entries of the sequence src into the sequence at key of the
+// mapping dst. Entries with the same name are merged by merge, the others are appended.
+func mergeNamedList(dst *yaml.Node, key string, src *yaml.Node, merge func(dst, src *yaml.Node)) {
+	list := mappingValue(dst, key)
+	if list == nil || list.Kind != yaml.SequenceNode {
+		setMappingValue(dst, key, src)
+		return
+	}
+	if src.Kind != yaml.SequenceNode {
+		return
+	}
+	for _, entry := range src.Content {
+		name := mappingValue(entry, "name")
+		var existing *yaml.Node
+		for _, candidate := range list.Content {
+			if n := mappingValue(candidate, "name"); n != nil && name != nil && n.Value == name.Value {
+				existing = candidate
+				break
+			}
+		}
+		if existing == nil {
+			list.Content = append(list.Content, entry)
+		} else {
+			merge(existing, entry)
+		}
+	}
+}
+
+// mergeFields sets the fields of the mapping at field of src in the one of dst, except the
+// fields in keep which dst already has.
+func mergeFields(dst, src *yaml.Node, field string, keep ...string) {
+	srcBody := mappingValue(src, field)
+	dstBody := mappingValue(dst, field)
+	if srcBody == nil {
+		return
+	}
+	if dstBody == nil || dstBody.Kind != yaml.MappingNode || srcBody.Kind != yaml.MappingNode {
+		setMappingValue(dst, field, srcBody)
+		return
+	}
+	for i := 0; i+1 < len(srcBody.Content); i += 2 {
+		key := srcBody.Content[i].Value
+		if slices.Contains(keep, key) && mappingValue(dstBody, key) != nil {
+			continue
+		}
+		setMappingValue(dstBody, key, srcBody.Content[i+1])
+	}
+}
+
+// mappingValue returns the value of key in the mapping node or nil.
+func mappingValue(node *yaml.Node, key string) *yaml.Node {
+	if node == nil || node.Kind != yaml.MappingNode {
+		return nil
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			return node.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// setMappingValue sets key in the mapping node to value, appending it if it doesn't exist.
+func setMappingValue(node *yaml.Node, key string, value *yaml.Node) {
+	if value == nil {
+		return
+	}
+	for i := 0; i+1 < len(node.Content); i += 2 {
+		if node.Content[i].Value == key {
+			node.Content[i+1] = value
+			return
+		}
+	}
+	node.Content = append(node.Content, &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: key}, value)
+}
+
+// formatKubeConfig formats the kubeconfig data like mergeKubeConfigs does.
+func formatKubeConfig(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if len(doc.Content) == 0 {
+		return nil, nil
+	}
+	return encodeKubeConfig(&doc)
+}
+
+// encodeKubeConfig encodes doc in the style of kubectl.
+func encodeKubeConfig(doc *yaml.Node) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	enc.CompactSeqIndent()
+	if err := enc.Encode(doc); err != nil {
+		return nil, fmt.Errorf("failed to encode kubeconfig: %w", err)
+	}
+	if err := enc.Close(); err != nil {
+		return nil, fmt.Errorf("failed to encode kubeconfig: %w", err)
+	}
+	return buf.Bytes(), nil
+}
+
+// diffConfigs returns the unified diff between the versions of the config name. Every value
+// of the users is replaced by a hash, see redactUsers, so changes still show up.
+func diffConfigs(name string, before, after []byte) (string, error) {
+	before, err := redactUsers(before)
+	if err != nil {
+		return "", fmt.Errorf("failed to diff %s: %w", name, err)
+	}
+	after, err = redactUsers(after)
+	if err != nil {
+		return "", fmt.Errorf("failed to diff %s: %w", name, err)
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		// SplitLines adds the newline to the last line itself
+		A:        difflib.SplitLines(strings.TrimSuffix(string(before), "\n")),
+		B:        difflib.SplitLines(strings.TrimSuffix(string(after), "\n")),
+		FromFile: name,
+		ToFile:   name + " (updated)",
+		Context:  3,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to diff %s: %w", name, err)
+	}
+	return diff, nil
+}
+
+// redactUsers returns the kubeconfig data with every scalar value below users[].user replaced
+// by a hash of the value. Users hold tokens, keys, passwords and the secrets of auth providers
+// and exec plugins under arbitrary keys, so nothing of them is shown.
+func redactUsers(data []byte) ([]byte, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalid, err)
+	}
+	if len(doc.Content) == 0 {
+		return data, nil
+	}
+	if users := mappingValue(doc.Content[0], "users"); users != nil && users.Kind == yaml.SequenceNode {
+		for _, entry := range users.Content {
+			redactValues(mappingValue(entry, "user"))
+		}
+	}
+	return encodeKubeConfig(&doc)
+}
+
+// redactValues replaces the scalar values below node by a hash of the value. The keys of
+// mappings are kept.
+func redactValues(node *yaml.Node) {
+	if node == nil {
+		return
+	}
+	switch node.Kind {
+	case yaml.ScalarNode:
+		node.Value = fmt.Sprintf("<redacted sha256:%.8x>", sha256.Sum256([]byte(node.Value)))
+		node.Tag = "!!str"
+		node.Style = 0
+	case yaml.MappingNode:
+		for i := 1; i < len(node.Content); i += 2 {
+			redactValues(node.Content[i])
+		}
+	case yaml.SequenceNode:
+		for _, item := range node.Content {
+			redactValues(item)
+		}
+	}
+}
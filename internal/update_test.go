@@ -0,0 +1,262 @@
+package internal
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const storedKubeConfig = `apiVersion: v1
+kind: Config
+# our dev cluster
+current-context: dev
+preferences:
+  colors: true
+clusters:
+- name: dev
+  cluster:
+    server: https://old.example.com
+    proxy-url: http://proxy:3128
+- name: local
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: admin
+    namespace: team-a
+users:
+- name: admin
+  user:
+    token: old-token
+`
+
+const refreshedKubeConfig = `apiVersion: v1
+kind: Config
+current-context: admin@dev
+clusters:
+- name: dev
+  cluster:
+    server: https://new.example.com
+    certificate-authority-data: Q0E=
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: admin
+    namespace: default
+- name: admin@dev
+  context:
+    cluster: dev
+    user: admin
+users:
+- name: admin
+  user:
+    client-certificate-data: Q0VSVA==
+    client-key-data: S0VZ
+`
+
+func TestMergeKubeConfigs(t *testing.T) {
+	t.Run("Merge", func(t *testing.T) {
+		merged, err := mergeKubeConfigs([]byte(storedKubeConfig), []byte(refreshedKubeConfig))
+		require.NoError(t, err)
+		assert.Equal(t, `apiVersion: v1
+kind: Config
+# our dev cluster
+current-context: dev
+preferences:
+  colors: true
+clusters:
+- name: dev
+  cluster:
+    server: https://new.example.com
+    proxy-url: http://proxy:3128
+    certificate-authority-data: Q0E=
+- name: local
+  cluster:
+    server: https://127.0.0.1:6443
+contexts:
+- name: dev
+  context:
+    cluster: dev
+    user: admin
+    namespace: team-a
+- name: admin@dev
+  context:
+    cluster: dev
+    user: admin
+users:
+- name: admin
+  user:
+    client-certificate-data: Q0VSVA==
+    client-key-data: S0VZ
+`, string(merged))
+	})
+
+	t.Run("Empty stored config", func(t *testing.T) {
+		merged, err := mergeKubeConfigs(nil, []byte("clusters:\n    - name: dev\n"))
+		require.NoError(t, err)
+		assert.Equal(t, "clusters:\n- name: dev\n", string(merged))
+	})
+
+	t.Run("Idempotent", func(t *testing.T) {
+		merged, err := mergeKubeConfigs([]byte(storedKubeConfig), []byte(refreshedKubeConfig))
+		require.NoError(t, err)
+		again, err := mergeKubeConfigs(merged, []byte(refreshedKubeConfig))
+		require.NoError(t, err)
+		assert.Equal(t, string(merged), string(again))
+	})
+
+	t.Run("Stored config is no mapping", func(t *testing.T) {
+		_, err := mergeKubeConfigs([]byte("- a\n"), []byte(refreshedKubeConfig))
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+}
+
+func TestUpdateConfig(t *testing.T) {
+	setup := func(t *testing.T) (*MemFS, *CO) {
+		t.Helper()
+		fsys, co := initMemCO(t)
+		require.NoError(t, fsys.WriteFile("/home/.kube/co/dev", []byte(storedKubeConfig), 0600))
+		require.NoError(t, fsys.MkdirAll("/tmp", onlyOwnerAccess))
+		require.NoError(t, fsys.WriteFile("/tmp/refreshed", []byte(refreshedKubeConfig), 0600))
+		co.ConfigName = "dev"
+		return fsys, co
+	}
+
+	t.Run("Success", func(t *testing.T) {
+		setAuditIdentity(t, "alice")
+		fsys, co := setup(t)
+		now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+
+		result, err := co.updateConfig(t.Context(), "/tmp/refreshed", false, now)
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+		assert.Equal(t, "dev", result.Name)
+		assert.Equal(t, "/home/.kube/co/.backup/dev-20261018T120000Z", result.Backup)
+		assert.Contains(t, result.Diff, "--- dev\n+++ dev (updated)\n")
+		assert.Contains(t, result.Diff, "-    server: https://old.example.com\n+    server: https://new.example.com\n")
+		assert.Contains(t, result.Diff, "-    token: <redacted sha256:")
+		assert.Contains(t, result.Diff, "+    client-key-data: <redacted sha256:")
+		assert.NotContains(t, result.Diff, "old-token")
+		assert.NotContains(t, result.Diff, "S0VZ")
+
+		backup, err := fsys.ReadFile(result.Backup)
+		require.NoError(t, err)
+		assert.Equal(t, storedKubeConfig, string(backup))
+		updated, err := fsys.ReadFile("/home/.kube/co/dev")
+		require.NoError(t, err)
+		assert.Contains(t, string(updated), "namespace: team-a")
+
+		co, err = NewCO("/home", WithFS(fsys))
+		require.NoError(t, err)
+		co.ConfigName = "dev"
+		result, err = co.UpdateConfig(t.Context(), "/tmp/refreshed", false)
+		require.NoError(t, err)
+		assert.False(t, result.Changed, "nothing changes on the second update")
+		assert.Empty(t, result.Backup)
+		entries, err := co.AuditLog(AuditFilter{Action: AuditUpdate})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "/tmp/refreshed", entries[0].From)
+	})
+
+	t.Run("Backups of the same second are kept", func(t *testing.T) {
+		fsys, co := setup(t)
+		now := time.Date(2026, 10, 18, 12, 0, 0, 0, time.UTC)
+		first, err := co.updateConfig(t.Context(), "/tmp/refreshed", false, now)
+		require.NoError(t, err)
+		require.NoError(t, fsys.WriteFile("/tmp/refreshed", []byte(strings.ReplaceAll(refreshedKubeConfig, "new.example.com", "newer.example.com")), 0600))
+		second, err := co.updateConfig(t.Context(), "/tmp/refreshed", false, now)
+		require.NoError(t, err)
+
+		assert.Equal(t, "/home/.kube/co/.backup/dev-20261018T120000Z", first.Backup)
+		assert.Equal(t, "/home/.kube/co/.backup/dev-20261018T120000Z-2", second.Backup)
+		backup, err := fsys.ReadFile(first.Backup)
+		require.NoError(t, err)
+		assert.Equal(t, storedKubeConfig, string(backup), "the original version is kept")
+		backup, err = fsys.ReadFile(second.Backup)
+		require.NoError(t, err)
+		assert.Contains(t, string(backup), "https://new.example.com")
+	})
+
+	t.Run("Dry run", func(t *testing.T) {
+		fsys, co := setup(t)
+		result, err := co.UpdateConfig(t.Context(), "/tmp/refreshed", true)
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+		assert.NotEmpty(t, result.Diff)
+		assert.Empty(t, result.Backup)
+		stored, err := fsys.ReadFile("/home/.kube/co/dev")
+		require.NoError(t, err)
+		assert.Equal(t, storedKubeConfig, string(stored))
+		_, err = fsys.Stat(co.BackupDir())
+		assert.Error(t, err)
+	})
+
+	t.Run("Every user value is redacted", func(t *testing.T) {
+		fsys, co := setup(t)
+		oidc := func(secret, token string) string {
+			return `apiVersion: v1
+kind: Config
+contexts:
+- name: oidc
+  context:
+    cluster: oidc
+    user: oidc
+users:
+- name: oidc
+  user:
+    auth-provider:
+      name: oidc
+      config:
+        client-id: kubernetes
+        client-secret: ` + secret + `
+        access-token: ` + token + `
+- name: block
+  user:
+    token: |
+      ` + token + `
+      second-line
+`
+		}
+		require.NoError(t, fsys.WriteFile("/home/.kube/co/oidc", []byte(oidc("SUPERSECRET1", "ACCESS1")), 0600))
+		require.NoError(t, fsys.WriteFile("/tmp/oidc", []byte(oidc("SUPERSECRET2", "ACCESS2")), 0600))
+		co.ConfigName = "oidc"
+		result, err := co.UpdateConfig(t.Context(), "/tmp/oidc", true)
+		require.NoError(t, err)
+		require.True(t, result.Changed)
+		for _, secret := range []string{"SUPERSECRET", "ACCESS", "second-line", "kubernetes"} {
+			assert.NotContains(t, result.Diff, secret)
+		}
+		assert.Contains(t, result.Diff, "-        client-secret: <redacted sha256:")
+		assert.Contains(t, result.Diff, "+        client-secret: <redacted sha256:")
+		assert.Contains(t, result.Diff, "+    token: <redacted sha256:")
+		assert.NotContains(t, result.Diff, "token: |")
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		fsys, co := setup(t)
+		require.NoError(t, fsys.WriteFile("/tmp/manifest", []byte("kind: Deployment\n"), 0600))
+		_, err := co.UpdateConfig(t.Context(), "/tmp/manifest", false)
+		assert.ErrorIs(t, err, ErrInvalid)
+
+		co.ConfigName = "missing"
+		_, err = co.UpdateConfig(t.Context(), "/tmp/refreshed", false)
+		assert.ErrorIs(t, err, ErrNotFound)
+
+		WithPrefixMatch(true)(co)
+		co.ConfigName = "pro"
+		_, err = co.UpdateConfig(t.Context(), "/tmp/refreshed", false)
+		assert.ErrorIs(t, err, ErrNotFound, "prefixes aren't updated")
+
+		co.ConfigName = "dev"
+		_, err = co.UpdateConfig(t.Context(), "/tmp/missing", false)
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "failed to read input config file")
+	})
+}
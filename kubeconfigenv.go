@@ -0,0 +1,89 @@
+package main
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/eslog"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "merge",
+		args:  "<name>...",
+		short: "Print statements adding configs to KUBECONFIG",
+		long: `kubectl merges the clusters, users and contexts of all files listed in KUBECONFIG. The
+configs are appended, so the current context stays the same. Without KUBECONFIG the list starts
+with ~/.kube/config. Use it with eval:
+
+  eval "$(kubectl co merge staging prod)"`,
+		minArgs:  1,
+		maxArgs:  -1,
+		complete: func(positional []string) []string { return configNames() },
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeyShell, "", "Shell to print the statements for: "+strings.Join(shells, ", "))
+		},
+		run: func(ctx context.Context, args []string) error {
+			return printKubeConfigEnv(ctx, args, (*co.Manager).Merge)
+		},
+	})
+	registerCommand(&command{
+		name:     "unmerge",
+		args:     "<name>...",
+		short:    "Print statements removing configs from KUBECONFIG",
+		long:     "KUBECONFIG is unset if only ~/.kube/config is left. Use it with eval like merge.",
+		minArgs:  1,
+		maxArgs:  -1,
+		complete: func(positional []string) []string { return configNames() },
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeyShell, "", "Shell to print the statements for: "+strings.Join(shells, ", "))
+		},
+		run: func(ctx context.Context, args []string) error {
+			return printKubeConfigEnv(ctx, args, (*co.Manager).Unmerge)
+		},
+	})
+}
+
+// printKubeConfigEnv prints the statements setting KUBECONFIG to the files returned by update.
+func printKubeConfigEnv(ctx context.Context, names []string, update func(*co.Manager, context.Context, ...string) ([]string, error)) error {
+	shell, err := envShell()
+	if err != nil {
+		return err
+	}
+	manager, err := newManager()
+	if err != nil {
+		return err
+	}
+	logToStderr()
+	files, err := update(manager, ctx, names...)
+	if err != nil {
+		return err
+	}
+	printEnv(os.Stdout, shell, kubeConfigEnv(files))
+	return nil
+}
+
+// kubeConfigEnv returns the change of the environment setting KUBECONFIG to files. No files
+// unset KUBECONFIG.
+func kubeConfigEnv(files []string) co.Env {
+	if len(files) == 0 {
+		return co.Env{Unset: []string{"KUBECONFIG"}}
+	}
+	return co.Env{Set: map[string]string{"KUBECONFIG": co.JoinKubeConfigEnv(files)}}
+}
+
+// logToStderrInEnvMode keeps the output of switching evaluable in env mode.
+func logToStderrInEnvMode() {
+	if config.Mode == co.ModeEnv {
+		logToStderr()
+	}
+}
+
+// logToStderr writes the log to stderr, so it doesn't end up in the statements evaluated by the
+// shell.
+func logToStderr() {
+	eslog.Logger.SetOutput(os.Stderr)
+}
@@ -0,0 +1,114 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"sort"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "label",
+		args:  "<name> key=value... key-...",
+		short: "Add, update or remove labels of a config",
+		long: `Labels select configs, e.g. 'kubectl co ls -l env=prod'. The env label is the environment
+of configs without an explicit environment and picks their color.`,
+		minArgs:    2,
+		maxArgs:    -1,
+		configArgs: 1,
+		run: func(ctx context.Context, args []string) error {
+			set, remove, err := co.ParseLabelChanges(args[1:])
+			if err != nil {
+				return err
+			}
+			return updateMetadata(ctx, args[0], func(md *co.Metadata) error {
+				md.Labels = applyChanges(md.Labels, set, remove)
+				return nil
+			})
+		},
+	})
+	registerCommand(&command{
+		name:       "annotate",
+		args:       "<name> [key=value...] [key-...]",
+		short:      "Set annotations, description, owner or color of a config",
+		long:       "Annotations hold free text. The color is one of " + colorNames() + ".",
+		minArgs:    1,
+		maxArgs:    -1,
+		configArgs: 1,
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeyDescription, "", "Set the description of the config")
+			flags.String(viperKeyOwner, "", "Set the owner of the config")
+			flags.String(viperKeyColor, "", "Set the color the config is listed in")
+		},
+		run: runAnnotate,
+	})
+}
+
+func runAnnotate(ctx context.Context, args []string) error {
+	set, remove, err := co.ParseLabelChanges(args[1:])
+	if err != nil {
+		return err
+	}
+	if len(set) == 0 && len(remove) == 0 && config.Description == "" && config.Owner == "" && config.Color == "" {
+		return fmt.Errorf("%w: nothing to annotate. Provide key=value, key-, --%s, --%s or --%s", co.ErrUsage, viperKeyDescription, viperKeyOwner, viperKeyColor)
+	}
+	if _, ok := colors[config.Color]; config.Color != "" && !ok {
+		return fmt.Errorf("%w: unknown color %q, use one of %s", co.ErrInvalid, config.Color, colorNames())
+	}
+	return updateMetadata(ctx, args[0], func(md *co.Metadata) error {
+		md.Annotations = applyChanges(md.Annotations, set, remove)
+		if config.Description != "" {
+			md.Description = config.Description
+		}
+		if config.Owner != "" {
+			md.Owner = config.Owner
+		}
+		if config.Color != "" {
+			md.Color = config.Color
+		}
+		return nil
+	})
+}
+
+func updateMetadata(ctx context.Context, name string, update func(md *co.Metadata) error) error {
+	manager, err := newManager()
+	if err != nil {
+		return err
+	}
+	cfg, err := manager.UpdateMetadata(ctx, name, update)
+	if err == nil {
+		fmt.Println("Updated", cfg.Name)
+	}
+	return err
+}
+
+// applyChanges returns a copy of values with the set entries added and the removed ones
+// deleted. It returns nil if no entries are left.
+func applyChanges(values, set map[string]string, remove []string) map[string]string {
+	result := maps.Clone(values)
+	if result == nil {
+		result = map[string]string{}
+	}
+	maps.Copy(result, set)
+	for _, key := range remove {
+		delete(result, key)
+	}
+	if len(result) == 0 {
+		return nil
+	}
+	return result
+}
+
+func colorNames() string {
+	names := []string{}
+	for name := range colors {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
@@ -4,25 +4,102 @@ Copyright © 2022 steffakasid
 package main
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	"path"
+	"strconv"
 	"strings"
+	"text/tabwriter"
+	"time"
 
 	"github.com/fatih/color"
 	flag "github.com/spf13/pflag"
 	"github.com/spf13/viper"
 	"github.com/steffakasid/eslog"
-	"github.com/steffakasid/kubectl-co/internal"
+	"github.com/steffakasid/kubectl-co/pkg/co"
 )
 
 type cmdCfg struct {
-	Delete   bool `mapstructure:"delete"`
-	Debug    bool `mapstructure:"debug"`
-	Add      bool `mapstructure:"add"`
-	Previous bool `mapstructure:"previous"`
-	Current  bool `mapstructure:"current"`
+	Delete   bool          `mapstructure:"delete"`
+	Debug    bool          `mapstructure:"debug"`
+	Add      bool          `mapstructure:"add"`
+	Previous bool          `mapstructure:"previous"`
+	Current  bool          `mapstructure:"current"`
+	Store    string        `mapstructure:"store"`
+	All      bool          `mapstructure:"all"`
+	Timeout  time.Duration `mapstructure:"timeout"`
+	Parallel int           `mapstructure:"parallel"`
+	Wide     bool          `mapstructure:"wide"`
+	WarnDays int           `mapstructure:"warn-days"`
+	Yes      bool          `mapstructure:"yes"`
+
+	Environment           string            `mapstructure:"environment"`
+	ProtectedEnvironments []string          `mapstructure:"protected-environments"`
+	Selector              string            `mapstructure:"selector"`
+	Description           string            `mapstructure:"description"`
+	Owner                 string            `mapstructure:"owner"`
+	Color                 string            `mapstructure:"color"`
+	Colors                map[string]string `mapstructure:"colors"`
+	Group                 string            `mapstructure:"group"`
+	Output                string            `mapstructure:"output"`
+	Aliases               []aliasCfg        `mapstructure:"aliases"`
+	PrefixMatch           bool              `mapstructure:"prefix-match"`
+	Hooks                 hooksCfg          `mapstructure:"hooks"`
+	Shell                 string            `mapstructure:"shell"`
+	Format                string            `mapstructure:"format"`
+	JSON                  bool              `mapstructure:"json"`
+	Fix                   bool              `mapstructure:"fix"`
+	Mode                  string            `mapstructure:"mode"`
+	StoreDir              string            `mapstructure:"store-dir"`
+	StateDir              string            `mapstructure:"state-dir"`
+	KubeConfigPath        string            `mapstructure:"kubeconfig-path"`
+	XDG                   bool              `mapstructure:"xdg"`
+	From                  string            `mapstructure:"from"`
+	RelativeLinks         bool              `mapstructure:"relative-links"`
+	Absolute              bool              `mapstructure:"absolute"`
+	AuditLog              bool              `mapstructure:"audit-log"`
+	AuditMaxSize          int64             `mapstructure:"audit-max-size"`
+	AuditMaxFiles         int               `mapstructure:"audit-max-files"`
+	Since                 string            `mapstructure:"since"`
+	Until                 string            `mapstructure:"until"`
+	User                  string            `mapstructure:"user"`
+	Action                string            `mapstructure:"action"`
+	Outcome               string            `mapstructure:"outcome"`
+	Sort                  string            `mapstructure:"sort"`
+	UnusedDays            int               `mapstructure:"unused-days"`
+	Unused                bool              `mapstructure:"unused"`
+	TTL                   time.Duration     `mapstructure:"ttl"`
+	FromDir               string            `mapstructure:"from-dir"`
+	Watch                 bool              `mapstructure:"watch"`
+	DeleteSource          bool              `mapstructure:"delete-source"`
+	FetchTimeout          time.Duration     `mapstructure:"fetch-timeout"`
+	DryRun                bool              `mapstructure:"dry-run"`
+}
+
+// hooksCfg is the hooks section of the config file.
+type hooksCfg struct {
+	Timeout    time.Duration    `mapstructure:"timeout"`
+	PreSwitch  []string         `mapstructure:"pre-switch"`
+	PostSwitch []string         `mapstructure:"post-switch"`
+	Configs    []configHooksCfg `mapstructure:"configs"`
+}
+
+// configHooksCfg are the hooks of a single config. The hooks of configs are a list instead of a
+// map by config name, since viper lowercases map keys and splits them at dots.
+type configHooksCfg struct {
+	Name       string   `mapstructure:"name"`
+	PreSwitch  []string `mapstructure:"pre-switch"`
+	PostSwitch []string `mapstructure:"post-switch"`
+}
+
+// aliasCfg is an alias of the config file. Like the hooks of configs, aliases are a list to keep
+// their case and dots.
+type aliasCfg struct {
+	Alias  string `mapstructure:"alias"`
+	Config string `mapstructure:"config"`
 }
 
 var config *cmdCfg = &cmdCfg{}
@@ -37,38 +114,107 @@ const (
 	viperKeyAdd      = "add"
 	viperKeyHelp     = "help"
 	viperKeyVersion  = "version"
+	viperKeyStore    = "store"
+	viperKeyAll      = "all"
+	viperKeyTimeout  = "timeout"
+	viperKeyParallel = "parallel"
+	viperKeyWide     = "wide"
+	viperKeyWarnDays = "warn-days"
+	viperKeyYes      = "yes"
+
+	viperKeyEnvironment           = "environment"
+	viperKeyProtectedEnvironments = "protected-environments"
+	viperKeySelector              = "selector"
+	viperKeyDescription           = "description"
+	viperKeyOwner                 = "owner"
+	viperKeyColor                 = "color"
+	viperKeyColors                = "colors"
+	viperKeyGroup                 = "group"
+	viperKeyOutput                = "output"
+	viperKeyAliases               = "aliases"
+	viperKeyPrefixMatch           = "prefix-match"
+	viperKeyHooks                 = "hooks"
+	viperKeyShell                 = "shell"
+	viperKeyFormat                = "format"
+	viperKeyJSON                  = "json"
+	viperKeyFix                   = "fix"
+	viperKeyMode                  = "mode"
+	viperKeyStoreDir              = "store-dir"
+	viperKeyStateDir              = "state-dir"
+	viperKeyKubeConfigPath        = "kubeconfig-path"
+	viperKeyXDG                   = "xdg"
+	viperKeyFrom                  = "from"
+	viperKeyRelativeLinks         = "relative-links"
+	viperKeyAbsolute              = "absolute"
+	viperKeyAuditLog              = "audit-log"
+	viperKeyAuditMaxSize          = "audit-max-size"
+	viperKeyAuditMaxFiles         = "audit-max-files"
+	viperKeySince                 = "since"
+	viperKeyUntil                 = "until"
+	viperKeyUser                  = "user"
+	viperKeyAction                = "action"
+	viperKeyOutcome               = "outcome"
+	viperKeySort                  = "sort"
+	viperKeyUnusedDays            = "unused-days"
+	viperKeyUnused                = "unused"
+	viperKeyTTL                   = "ttl"
+	viperKeyFromDir               = "from-dir"
+	viperKeyWatch                 = "watch"
+	viperKeyDeleteSource          = "delete-source"
+	viperKeyFetchTimeout          = "fetch-timeout"
+	viperKeyDryRun                = "dry-run"
 )
 
-func init() {
-	var err error
+// globalFlags returns the flags every command accepts.
+func globalFlags() *flag.FlagSet {
+	flags := flag.NewFlagSet("global", flag.ContinueOnError)
+	flags.BoolP(viperKeyHelp, "h", false, "Show help")
+	flags.Bool(viperKeyDebug, false, "Turn on debug output")
+	flags.Bool(viperKeyVersion, false, "Show version information")
+	flags.String(viperKeyStore, co.StoreBackendDir, "Store backend to keep the configs in (dir or git)")
+	flags.BoolP(viperKeyYes, "y", false, "Don't ask for confirmation before switching to or deleting a protected config")
+	flags.String(viperKeyStoreDir, "", "Directory to keep the configs in (default ~/.kube/co)")
+	flags.String(viperKeyStateDir, "", "Directory to keep the previous link in (default the store directory)")
+	flags.String(viperKeyKubeConfigPath, "", "Path of the kube config link (default ~/.kube/config)")
+	flags.Bool(viperKeyRelativeLinks, false, "Create the kube config and previous links relative to their directory")
+	flags.Bool(viperKeyXDG, false, "Use $XDG_DATA_HOME/kubectl-co as store and $XDG_STATE_HOME/kubectl-co as state directory by default")
+	flags.String(viperKeyMode, co.ModeSymlink, "How to switch configs: symlink links ~/.kube/config, env prints the KUBECONFIG variable to eval")
+	return flags
+}
 
-	flag.BoolP(viperKeyDelete, "d", false, "Delete the config with the given name. Usage: kubectl co --delete [configname]")
-	flag.BoolP(viperKeyAdd, "a", false, "Add a new given config providing the path and the name. Usage: kubectl co --add [configpath] [configname]")
-	flag.BoolP(viperKeyPrevious, "p", false, "Switch to previous config")
-	flag.BoolP(viperKeyCurrent, "c", false, "Show the current config path")
-	flag.BoolP(viperKeyHelp, "h", false, "Show help")
-	flag.Bool(viperKeyDebug, false, "Turn on debug output")
+// legacyFlags returns the flags of the flag based command line which are kept as aliases for
+// the add, rm, prev and current commands.
+func legacyFlags() *flag.FlagSet {
+	flags := flag.NewFlagSet("legacy", flag.ContinueOnError)
+	flags.BoolP(viperKeyDelete, "d", false, "Delete the config with the given name. Same as 'rm'. Usage: kubectl co --delete <configname>")
+	flags.BoolP(viperKeyAdd, "a", false, "Add a new config providing the name and optionally the path to copy from. Same as 'add'. Usage: kubectl co --add <configname> [configpath|-|url] [--ttl <duration>]")
+	flags.BoolP(viperKeyPrevious, "p", false, "Switch to previous config. Same as 'prev'")
+	flags.BoolP(viperKeyCurrent, "c", false, "Show the current config path. Same as 'current'")
+	return flags
+}
 
-	flag.Usage = func() {
-		stdErr := os.Stderr
+func usage() {
+	stdErr := os.Stderr
 
-		_, err := fmt.Fprintf(stdErr, "Usage of %s: \n", os.Args[0])
-		eslog.LogIfErrorf(err, eslog.Fatalf, "Error printing usage: %s")
-		_, err = fmt.Fprintln(stdErr, `
+	_, err := fmt.Fprintf(stdErr, "Usage of %s: \n", os.Args[0])
+	eslog.LogIfErrorf(err, eslog.Fatalf, "Error printing usage: %s")
+	_, err = fmt.Fprintf(stdErr, `
 This tool can be used to work with multiple kube configs. It allows to
 add, delete and switch config files.
 
 NOTE: If you set the KUBECONFIG environment var this will always take precedence before the config file.
+Use --mode env to switch by setting KUBECONFIG instead, e.g. eval "$(kubectl co --mode env use dev)".
 
 Preqrequisites:
   kubectl should be installed (even if the application would also run for it own as 'kubectl-co')
 
 Examples:
-  kubectl co --add new-config ~/.kube/config    - adds your current kubeconfig to be used by co with the name 'new-config'
-  kubectl co --add completly-new                - adds a plain new config file which must be inialised afterwards
-  kubectl co --previous                         - switch to previous config and set current config to previous
-  kubectl co --delete config-name               - delete config with name 'new-config'
-  kubectl co --current                          - show the current config path
+  kubectl co add new-config ~/.kube/config      - adds your current kubeconfig to be used by co with the name 'new-config'
+  kubectl co add completly-new                  - adds a plain new config file which must be inialised afterwards
+  kubectl co add dev - < dev.yaml               - adds the config read from stdin with the name 'dev'
+  kubectl co prev                               - switch to previous config and set current config to previous
+  kubectl co rm config-name                     - delete config with name 'config-name'
+  kubectl co current                            - show the current config path
   kubectl co new-config                         - switch to 'new-config' this will overwrite ~/.kube/config with a symbolic link
   kubectl co                                    - list all available configs
 
@@ -81,16 +227,21 @@ Enable Shell completion:
 
 Usage:
   kubectl co [flags]
-  kubectl-co [flags]
-  kubectl-co completion bash|zsh
+  kubectl co <configname> [flags]
+  kubectl co <command> [flags] [args]
+  kubectl-co <command> [flags] [args]
 
-Flags:`)
-		eslog.LogIfErrorf(err, eslog.Fatalf, "Error printing usage: %s")
+Commands:
+%s
+Use "kubectl co help <command>" or "kubectl co <command> --help" for more information about a command.
 
-		flag.PrintDefaults()
-	}
+Flags:
+%s%s`, commandSummary(), globalFlags().FlagUsages(), legacyFlags().FlagUsages())
+	eslog.LogIfErrorf(err, eslog.Fatalf, "Error printing usage: %s")
+}
 
-	flag.Parse()
+// initConfig reads the config file and the environment.
+func initConfig() {
 	initHome()
 
 	viper.AddConfigPath(path.Join(home, ".config", "kubectl-co"))
@@ -99,92 +250,145 @@ Flags:`)
 	viper.SetEnvPrefix("KUBECTL_CO")
 	viper.SetEnvKeyReplacer(strings.NewReplacer("-", "_"))
 	viper.AutomaticEnv()
-	err = viper.ReadInConfig()
-	eslog.LogIfErrorf(err, eslog.Errorf, "Error reading config: %s")
-	err = viper.BindPFlags(flag.CommandLine)
-	eslog.LogIfErrorf(err, eslog.Fatalf, "Error binding flags: %s")
-
-	err = viper.Unmarshal(config)
-	eslog.LogIfErrorf(err, eslog.Fatalf, "Error unmarshal config: %s")
+	viper.SetDefault(viperKeyWarnDays, co.DefaultWarnDays)
+	viper.SetDefault(viperKeyProtectedEnvironments, co.DefaultProtectedEnvironments)
+	viper.SetDefault(viperKeyColors, defaultEnvColors)
+	viper.SetDefault(viperKeyAuditLog, true)
+	viper.SetDefault(viperKeyUnusedDays, co.DefaultUnusedDays)
+	viper.SetDefault(viperKeyAuditMaxSize, co.DefaultAuditMaxSize)
+	viper.SetDefault(viperKeyAuditMaxFiles, co.DefaultAuditMaxFiles)
+	err := viper.ReadInConfig()
+	var notFound viper.ConfigFileNotFoundError
+	if err != nil && !errors.As(err, &notFound) {
+		eslog.Errorf("Error reading config: %s", err)
+	}
+}
 
-	if config.Debug {
-		err = eslog.Logger.SetLogLevel("debug")
-		eslog.LogIfErrorf(err, eslog.Fatalf, "Error SetLogLevel(debug): %s")
-	} else {
-		err = eslog.Logger.SetLogLevel("info")
-		eslog.LogIfErrorf(err, eslog.Fatalf, "Error SetLogLevel(info): %s")
+// bindFlags binds the parsed flags to viper and updates config and the log level.
+func bindFlags(flags *flag.FlagSet) error {
+	if err := viper.BindPFlags(flags); err != nil {
+		return fmt.Errorf("error binding flags: %w", err)
 	}
 
-	if isCompletionInvocation() {
-		handleCompletion()
-		os.Exit(0)
+	if err := viper.Unmarshal(config); err != nil {
+		return fmt.Errorf("error unmarshal config: %w", err)
+	}
+	if err := validateConfigLists(); err != nil {
+		return err
 	}
 
+	level := "info"
+	if config.Debug {
+		level = "debug"
+	}
+	return eslog.Logger.SetLogLevel(level)
 }
 
 func main() {
-	if viper.GetBool(viperKeyVersion) {
-		fmt.Printf("kubectl-co version: %s\n", version)
-	} else if viper.GetBool(viperKeyHelp) {
-		flag.Usage()
-	} else {
-		args := flag.Args()
-		if len(args) > 0 && args[0] == "completion" {
-			handleCompletionCommand(args[1:])
-			return
+	initConfig()
+	if isCompletionInvocation() {
+		if err := bindFlags(globalFlags()); err == nil {
+			handleCompletion()
 		}
-		err := validateFlags(args)
-		eslog.LogIfErrorf(err, eslog.Fatalf, "Error validating flags: %s")
-
-		execute(args)
+		return
 	}
+
+	cmd, args, err := parseCommandLine(os.Args[1:])
+	exitOnError(err, "Error validating flags: %s")
+
+	ctx := context.Background()
+	collectExpiredBefore(ctx, cmd)
+	err = cmd.run(ctx, args)
+	exitOnError(err, "Error on execute: %s")
 }
 
 func validateFlags(args []string) error {
 	eslog.Debugf("config %s", toString(config))
 
 	if (config.Current && config.Previous) || (config.Delete && config.Previous) || (config.Delete && config.Current) || (config.Add && config.Previous) || (config.Add && config.Current) || (config.Add && config.Delete) {
-		return fmt.Errorf("%s, %s, %s and %s are exklusiv just use one at a time", viperKeyAdd, viperKeyDelete, viperKeyPrevious, viperKeyCurrent)
+		return fmt.Errorf("%w: %s, %s, %s and %s are exklusiv just use one at a time", co.ErrUsage, viperKeyAdd, viperKeyDelete, viperKeyPrevious, viperKeyCurrent)
 	} else if config.Delete && len(args) != 1 {
-		return fmt.Errorf("when using %s you must only provide the name of the config to be deleted", viperKeyDelete)
+		return fmt.Errorf("%w: when using %s you must only provide the name of the config to be deleted", co.ErrUsage, viperKeyDelete)
 	} else if config.Add && (len(args) == 0 || len(args) > 2) {
-		return fmt.Errorf("when using %s you must provide the path as first argument and the name of the config as second argument", viperKeyAdd)
+		return fmt.Errorf("%w: when using %s you must provide the name of the config as first argument and optionally the path as second argument", co.ErrUsage, viperKeyAdd)
 	} else if config.Previous && len(args) != 0 {
-		return fmt.Errorf("%s doesn't take any arguments", viperKeyPrevious)
+		return fmt.Errorf("%w: %s doesn't take any arguments", co.ErrUsage, viperKeyPrevious)
+	} else if config.Current && len(args) != 0 {
+		return fmt.Errorf("%w: %s doesn't take any arguments", co.ErrUsage, viperKeyCurrent)
 	}
 	return nil
 }
 
-func execute(args []string) {
-	var err error
+// newManager returns a Manager configured from the viper config.
+func newManager() (*co.Manager, error) {
+	return co.NewManager(home, managerOptions()...)
+}
+
+// managerOptions returns the Manager options of the settings in config.
+func managerOptions() []co.Option {
+	return append(locationOptions(),
+		co.WithBackend(config.Store),
+		co.WithWarnDays(config.WarnDays),
+		co.WithProtectedEnvironments(config.ProtectedEnvironments...),
+		co.WithConfirm(confirmProtected),
+		co.WithAliases(configAliases()),
+		co.WithPrefixMatch(config.PrefixMatch),
+		co.WithHooks(hooks()),
+		co.WithMode(config.Mode),
+		co.WithRelativeLinks(config.RelativeLinks),
+		co.WithAuditLog(config.AuditLog, config.AuditMaxSize, config.AuditMaxFiles),
+		co.WithFetchTimeout(config.FetchTimeout),
+		co.WithReservedNames(reservedNames()...),
+	)
+}
 
-	co, err := internal.NewCO(home)
-	eslog.LogIfErrorf(err, eslog.Fatalf, "Error initializing co: %s")
-	if len(args) > 0 {
-		co.ConfigName = args[0]
+// hooks returns the hooks of the config file and the hook scripts in
+// ~/.config/kubectl-co/hooks.
+func hooks() co.Hooks {
+	hooks := co.Hooks{
+		PreSwitch:  config.Hooks.PreSwitch,
+		PostSwitch: config.Hooks.PostSwitch,
+		Configs:    map[string]co.ConfigHooks{},
+		Dir:        path.Join(home, ".config", "kubectl-co", viperKeyHooks),
+		Timeout:    config.Hooks.Timeout,
+	}
+	for _, configHooks := range config.Hooks.Configs {
+		hooks.Configs[configHooks.Name] = co.ConfigHooks{PreSwitch: configHooks.PreSwitch, PostSwitch: configHooks.PostSwitch}
 	}
+	return hooks
+}
 
-	if config.Add {
-		copyConfigFrom := ""
-		if len(args) == 2 {
-			copyConfigFrom = args[1]
-		}
-		err = co.AddConfig(copyConfigFrom)
-		if err != nil {
-			err = co.LinkKubeConfig()
+// configAliases returns the aliases of the config file by alias.
+func configAliases() map[string]string {
+	aliases := map[string]string{}
+	for _, alias := range config.Aliases {
+		aliases[alias.Alias] = alias.Config
+	}
+	return aliases
+}
+
+// validateConfigLists checks that every entry of the hooks of configs and of the aliases names
+// its config. Entries in the map format of earlier versions are decoded without a name.
+func validateConfigLists() error {
+	for _, configHooks := range config.Hooks.Configs {
+		if configHooks.Name == "" {
+			return fmt.Errorf("%w: every entry of %s.configs needs a name", co.ErrUsage, viperKeyHooks)
 		}
-	} else if config.Delete {
-		err = co.DeleteConfig()
-	} else if config.Previous || len(args) == 1 {
-		err = co.LinkKubeConfig()
-	} else {
-		err = co.ListConfigs()
-
-		if err == nil {
-			printConfigs(co)
+	}
+	for _, alias := range config.Aliases {
+		if alias.Alias == "" || alias.Config == "" {
+			return fmt.Errorf("%w: every entry of %s needs an alias and a config", co.ErrUsage, viperKeyAliases)
 		}
 	}
-	eslog.LogIfErrorf(err, eslog.Fatalf, "Error on execute: %s")
+	return nil
+}
+
+// exitOnError logs err and terminates the process with the exit code of the error category.
+func exitOnError(err error, format string) {
+	if err != nil {
+		eslog.Errorf(format, err)
+		os.Exit(co.ExitCode(err))
+	}
 }
 
 func toString(obj any) string {
@@ -195,15 +399,129 @@ func toString(obj any) string {
 	return string(bt)
 }
 
-func printConfigs(co *internal.CO) {
-	red := color.New(color.FgRed)
+// printSwitch reports a switch. In env mode it prints the statements setting KUBECONFIG instead.
+func printSwitch(result co.SwitchResult) error {
+	if result.KubeConfigEnv == nil {
+		fmt.Printf("Linked %s to %s\n", result.KubeConfigPath, result.Config.Path)
+		return nil
+	}
+	shell, err := envShell()
+	if err != nil {
+		return err
+	}
+	printEnv(os.Stdout, shell, kubeConfigEnv(result.KubeConfigEnv))
+	return nil
+}
+
+func printConfigs(ctx context.Context, manager *co.Manager, configs []co.Config) {
+	if config.Wide {
+		printConfigsWide(ctx, manager, configs)
+		return
+	}
+
+	for _, cfg := range configs {
+		c := configColor(cfg)
+		if cfg.Current {
+			c.Add(color.Bold, color.Underline)
+		}
+		_, err := c.Println(cfg.Name)
+		eslog.LogIfErrorf(err, eslog.Errorf, "Error printing config: %s")
+	}
+}
+
+// colors are the names usable in the color metadata and the colors setting.
+var colors = map[string]color.Attribute{
+	"black":   color.FgBlack,
+	"red":     color.FgRed,
+	"green":   color.FgGreen,
+	"yellow":  color.FgYellow,
+	"blue":    color.FgBlue,
+	"magenta": color.FgMagenta,
+	"cyan":    color.FgCyan,
+	"white":   color.FgWhite,
+}
+
+// defaultEnvColors are the colors of the environments if the colors setting is not configured.
+var defaultEnvColors = map[string]string{
+	"prod":        "red",
+	"production":  "red",
+	"staging":     "yellow",
+	"dev":         "green",
+	"development": "green",
+}
 
-	for _, config := range co.Configs {
-		if strings.HasSuffix(co.CurrentConfigPath, config) {
-			_, err := red.Println(config)
-			eslog.LogIfErrorf(err, eslog.Errorf, "Error printing config: %s")
-		} else {
-			fmt.Println(config)
+// configColor returns the color of the config: its own color or the color of its environment.
+func configColor(cfg co.Config) *color.Color {
+	if attr, ok := colors[configColorName(cfg.Metadata.Color, cfg.Environment)]; ok {
+		return color.New(attr)
+	}
+	return color.New(color.Reset)
+}
+
+// configColorName returns the color of a config: its own color or the color of its environment.
+func configColorName(own, environment string) string {
+	if own != "" {
+		return own
+	}
+	return config.Colors[strings.ToLower(environment)]
+}
+
+// expiryKinds are the columns of the wide listing.
+var expiryKinds = []string{co.ExpiryClientCertificate, co.ExpiryCertificateAuthority, co.ExpiryToken}
+
+// printConfigsWide prints a table with the metadata and the earliest expiry of each kind of
+// credential per config.
+func printConfigsWide(ctx context.Context, manager *co.Manager, configs []co.Config) {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "CURRENT\tNAME\tENV\tLABELS\tOWNER\tLAST-USED\tSWITCHES\tCLIENT-CERTIFICATE\tCERTIFICATE-AUTHORITY\tTOKEN\tDESCRIPTION")
+	now := time.Now()
+	for _, cfg := range configs {
+		current := ""
+		if cfg.Current {
+			current = "*"
+		}
+		lastUsed := ""
+		if !cfg.Metadata.LastUsed.IsZero() {
+			lastUsed = cfg.Metadata.LastUsed.Local().Format(time.DateTime)
+		}
+		columns := []string{
+			current,
+			cfg.Name,
+			orDash(cfg.Environment),
+			orDash(co.FormatLabels(cfg.Metadata.Labels)),
+			orDash(cfg.Metadata.Owner),
+			orDash(lastUsed),
+			strconv.Itoa(cfg.Metadata.SwitchCount),
+		}
+		expiries, err := manager.Expiries(ctx, cfg.Name)
+		if err != nil {
+			eslog.Debugf("Error reading expiries of %s: %s", cfg.Name, err)
+		}
+		for _, kind := range expiryKinds {
+			columns = append(columns, formatExpiry(expiries, kind, now))
+		}
+		columns = append(columns, cfg.Metadata.Description)
+		fmt.Fprintln(w, strings.Join(columns, "\t"))
+	}
+	eslog.LogIfErrorf(w.Flush(), eslog.Errorf, "Error printing configs: %s")
+}
+
+// formatExpiry returns the earliest expiry of the given kind. Expiries within the warn-days
+// threshold are marked.
+func formatExpiry(expiries []co.Expiry, kind string, now time.Time) string {
+	for _, expiry := range expiries {
+		if expiry.Kind != kind {
+			continue
+		}
+		date := expiry.NotAfter.Format(time.DateOnly)
+		switch {
+		case expiry.Expired(now):
+			return date + " (expired)"
+		case expiry.ExpiresWithin(config.WarnDays, now):
+			return fmt.Sprintf("%s (in %d days)", date, daysUntil(expiry.NotAfter, now))
+		default:
+			return date
 		}
 	}
+	return "-"
 }
@@ -0,0 +1,765 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"os"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/spf13/viper"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func resetConfig(t *testing.T) {
+	t.Helper()
+	viper.Reset()
+	config = &cmdCfg{}
+	t.Cleanup(func() {
+		viper.Reset()
+		config = &cmdCfg{}
+	})
+}
+
+func TestParseCommandLine(t *testing.T) {
+	tests := map[string]struct {
+		argv    []string
+		command string
+		args    []string
+	}{
+		"No arguments lists":     {argv: []string{}, command: "ls", args: nil},
+		"Config name switches":   {argv: []string{"dev"}, command: "use", args: []string{"dev"}},
+		"Use":                    {argv: []string{"use", "dev"}, command: "use", args: []string{"dev"}},
+		"Switch alias":           {argv: []string{"switch", "dev"}, command: "use", args: []string{"dev"}},
+		"Add with path":          {argv: []string{"add", "dev", "/tmp/config"}, command: "add", args: []string{"dev", "/tmp/config"}},
+		"Delete alias":           {argv: []string{"delete", "dev"}, command: "rm", args: []string{"dev"}},
+		"Rename":                 {argv: []string{"mv", "dev", "prod"}, command: "mv", args: []string{"dev", "prod"}},
+		"Global flag first":      {argv: []string{"--debug", "rm", "dev"}, command: "rm", args: []string{"dev"}},
+		"Global flag last":       {argv: []string{"rm", "dev", "--debug"}, command: "rm", args: []string{"dev"}},
+		"Legacy add":             {argv: []string{"--add", "dev"}, command: "add", args: []string{"dev"}},
+		"Legacy add from stdin":  {argv: []string{"--add", "dev", "-"}, command: "add", args: []string{"dev", "-"}},
+		"Add from stdin":         {argv: []string{"add", "dev", "-"}, command: "add", args: []string{"dev", "-"}},
+		"Legacy delete":          {argv: []string{"-d", "dev"}, command: "rm", args: []string{"dev"}},
+		"Legacy previous":        {argv: []string{"--previous"}, command: "prev", args: []string{}},
+		"Legacy current":         {argv: []string{"-c"}, command: "current", args: []string{}},
+		"Version flag":           {argv: []string{"--version"}, command: "version", args: nil},
+		"Help flag":              {argv: []string{"--help"}, command: "help", args: nil},
+		"Command help flag":      {argv: []string{"rm", "-h"}, command: "help", args: []string{"rm"}},
+		"Help command":           {argv: []string{"help", "add"}, command: "help", args: []string{"add"}},
+		"Completion":             {argv: []string{"completion", "zsh"}, command: "completion", args: []string{"zsh"}},
+		"Check":                  {argv: []string{"check", "--all"}, command: "check", args: []string{}},
+		"Config named like flag": {argv: []string{"use", "--", "-dev"}, command: "use", args: []string{"-dev"}},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resetConfig(t)
+			cmd, args, err := parseCommandLine(test.argv)
+			require.NoError(t, err)
+			assert.Equal(t, test.command, cmd.name)
+			assert.Equal(t, test.args, args)
+		})
+	}
+
+	t.Run("Flags are bound", func(t *testing.T) {
+		resetConfig(t)
+		_, _, err := parseCommandLine([]string{"--store", "git", "ls", "--debug"})
+		require.NoError(t, err)
+		assert.Equal(t, "git", config.Store)
+		assert.True(t, config.Debug)
+	})
+
+	t.Run("Legacy add binds ttl", func(t *testing.T) {
+		resetConfig(t)
+		cmd, args, err := parseCommandLine([]string{"--debug", "--add", "breakglass", "/tmp/config", "--ttl", "8h"})
+		require.NoError(t, err)
+		assert.Equal(t, "add", cmd.name)
+		assert.Equal(t, []string{"breakglass", "/tmp/config"}, args)
+		assert.Equal(t, 8*time.Hour, config.TTL)
+		assert.True(t, config.Debug)
+	})
+
+	t.Run("Fetch timeout is bound", func(t *testing.T) {
+		resetConfig(t)
+		_, args, err := parseCommandLine([]string{"add", "dev", "https://example.com/dev", "--fetch-timeout", "5s"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dev", "https://example.com/dev"}, args)
+		assert.Equal(t, 5*time.Second, config.FetchTimeout)
+	})
+
+	t.Run("Update dry run is bound", func(t *testing.T) {
+		resetConfig(t)
+		cmd, args, err := parseCommandLine([]string{"update", "dev", "/tmp/config", "--dry-run"})
+		require.NoError(t, err)
+		assert.Equal(t, "update", cmd.name)
+		assert.Equal(t, []string{"dev", "/tmp/config"}, args)
+		assert.True(t, config.DryRun)
+	})
+
+	t.Run("Import flags are bound", func(t *testing.T) {
+		resetConfig(t)
+		cmd, _, err := parseCommandLine([]string{"import", "--from-dir", "~/Downloads", "--watch", "--delete-source"})
+		require.NoError(t, err)
+		assert.Equal(t, "import", cmd.name)
+		assert.Equal(t, "~/Downloads", config.FromDir)
+		assert.True(t, config.Watch)
+		assert.True(t, config.DeleteSource)
+	})
+
+	t.Run("Check flags are bound", func(t *testing.T) {
+		resetConfig(t)
+		_, _, err := parseCommandLine([]string{"check", "--all", "--timeout", "2s", "--parallel", "8"})
+		require.NoError(t, err)
+		assert.True(t, config.All)
+		assert.Equal(t, 2*time.Second, config.Timeout)
+		assert.Equal(t, 8, config.Parallel)
+	})
+
+	t.Run("Wide and warn days are bound", func(t *testing.T) {
+		resetConfig(t)
+		viper.Set(viperKeyWarnDays, 7)
+		_, _, err := parseCommandLine([]string{"ls", "-w"})
+		require.NoError(t, err)
+		assert.True(t, config.Wide)
+		assert.Equal(t, 7, config.WarnDays)
+	})
+
+	t.Run("Protection flags are bound", func(t *testing.T) {
+		resetConfig(t)
+		viper.Set(viperKeyProtectedEnvironments, "prod,live")
+		_, _, err := parseCommandLine([]string{"protect", "prod", "--environment", "live", "-y"})
+		require.NoError(t, err)
+		assert.Equal(t, "live", config.Environment)
+		assert.Equal(t, []string{"prod", "live"}, config.ProtectedEnvironments)
+		assert.True(t, config.Yes)
+	})
+
+	t.Run("Metadata flags are bound", func(t *testing.T) {
+		resetConfig(t)
+		_, args, err := parseCommandLine([]string{"annotate", "dev", "--description", "Dev cluster", "--owner", "ops", "--color", "red", "note=x"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dev", "note=x"}, args)
+		assert.Equal(t, "Dev cluster", config.Description)
+		assert.Equal(t, "ops", config.Owner)
+		assert.Equal(t, "red", config.Color)
+
+		resetConfig(t)
+		_, args, err = parseCommandLine([]string{"label", "dev", "env=dev", "team-"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"dev", "env=dev", "team-"}, args)
+
+		resetConfig(t)
+		_, _, err = parseCommandLine([]string{"ls", "-l", "env=prod"})
+		require.NoError(t, err)
+		assert.Equal(t, "env=prod", config.Selector)
+	})
+
+	t.Run("Group flags are bound", func(t *testing.T) {
+		for _, argv := range [][]string{{"ls", "--group", "customer-a"}, {"check", "--group=customer-a"}, {"rm", "--group", "customer-a"}} {
+			resetConfig(t)
+			_, args, err := parseCommandLine(argv)
+			require.NoError(t, err, argv)
+			assert.Empty(t, args, argv)
+			assert.Equal(t, "customer-a", config.Group, argv)
+		}
+
+		resetConfig(t)
+		cmd, args, err := parseCommandLine([]string{"export", "--group", "customer-a", "-o", "a.tar.gz"})
+		require.NoError(t, err)
+		assert.Equal(t, "export", cmd.name)
+		assert.Empty(t, args)
+		assert.Equal(t, "a.tar.gz", config.Output)
+	})
+}
+
+func TestFormatExpiry(t *testing.T) {
+	resetConfig(t)
+	config.WarnDays = 30
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	expiries := []co.Expiry{
+		{Kind: co.ExpiryToken, Name: "sa", NotAfter: now.Add(-time.Hour)},
+		{Kind: co.ExpiryClientCertificate, Name: "admin", NotAfter: now.AddDate(0, 0, 10)},
+		{Kind: co.ExpiryClientCertificate, Name: "other", NotAfter: now.AddDate(0, 0, 20)},
+		{Kind: co.ExpiryCertificateAuthority, Name: "cluster", NotAfter: now.AddDate(1, 0, 0)},
+	}
+
+	assert.Equal(t, "2026-01-01 (expired)", formatExpiry(expiries, co.ExpiryToken, now))
+	assert.Equal(t, "2026-01-11 (in 10 days)", formatExpiry(expiries, co.ExpiryClientCertificate, now))
+	assert.Equal(t, "2027-01-01", formatExpiry(expiries, co.ExpiryCertificateAuthority, now))
+	assert.Equal(t, "-", formatExpiry(nil, co.ExpiryToken, now))
+}
+
+func TestConfirmProtected(t *testing.T) {
+	tests := map[string]struct {
+		input string
+		yes   bool
+		want  bool
+	}{
+		"Name typed":     {input: "prod\n", want: true},
+		"Without enter":  {input: "prod", want: true},
+		"Other name":     {input: "dev\n", want: false},
+		"No input":       {input: "", want: false},
+		"Yes flag":       {input: "", yes: true, want: true},
+		"Surrounding ws": {input: "  prod \n", want: true},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resetConfig(t)
+			config.Yes = test.yes
+			setConfirmInput(t, test.input)
+
+			assert.Equal(t, test.want, confirmProtected("prod"))
+			if !test.yes {
+				assert.Contains(t, confirmOutput.(*bytes.Buffer).String(), "prod is protected")
+			}
+		})
+	}
+
+	t.Run("Several prompts", func(t *testing.T) {
+		resetConfig(t)
+		setConfirmInput(t, "a\nb\n")
+
+		assert.True(t, confirmProtected("a"))
+		assert.True(t, confirmProtected("b"), "the second answer isn't lost in the buffer of the first prompt")
+		assert.False(t, confirmProtected("c"))
+	})
+}
+
+// setConfirmInput answers confirmations with input and captures the prompts.
+func setConfirmInput(t *testing.T, input string) {
+	t.Helper()
+	confirmInput = bufio.NewReader(strings.NewReader(input))
+	confirmOutput = &bytes.Buffer{}
+	t.Cleanup(func() {
+		confirmInput = bufio.NewReader(os.Stdin)
+		confirmOutput = os.Stderr
+	})
+}
+
+func TestApplyChanges(t *testing.T) {
+	assert.Equal(t, map[string]string{"env": "prod", "team": "ops"}, applyChanges(nil, map[string]string{"env": "prod", "team": "ops"}, nil))
+	assert.Equal(t, map[string]string{"env": "dev"}, applyChanges(map[string]string{"env": "prod", "team": "ops"}, map[string]string{"env": "dev"}, []string{"team"}))
+	assert.Nil(t, applyChanges(map[string]string{"team": "ops"}, nil, []string{"team", "missing"}))
+
+	values := map[string]string{"env": "prod"}
+	applyChanges(values, map[string]string{"env": "dev"}, nil)
+	assert.Equal(t, map[string]string{"env": "prod"}, values)
+}
+
+func TestConfigColor(t *testing.T) {
+	resetConfig(t)
+	config.Colors = defaultEnvColors
+
+	assert.Equal(t, color.New(color.FgRed), configColor(co.Config{Environment: "prod"}))
+	assert.Equal(t, color.New(color.FgRed), configColor(co.Config{Environment: "PROD"}))
+	assert.Equal(t, color.New(color.FgCyan), configColor(co.Config{Environment: "prod", Metadata: co.Metadata{Color: "cyan"}}))
+	assert.Equal(t, color.New(color.Reset), configColor(co.Config{Environment: "qa"}))
+	assert.Equal(t, color.New(color.Reset), configColor(co.Config{Metadata: co.Metadata{Color: "pink"}}))
+}
+
+func TestHooksConfig(t *testing.T) {
+	resetConfig(t)
+	home = "/home/user"
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(`
+hooks:
+  timeout: 5s
+  pre-switch:
+    - aws sso login
+  post-switch:
+    - tmux refresh-client -S
+  configs:
+    - name: prod
+      pre-switch:
+        - ./check-vpn
+    - name: Prod-EKS
+      pre-switch:
+        - ./check-eks
+    - name: dev.example.com
+      post-switch:
+        - ./notify
+`)))
+	require.NoError(t, bindFlags(globalFlags()))
+
+	assert.Equal(t, co.Hooks{
+		PreSwitch:  []string{"aws sso login"},
+		PostSwitch: []string{"tmux refresh-client -S"},
+		Configs: map[string]co.ConfigHooks{
+			"prod":            {PreSwitch: []string{"./check-vpn"}},
+			"Prod-EKS":        {PreSwitch: []string{"./check-eks"}},
+			"dev.example.com": {PostSwitch: []string{"./notify"}},
+		},
+		Dir:     "/home/user/.config/kubectl-co/hooks",
+		Timeout: 5 * time.Second,
+	}, hooks())
+}
+
+func TestAliasesConfig(t *testing.T) {
+	resetConfig(t)
+	viper.SetConfigType("yaml")
+	require.NoError(t, viper.ReadConfig(strings.NewReader(`
+aliases:
+  - alias: STG
+    config: Staging-EKS
+  - alias: dev.local
+    config: dev.example.com
+`)))
+	require.NoError(t, bindFlags(globalFlags()))
+
+	assert.Equal(t, map[string]string{"STG": "Staging-EKS", "dev.local": "dev.example.com"}, configAliases())
+}
+
+func TestConfigListsOfEarlierVersions(t *testing.T) {
+	tests := map[string]string{
+		"Hooks": `
+hooks:
+  configs:
+    prod:
+      pre-switch:
+        - ./check-vpn
+`,
+		"Aliases": `
+aliases:
+  stg: staging
+`,
+	}
+	for name, content := range tests {
+		t.Run(name, func(t *testing.T) {
+			resetConfig(t)
+			viper.SetConfigType("yaml")
+			require.NoError(t, viper.ReadConfig(strings.NewReader(content)))
+			assert.Error(t, bindFlags(globalFlags()))
+		})
+	}
+}
+
+func TestPrintEnv(t *testing.T) {
+	env := co.Env{
+		Set:   map[string]string{"AWS_PROFILE": "dev", "NOTE": `it's a \ test`},
+		Unset: []string{"HTTPS_PROXY"},
+	}
+	tests := map[string]string{
+		"bash": "unset HTTPS_PROXY;\nexport AWS_PROFILE='dev';\nexport NOTE='it'\\''s a \\ test';\n",
+		"zsh":  "unset HTTPS_PROXY;\nexport AWS_PROFILE='dev';\nexport NOTE='it'\\''s a \\ test';\n",
+		"fish": "set -e HTTPS_PROXY;\nset -gx AWS_PROFILE 'dev';\nset -gx NOTE 'it\\'s a \\\\ test';\n",
+	}
+
+	for shell, want := range tests {
+		t.Run(shell, func(t *testing.T) {
+			out := &bytes.Buffer{}
+			printEnv(out, shell, env)
+			assert.Equal(t, want, out.String())
+		})
+	}
+}
+
+func TestEnvShell(t *testing.T) {
+	resetConfig(t)
+	t.Setenv("SHELL", "/usr/bin/fish")
+	shell, err := envShell()
+	require.NoError(t, err)
+	assert.Equal(t, "fish", shell)
+
+	t.Setenv("SHELL", "/bin/tcsh")
+	shell, err = envShell()
+	require.NoError(t, err)
+	assert.Equal(t, "bash", shell)
+
+	config.Shell = "zsh"
+	shell, err = envShell()
+	require.NoError(t, err)
+	assert.Equal(t, "zsh", shell)
+
+	config.Shell = "powershell"
+	_, err = envShell()
+	require.ErrorIs(t, err, co.ErrUsage)
+}
+
+func TestFormatPrompt(t *testing.T) {
+	resetConfig(t)
+	config.WarnDays = 30
+	config.Colors = defaultEnvColors
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	info := co.PromptInfo{Name: "dev", Context: "admin", Namespace: "payments", Environment: "prod", Protected: true}
+
+	tests := map[string]struct {
+		format string
+		shell  string
+		expiry *co.Expiry
+		want   string
+	}{
+		"Default":        {format: defaultPromptFormat, want: "\x1b[31mdev\x1b[0m"},
+		"Bash":           {format: defaultPromptFormat, shell: "bash", want: "\\[\x1b[31m\\]dev\\[\x1b[0m\\]"},
+		"Zsh":            {format: defaultPromptFormat, shell: "zsh", want: "%{\x1b[31m%}dev%{\x1b[0m%}"},
+		"All fields":     {format: "{name}/{context}/{namespace}/{env}{protected}", want: "dev/admin/payments/prod!"},
+		"Expiring":       {format: "{name}{expiry}", expiry: &co.Expiry{NotAfter: now.AddDate(0, 0, 3)}, want: "dev [expires in 3 days]"},
+		"Expired":        {format: "{name}{expiry}", expiry: &co.Expiry{NotAfter: now.Add(-time.Hour)}, want: "dev [expired]"},
+		"Not expiring":   {format: "{name}{expiry}", expiry: &co.Expiry{NotAfter: now.AddDate(1, 0, 0)}, want: "dev"},
+		"Unknown fields": {format: "{name} {unknown}", want: "dev {unknown}"},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			info := info
+			info.Expiry = test.expiry
+			assert.Equal(t, test.want, formatPrompt(test.format, test.shell, info, now))
+		})
+	}
+
+	t.Run("NO_COLOR", func(t *testing.T) {
+		t.Setenv("NO_COLOR", "1")
+		assert.Equal(t, "dev", formatPrompt(defaultPromptFormat, "", info, now))
+	})
+}
+
+func TestPrintPromptJSON(t *testing.T) {
+	resetConfig(t)
+	config.WarnDays = 30
+	now := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+	out := &bytes.Buffer{}
+	info := co.PromptInfo{Name: "dev", Path: "/home/.kube/co/dev", Color: "cyan", Expiry: &co.Expiry{NotAfter: now.AddDate(0, 0, 3)}}
+	require.NoError(t, printPromptJSON(out, info, now))
+	assert.JSONEq(t, `{"name":"dev","path":"/home/.kube/co/dev","context":"","namespace":"","environment":"","color":"cyan",
+		"protected":false,"expiresAt":"2026-01-04T12:00:00Z","expiring":true,"expired":false}`, out.String())
+
+	out.Reset()
+	require.NoError(t, printPromptJSON(out, co.PromptInfo{Path: "/elsewhere"}, now))
+	assert.JSONEq(t, `{"name":"","path":"/elsewhere","context":"","namespace":"","environment":"","color":"",
+		"protected":false,"expiring":false,"expired":false}`, out.String())
+}
+
+func TestPrintProblems(t *testing.T) {
+	out := &bytes.Buffer{}
+	printProblems(out, []co.Problem{
+		{Severity: co.SeverityWarning, Path: "/home/.kube/co/previous", Message: "previous links to the missing file /gone", Fix: "remove the link", Fixed: true},
+		{Severity: co.SeverityError, Path: "/home/.kube/co/broken", Message: "config broken is not a valid kubeconfig"},
+		{Severity: co.SeverityWarning, Path: "/home/.kube/co", Message: "config store is accessible by other users (mode 0755)", Fix: "restrict the permissions to 0700", FixErr: errors.New("permission denied")},
+	})
+	assert.Equal(t, `SEVERITY  PATH                     PROBLEM                                                FIX
+warning   /home/.kube/co/previous  previous links to the missing file /gone               remove the link (fixed)
+error     /home/.kube/co/broken    config broken is not a valid kubeconfig                -
+warning   /home/.kube/co           config store is accessible by other users (mode 0755)  restrict the permissions to 0700 (failed: permission denied)
+`, out.String())
+}
+
+func TestKubeConfigEnv(t *testing.T) {
+	out := &bytes.Buffer{}
+	printEnv(out, "bash", kubeConfigEnv([]string{"/home/.kube/co/dev", "/tmp/extra"}))
+	assert.Equal(t, "export KUBECONFIG='/home/.kube/co/dev:/tmp/extra';\n", out.String())
+
+	out.Reset()
+	printEnv(out, "fish", kubeConfigEnv(nil))
+	assert.Equal(t, "set -e KUBECONFIG;\n", out.String())
+}
+
+func TestParseCommandLineErrors(t *testing.T) {
+	tests := map[string][]string{
+		"Unknown flag":             {"--unknown"},
+		"Unknown command flag":     {"ls", "--unknown"},
+		"Add without name":         {"add"},
+		"Add too many arguments":   {"add", "dev", "path", "extra"},
+		"Rename with one argument": {"mv", "dev"},
+		"List with argument":       {"ls", "dev"},
+		"Switch too many":          {"dev", "prod"},
+		"Legacy exclusive":         {"--add", "--delete", "dev"},
+		"Legacy delete no name":    {"--delete"},
+		"Legacy previous argument": {"--previous", "dev"},
+		"Legacy delete with ttl":   {"--delete", "dev", "--ttl", "1h"},
+		"GC with argument":         {"gc", "dev"},
+	}
+
+	for name, argv := range tests {
+		t.Run(name, func(t *testing.T) {
+			resetConfig(t)
+			_, _, err := parseCommandLine(argv)
+			require.ErrorIs(t, err, co.ErrUsage)
+			assert.Equal(t, co.ExitUsage, co.ExitCode(err))
+		})
+	}
+}
+
+func TestCompletionCandidates(t *testing.T) {
+	resetConfig(t)
+	home = t.TempDir()
+	manager, err := co.NewManager(home)
+	require.NoError(t, err)
+	for _, name := range []string{"dev", "prod"} {
+		_, err := manager.Add(t.Context(), name, "")
+		require.NoError(t, err)
+	}
+	_, err = manager.SetGroup(t.Context(), "dev", "customer-a", true)
+	require.NoError(t, err)
+	_, err = manager.AddAlias(t.Context(), "prod", "live")
+	require.NoError(t, err)
+	config.Aliases = []aliasCfg{{Alias: "d", Config: "dev"}}
+
+	tests := map[string]struct {
+		words    []string
+		cur      string
+		expected []string
+	}{
+		"Commands and configs": {words: []string{}, cur: "", expected: append(commandNames(), "dev", "prod", "d", "live")},
+		"Prefix":               {words: []string{}, cur: "cu", expected: []string{"current"}},
+		"Config argument":      {words: []string{"rm"}, cur: "de", expected: []string{"dev"}},
+		"Config alias":         {words: []string{"delete"}, cur: "", expected: []string{"dev", "prod", "d", "live"}},
+		"Alias of config":      {words: []string{"use"}, cur: "l", expected: []string{"live"}},
+		"Unalias":              {words: []string{"unalias"}, cur: "", expected: []string{"live"}},
+		"Second argument":      {words: []string{"mv", "dev"}, cur: "", expected: nil},
+		"Legacy flag":          {words: []string{"--delete"}, cur: "p", expected: []string{"prod"}},
+		"Shells":               {words: []string{"completion"}, cur: "", expected: []string{"bash", "zsh"}},
+		"Help":                 {words: []string{"help"}, cur: "r", expected: []string{"relink", "rm"}},
+		"Command flags":        {words: []string{"ls"}, cur: "--d", expected: []string{"--debug"}},
+		"Legacy flags":         {words: []string{}, cur: "--p", expected: []string{"--previous"}},
+		"Group flag value":     {words: []string{"ls", "--group"}, cur: "c", expected: []string{"customer-a"}},
+		"Other flag value":     {words: []string{"ls", "-l"}, cur: "", expected: nil},
+		"After flag value":     {words: []string{"rm", "--group", "customer-a"}, cur: "p", expected: []string{"prod"}},
+		"Group argument":       {words: []string{"group"}, cur: "", expected: []string{"customer-a"}},
+		"Group members":        {words: []string{"ungroup", "customer-a", "dev"}, cur: "p", expected: []string{"prod"}},
+		"Shell flag value":     {words: []string{"env", "--shell"}, cur: "f", expected: []string{"fish"}},
+		"Export":               {words: []string{"export", "dev"}, cur: "", expected: []string{"dev", "prod", "d", "live"}},
+		"Sort flag value":      {words: []string{"ls", "--sort"}, cur: "", expected: co.SortOrders},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, test.expected, completionCandidates(test.words, test.cur))
+		})
+	}
+
+	t.Run("Most recently used first", func(t *testing.T) {
+		_, err := manager.Switch(t.Context(), "prod")
+		require.NoError(t, err)
+		config.Sort = co.SortRecent
+		t.Cleanup(func() { config.Sort = "" })
+		assert.Equal(t, []string{"prod", "dev"}, completionCandidates([]string{"use"}, "")[:2])
+	})
+}
+
+func TestDeleteGroup(t *testing.T) {
+	resetConfig(t)
+	home = t.TempDir()
+	manager, err := co.NewManager(home)
+	require.NoError(t, err)
+	ctx := t.Context()
+	for _, name := range []string{"a-dev", "a-test", "b-dev"} {
+		_, err := manager.Add(ctx, name, "")
+		require.NoError(t, err)
+	}
+	for _, name := range []string{"a-dev", "a-test"} {
+		_, err := manager.SetGroup(ctx, name, "customer-a", true)
+		require.NoError(t, err)
+	}
+	_, err = manager.Switch(ctx, "b-dev")
+	require.NoError(t, err)
+	_, err = manager.Switch(ctx, "a-dev")
+	require.NoError(t, err)
+
+	require.NoError(t, deleteGroup(ctx, manager, "customer-a"))
+	configs, err := manager.List(ctx)
+	require.NoError(t, err)
+	require.Len(t, configs, 1)
+	assert.Equal(t, "b-dev", configs[0].Name)
+
+	require.ErrorIs(t, deleteGroup(ctx, manager, "customer-a"), co.ErrNotFound)
+	require.ErrorIs(t, deleteGroup(ctx, manager, "not valid"), co.ErrInvalid)
+
+	t.Run("Current and previous in group", func(t *testing.T) {
+		for _, name := range []string{"a-dev", "a-test"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+			_, err = manager.SetGroup(ctx, name, "customer-a", true)
+			require.NoError(t, err)
+		}
+		_, err = manager.Switch(ctx, "a-test")
+		require.NoError(t, err)
+		_, err = manager.Switch(ctx, "a-dev")
+		require.NoError(t, err)
+
+		require.NoError(t, deleteGroup(ctx, manager, "customer-a"))
+		current, err := manager.Current(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "b-dev", current.Name)
+		_, err = manager.Previous(ctx)
+		require.ErrorIs(t, err, co.ErrNoPrevious)
+	})
+}
+
+func TestCommandWords(t *testing.T) {
+	assert.Equal(t, []string{"rm"}, commandWords([]string{"kubectl", "co", "rm"}))
+	assert.Equal(t, []string{"rm"}, commandWords([]string{"kubectl-co", "rm"}))
+	assert.Equal(t, []string{}, commandWords([]string{"kubectl-co"}))
+}
+
+func TestHandleCompletionCommand(t *testing.T) {
+	require.NoError(t, handleCompletionCommand([]string{"bash"}))
+	require.NoError(t, handleCompletionCommand([]string{"zsh"}))
+	err := handleCompletionCommand([]string{"fish"})
+	require.ErrorIs(t, err, co.ErrUsage)
+}
+
+func TestLocationOptions(t *testing.T) {
+	resetConfig(t)
+	oldHome := home
+	home = "/home"
+	t.Cleanup(func() { home = oldHome })
+
+	assert.Equal(t, "/home/.kube/co", expandHome("~/.kube/co"))
+	assert.Equal(t, "/home", expandHome("~"))
+	assert.Equal(t, "~user/co", expandHome("~user/co"))
+	assert.Equal(t, "relative/co", expandHome("relative/co"))
+
+	t.Setenv("XDG_DATA_HOME", "/data")
+	t.Setenv("XDG_STATE_HOME", "relative")
+	assert.Equal(t, "/data", xdgDir("XDG_DATA_HOME", ".local/share"))
+	assert.Equal(t, "/home/.local/state", xdgDir("XDG_STATE_HOME", ".local/state"), "relative paths are ignored")
+
+	t.Setenv("KUBECONFIG", "")
+	fsys := co.NewMemFS()
+	require.NoError(t, fsys.MkdirAll("/home", 0700))
+	config.XDG = true
+	config.StoreDir = "~/configs"
+	manager, err := co.NewManager(home, append(locationOptions(), co.WithFS(fsys))...)
+	require.NoError(t, err)
+	_, err = manager.Add(t.Context(), "dev", "")
+	require.NoError(t, err)
+	_, err = fsys.Stat("/home/configs/dev")
+	assert.NoError(t, err, "the configured store directory wins over XDG")
+	_, err = fsys.Stat("/home/.local/state/kubectl-co")
+	assert.NoError(t, err, "the state directory defaults to XDG")
+}
+
+func TestAuditFilter(t *testing.T) {
+	resetConfig(t)
+	now := time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC)
+
+	config.Since = "24h"
+	config.Until = "2024-05-10"
+	config.Action = co.AuditLink
+	filter, err := auditFilter([]string{"dev"}, now)
+	require.NoError(t, err)
+	assert.Equal(t, co.AuditFilter{
+		Since:  time.Date(2024, 5, 9, 12, 0, 0, 0, time.UTC),
+		Until:  time.Date(2024, 5, 10, 0, 0, 0, 0, time.UTC),
+		Action: co.AuditLink,
+		Config: "dev",
+	}, filter)
+
+	config.Since = "2024-05-01T08:00:00+02:00"
+	filter, err = auditFilter(nil, now)
+	require.NoError(t, err)
+	assert.True(t, filter.Since.Equal(time.Date(2024, 5, 1, 6, 0, 0, 0, time.UTC)))
+
+	config.Since = "yesterday"
+	_, err = auditFilter(nil, now)
+	assert.ErrorIs(t, err, co.ErrUsage)
+
+	config.Since = ""
+	config.Action = "switch"
+	_, err = auditFilter(nil, now)
+	assert.ErrorIs(t, err, co.ErrUsage)
+}
+
+func TestPrintAuditLog(t *testing.T) {
+	local := time.Local
+	time.Local = time.UTC
+	t.Cleanup(func() { time.Local = local })
+	entries := []co.AuditEntry{
+		{Time: time.Date(2024, 5, 10, 12, 0, 0, 0, time.UTC), User: "alice", Host: "laptop", Action: co.AuditAdd,
+			Config: "dev", Outcome: co.AuditSuccess},
+		{Time: time.Date(2024, 5, 10, 12, 1, 0, 0, time.UTC), User: "alice", Host: "laptop", TTY: "/dev/pts/0",
+			Action: co.AuditLink, Config: "prod", From: "/home/.kube/co/dev", To: "/home/.kube/co/prod",
+			Outcome: co.AuditFailure, Error: "config is protected"},
+	}
+	out := &bytes.Buffer{}
+	printAuditLog(out, entries)
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"TIME", "USER", "HOST", "TTY", "ACTION", "CONFIG", "FROM", "TO", "OUTCOME"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"2024-05-10", "12:00:00", "alice", "laptop", "-", "add", "dev", "-", "-", "success"}, strings.Fields(lines[1]))
+	assert.Contains(t, lines[2], "failure: config is protected")
+
+	out.Reset()
+	require.NoError(t, printAuditJSON(out, entries[:1]))
+	assert.Equal(t, `{"time":"2024-05-10T12:00:00Z","user":"alice","host":"laptop","action":"add","config":"dev","outcome":"success"}`+"\n", out.String())
+}
+
+func TestPrintStats(t *testing.T) {
+	lastUsed := time.Date(2024, 5, 10, 12, 0, 0, 0, time.Local)
+	stats := []co.ConfigStats{
+		{Name: "dev", SwitchCount: 3, LastUsed: lastUsed},
+		{Name: "old", Unused: true},
+	}
+	out := &bytes.Buffer{}
+	assert.Equal(t, 1, printStats(out, stats, false))
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	require.Len(t, lines, 3)
+	assert.Equal(t, []string{"NAME", "SWITCHES", "LAST-USED", "CREATED", "UNUSED"}, strings.Fields(lines[0]))
+	assert.Equal(t, []string{"dev", "3", "2024-05-10", "12:00:00", "-"}, strings.Fields(lines[1]))
+	assert.Equal(t, []string{"old", "0", "-", "-", "*"}, strings.Fields(lines[2]))
+
+	out.Reset()
+	assert.Equal(t, 1, printStats(out, stats, true))
+	assert.NotContains(t, out.String(), "dev")
+}
+
+func TestReservedNames(t *testing.T) {
+	names := reservedNames()
+	for _, name := range []string{"use", "switch", "log", "rm", "delete"} {
+		assert.Contains(t, names, name)
+	}
+	assert.True(t, sort.StringsAreSorted(names))
+
+	t.Run("Alias named like a command", func(t *testing.T) {
+		resetConfig(t)
+		home = t.TempDir()
+		manager, err := co.NewManager(home)
+		require.NoError(t, err)
+		_, err = manager.Add(t.Context(), "dev", "")
+		require.NoError(t, err)
+
+		err = commands["alias"].run(t.Context(), []string{"dev", "log"})
+		require.ErrorIs(t, err, co.ErrReserved)
+		assert.Equal(t, co.ExitValidation, co.ExitCode(err))
+	})
+}
+
+func TestCollectExpiredBefore(t *testing.T) {
+	resetConfig(t)
+	home = t.TempDir()
+	manager, err := co.NewManager(home)
+	require.NoError(t, err)
+	ctx := t.Context()
+	for _, name := range []string{"dev", "breakglass"} {
+		_, err := manager.Add(ctx, name, "")
+		require.NoError(t, err)
+	}
+	_, err = manager.Switch(ctx, "breakglass")
+	require.NoError(t, err)
+	_, err = manager.SetExpiry(ctx, "breakglass", time.Now().Add(-time.Minute))
+	require.NoError(t, err)
+	names := func() []string {
+		configs, err := manager.List(ctx)
+		require.NoError(t, err)
+		names := []string{}
+		for _, cfg := range configs {
+			names = append(names, cfg.Name)
+		}
+		return names
+	}
+
+	collectExpiredBefore(ctx, commands["prompt"])
+	assert.Equal(t, []string{"breakglass", "dev"}, names(), "prompt doesn't touch expired configs")
+	current, err := manager.Current(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, "breakglass", current.Name)
+
+	collectExpiredBefore(ctx, commands["ls"])
+	assert.Equal(t, []string{"dev"}, names())
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/eslog"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "migrate",
+		args:  "[--from <dir>]",
+		short: "Move the config store to the configured store directory",
+		long: `Moves the configs and their metadata from --from (default ~/.kube/co) to the store
+directory set by --store-dir or --xdg and the previous link to the state directory. The kube
+config and previous links are pointed to the new location. Nothing is moved if a config already
+exists in the store directory.`,
+		maxArgs: 0,
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeyFrom, "", "Store directory to move the configs from (default ~/.kube/co)")
+		},
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			moved, err := manager.Migrate(ctx, expandHome(config.From))
+			if err != nil {
+				return err
+			}
+			for _, name := range moved {
+				fmt.Println(name)
+			}
+			eslog.Infof("Moved %d entries", len(moved))
+			return nil
+		},
+	})
+}
@@ -0,0 +1,1139 @@
+/*
+Package co exposes the config management of kubectl-co to other Go programs.
+
+A Manager stores kubeconfig files in ~/.kube/co and switches between them by linking
+~/.kube/config to the selected file:
+
+	manager, err := co.NewManager(home)
+	if err != nil {
+		return err
+	}
+	result, err := manager.Switch(ctx, "staging")
+	if errors.Is(err, co.ErrNotFound) {
+		...
+	}
+*/
+package co
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"slices"
+	"sort"
+	"time"
+
+	"github.com/steffakasid/kubectl-co/internal"
+)
+
+// Errors returned by the Manager. Check them with errors.Is.
+var (
+	// ErrNotFound is returned when the requested config does not exist.
+	ErrNotFound = internal.ErrNotFound
+	// ErrNoPrevious is returned by Previous when no previous config is recorded.
+	ErrNoPrevious = internal.ErrNoPrevious
+	// ErrExists is returned when a config with the same name already exists.
+	ErrExists = internal.ErrExists
+	// ErrInvalid is returned for invalid input like a config name containing a slash.
+	ErrInvalid = internal.ErrInvalid
+	// ErrUsage marks errors caused by wrong usage of a command line built on the Manager.
+	ErrUsage = internal.ErrUsage
+	// ErrProtected is returned when switching to or deleting a protected config wasn't confirmed.
+	ErrProtected = internal.ErrProtected
+	// ErrAmbiguous is returned when a name prefix matches more than one config.
+	ErrAmbiguous = internal.ErrAmbiguous
+	// ErrVetoed is returned when a pre-switch hook failed and the switch was aborted.
+	ErrVetoed = internal.ErrVetoed
+	// ErrReserved is returned when a new config or alias is named like a command, see
+	// WithReservedNames. It wraps ErrInvalid.
+	ErrReserved = internal.ErrReserved
+)
+
+// Exit codes returned by ExitCode.
+const (
+	ExitOK         = internal.ExitOK
+	ExitError      = internal.ExitError
+	ExitUsage      = internal.ExitUsage
+	ExitNotFound   = internal.ExitNotFound
+	ExitConflict   = internal.ExitConflict
+	ExitIO         = internal.ExitIO
+	ExitValidation = internal.ExitValidation
+	ExitProtected  = internal.ExitProtected
+)
+
+// ExitCode maps err to the process exit code of its category (usage, not found, conflict or
+// ambiguous name, I/O, validation or protected). It returns ExitOK for nil and ExitError for uncategorized errors.
+func ExitCode(err error) int {
+	return internal.ExitCode(err)
+}
+
+const (
+	// StoreBackendDir keeps every config as a plain file.
+	StoreBackendDir = internal.StoreBackendDir
+	// StoreBackendGit keeps the configs in a git working tree and commits every change.
+	StoreBackendGit = internal.StoreBackendGit
+)
+
+type (
+	// FS is the filesystem a Manager operates on.
+	FS = internal.FS
+	// OSFS is the FS of the operating system, used by default.
+	OSFS = internal.OSFS
+	// MemFS is an in-memory FS, useful for tests.
+	MemFS = internal.MemFS
+	// CheckOptions configures Check.
+	CheckOptions = internal.CheckOptions
+	// CheckResult is the outcome of checking one context of a config.
+	CheckResult = internal.CheckResult
+	// Expiry is the expiry date of a credential embedded in a config.
+	Expiry = internal.Expiry
+	// PromptInfo describes the current config for a shell prompt, see Manager.Prompt.
+	PromptInfo = internal.PromptInfo
+	// Problem is an issue found by Doctor.
+	Problem = internal.Problem
+	// Severity tells how serious a Problem is.
+	Severity = internal.Severity
+	// ConfigStats is the usage of a config, see Manager.Stats.
+	ConfigStats = internal.ConfigStats
+	// AuditEntry is a single entry of the audit log, see Manager.AuditLog.
+	AuditEntry = internal.AuditEntry
+	// AuditFilter selects entries of the audit log. Empty fields match every entry.
+	AuditFilter = internal.AuditFilter
+	// ImportResult is the outcome of importing a single file, see Manager.Import.
+	ImportResult = internal.ImportResult
+	// UpdateResult describes what Manager.Update did.
+	UpdateResult = internal.UpdateResult
+	// GCResult describes what CollectExpired did.
+	GCResult = internal.GCResult
+	// DeleteGroupResult describes what Manager.DeleteGroup did.
+	DeleteGroupResult = internal.DeleteGroupResult
+	// Hooks configures the commands run before and after switching, see WithHooks.
+	Hooks = internal.Hooks
+	// ConfigHooks are the hooks of a single config.
+	ConfigHooks = internal.ConfigHooks
+	// Metadata holds the description, labels, groups, aliases, variables, annotations, color, owner,
+	// timestamps, protection and expiry of a config.
+	Metadata = internal.Metadata
+)
+
+// Kinds of credentials reported in Expiry.
+const (
+	ExpiryClientCertificate    = internal.ExpiryClientCertificate
+	ExpiryCertificateAuthority = internal.ExpiryCertificateAuthority
+	ExpiryToken                = internal.ExpiryToken
+)
+
+// StdinSource is the source of Add reading the config from standard input.
+const StdinSource = internal.StdinSource
+
+// DefaultFetchTimeout limits the time Add spends on fetching a config from a URL.
+const DefaultFetchTimeout = internal.DefaultFetchTimeout
+
+// DefaultWarnDays is the number of days before an expiry a warning is shown.
+const DefaultWarnDays = internal.DefaultWarnDays
+
+const (
+	// DefaultCheckTimeout limits the time spent on checking a single context.
+	DefaultCheckTimeout = internal.DefaultCheckTimeout
+	// DefaultCheckParallelism is the number of contexts checked at the same time.
+	DefaultCheckParallelism = internal.DefaultCheckParallelism
+)
+
+// Hook names. They are also the file names of the hook scripts in Hooks.Dir.
+const (
+	HookPreSwitch  = internal.HookPreSwitch
+	HookPostSwitch = internal.HookPostSwitch
+)
+
+// Orders of SortConfigs.
+const (
+	SortName     = internal.SortName
+	SortRecent   = internal.SortRecent
+	SortFrequent = internal.SortFrequent
+)
+
+// SortOrders lists the orders of SortConfigs.
+var SortOrders = internal.SortOrders
+
+// ExpiryWarningWindow is the time before the expiry of a temporary config within which
+// switching to it warns, see SetExpiry.
+const ExpiryWarningWindow = internal.ExpiryWarningWindow
+
+// DefaultUnusedDays is the number of days after which Stats reports a config as unused.
+const DefaultUnusedDays = internal.DefaultUnusedDays
+
+// Actions recorded in the audit log.
+const (
+	AuditAdd    = internal.AuditAdd
+	AuditLink   = internal.AuditLink
+	AuditDelete = internal.AuditDelete
+	AuditRename = internal.AuditRename
+	AuditEdit   = internal.AuditEdit
+	AuditUpdate = internal.AuditUpdate
+	AuditExpire = internal.AuditExpire
+)
+
+// Outcomes of the actions recorded in the audit log.
+const (
+	AuditSuccess = internal.AuditSuccess
+	AuditFailure = internal.AuditFailure
+)
+
+// Defaults of the audit log rotation, see WithAuditLog.
+const (
+	DefaultAuditMaxSize  = internal.DefaultAuditMaxSize
+	DefaultAuditMaxFiles = internal.DefaultAuditMaxFiles
+)
+
+// Severities of a Problem.
+const (
+	SeverityError   = internal.SeverityError
+	SeverityWarning = internal.SeverityWarning
+	SeverityInfo    = internal.SeverityInfo
+)
+
+// Modes of switching configs, see WithMode.
+const (
+	// ModeSymlink switches by linking ~/.kube/config to the config.
+	ModeSymlink = "symlink"
+	// ModeEnv switches by returning the files the KUBECONFIG environment variable has to list.
+	ModeEnv = "env"
+)
+
+// DefaultHookTimeout limits the run time of a single hook.
+const DefaultHookTimeout = internal.DefaultHookTimeout
+
+// NewMemFS returns an empty in-memory FS.
+func NewMemFS() *MemFS {
+	return internal.NewMemFS()
+}
+
+// Config describes a stored config. Environment, Protected and Metadata are only set by List,
+// Current, Protect, SetExpiry and UpdateMetadata.
+type Config struct {
+	// Name is the name of the config. It is empty if the kube config links to a file outside the store.
+	Name string
+	// Path is the location of the config file.
+	Path string
+	// Current is set if the kube config links to this config.
+	Current bool
+	// Environment is the kind of cluster, e.g. prod, taken from the environment or the env label.
+	Environment string
+	// Protected is set if switching to or deleting the config needs a confirmation.
+	Protected bool
+	// Metadata is the metadata stored next to the config.
+	Metadata Metadata
+}
+
+// FormatLabels returns the labels as sorted, comma separated key=value pairs.
+func FormatLabels(labels map[string]string) string {
+	return internal.FormatLabels(labels)
+}
+
+// ParseLabelChanges parses kubectl style label arguments: key=value sets a label, key- removes
+// it. Errors wrap ErrInvalid.
+func ParseLabelChanges(args []string) (set map[string]string, remove []string, err error) {
+	return internal.ParseLabelChanges(args)
+}
+
+// Filter selects configs returned by List.
+type Filter func(Config) bool
+
+// Selector returns a Filter for configs whose labels match selector, e.g. "env=prod,team!=ops".
+// Requirements are separated by commas and can be key=value, key!=value, key (the label
+// exists) or !key (it doesn't). The env label defaults to the environment of the config.
+// Errors wrap ErrInvalid.
+func Selector(selector string) (Filter, error) {
+	parsed, err := internal.ParseSelector(selector)
+	if err != nil {
+		return nil, err
+	}
+	return func(cfg Config) bool {
+		return parsed.Matches(cfg.Metadata.SelectorLabels())
+	}, nil
+}
+
+// InGroup returns a Filter for configs belonging to group.
+func InGroup(group string) Filter {
+	return func(cfg Config) bool {
+		return cfg.Metadata.InGroup(group)
+	}
+}
+
+// SortConfigs sorts configs returned by List by name (SortName or empty), by the most recent
+// use (SortRecent) or by the number of switches (SortFrequent). Unknown orders return an error
+// wrapping ErrInvalid.
+func SortConfigs(configs []Config, by string) error {
+	if err := internal.ValidateSort(by); err != nil {
+		return err
+	}
+	slices.SortStableFunc(configs, func(a, b Config) int {
+		return internal.CompareUsage(by, a.Name, a.Metadata, b.Name, b.Metadata)
+	})
+	return nil
+}
+
+// ValidateGroup checks the name of a group. Group names follow the rules of label values.
+// Errors wrap ErrInvalid.
+func ValidateGroup(group string) error {
+	return internal.ValidateGroup(group)
+}
+
+// Group is a named set of configs.
+type Group struct {
+	// Name is the name of the group.
+	Name string
+	// Configs are the names of the configs in the group in alphabetical order.
+	Configs []string
+}
+
+// Env holds the environment variables for using a config, see Manager.Env.
+type Env struct {
+	// Set are the variables of the config.
+	Set map[string]string
+	// Unset are the variables of the config used before which the config doesn't set.
+	Unset []string
+}
+
+// SwitchResult is returned when the kube config was linked to another config.
+type SwitchResult struct {
+	// Config is the config the kube config links to now.
+	Config Config
+	// KubeConfigPath is the path of the kube config symlink. It is empty in ModeEnv.
+	KubeConfigPath string
+	// KubeConfigEnv are the files the KUBECONFIG environment variable has to list in ModeEnv,
+	// the new config first. It is nil in ModeSymlink.
+	KubeConfigEnv []string
+	// From is the path the kube config linked to before. In ModeEnv it is the config kubectl used
+	// before. It is empty if it was not linked.
+	From string
+	// Expiring holds the credentials of Config which expire within the days set by WithWarnDays.
+	Expiring []Expiry
+}
+
+// DeleteResult is returned by Delete.
+type DeleteResult struct {
+	// Deleted is the removed config.
+	Deleted Config
+	// Switch describes the switch to the previous config done before deleting.
+	Switch SwitchResult
+}
+
+// Option configures a Manager.
+type Option func(*Manager)
+
+// WithBackend selects the store backend (StoreBackendDir or StoreBackendGit).
+func WithBackend(backend string) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithBackend(backend))
+	}
+}
+
+// WithFS lets the Manager operate on fsys instead of the disk.
+func WithFS(fsys FS) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithFS(fsys))
+	}
+}
+
+// WithWarnDays makes switching warn about credentials which expire within the given number
+// of days. The expiring credentials are returned in SwitchResult.Expiring. Zero disables the
+// warning.
+func WithWarnDays(days int) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithWarnDays(days))
+	}
+}
+
+// DefaultProtectedEnvironments are the environments whose configs are protected unless
+// WithProtectedEnvironments is used.
+var DefaultProtectedEnvironments = internal.DefaultProtectedEnvironments
+
+// WithProtectedEnvironments sets the environments whose configs are protected. Without
+// environments only configs marked by Protect are protected.
+func WithProtectedEnvironments(environments ...string) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithProtectedEnvironments(environments...))
+	}
+}
+
+// WithConfirm sets the function asked before switching to or deleting a protected config.
+// Returning false aborts with ErrProtected. Without it protected configs can't be switched to
+// or deleted.
+func WithConfirm(confirm func(name string) bool) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithConfirm(confirm))
+	}
+}
+
+// WithAliases sets aliases in addition to the ones stored with AddAlias. The map goes from
+// alias to config name.
+func WithAliases(aliases map[string]string) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithAliases(aliases))
+	}
+}
+
+// WithReservedNames refuses names as names of new configs and aliases (ErrReserved), e.g. the
+// commands of a command line which would shadow configs named like them. Imported configs get
+// a number appended instead.
+func WithReservedNames(names ...string) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithReservedNames(names...))
+	}
+}
+
+// WithPrefixMatch sets whether a unique prefix of a config name or alias selects the config.
+// It is disabled by default, so prefixes are only suggested in ErrNotFound errors.
+func WithPrefixMatch(enabled bool) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithPrefixMatch(enabled))
+	}
+}
+
+// WithHooks sets the hooks run before and after the kube config is linked to another config by
+// Switch, Previous and Delete. The hooks get the old and new config in the environment variables
+// KUBECTL_CO_OLD_NAME, KUBECTL_CO_OLD_PATH, KUBECTL_CO_NEW_NAME and KUBECTL_CO_NEW_PATH. A
+// failing pre-switch hook aborts the switch with ErrVetoed.
+func WithHooks(hooks Hooks) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithHooks(hooks))
+	}
+}
+
+// WithMode selects how Switch and Previous switch configs: ModeSymlink (the default) links
+// ~/.kube/config, ModeEnv leaves it alone and returns the files for the KUBECONFIG environment
+// variable in SwitchResult.KubeConfigEnv. Delete always relinks ~/.kube/config.
+func WithMode(mode string) Option {
+	return func(m *Manager) {
+		m.mode = mode
+	}
+}
+
+// WithKubeConfigEnv replaces the value of the KUBECONFIG environment variable, which is read
+// from the process environment by default.
+func WithKubeConfigEnv(value string) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithKubeConfigEnv(value))
+	}
+}
+
+// WithRelativeLinks makes switching create the kube config and previous links relative to their
+// directory, so they survive moving the home directory.
+func WithRelativeLinks(relative bool) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithRelativeLinks(relative))
+	}
+}
+
+// WithStdin lets Add read the source StdinSource from r instead of os.Stdin.
+func WithStdin(r io.Reader) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithStdin(r))
+	}
+}
+
+// WithFetchTimeout limits the time Add spends on fetching a config from an http(s) URL. Zero
+// keeps DefaultFetchTimeout.
+func WithFetchTimeout(timeout time.Duration) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithFetchTimeout(timeout))
+	}
+}
+
+// WithAuditLog turns the audit log on or off and sets its rotation: the log is rotated before it
+// grows beyond maxSize bytes and maxFiles rotated logs are kept. Values below one keep the
+// defaults. The audit log is on by default.
+func WithAuditLog(enabled bool, maxSize int64, maxFiles int) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithAuditLog(enabled, maxSize, maxFiles))
+	}
+}
+
+// WithStoreDir keeps the configs in dir instead of ~/.kube/co. An empty dir keeps the default.
+func WithStoreDir(dir string) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithStoreDir(dir))
+	}
+}
+
+// WithKubeConfigPath links kubeConfigPath instead of ~/.kube/config to the selected config. An
+// empty path keeps the default.
+func WithKubeConfigPath(kubeConfigPath string) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithKubeConfigPath(kubeConfigPath))
+	}
+}
+
+// WithStateDir keeps the previous link in dir instead of the store directory. An empty dir
+// keeps the default.
+func WithStateDir(dir string) Option {
+	return func(m *Manager) {
+		m.opts = append(m.opts, internal.WithStateDir(dir))
+	}
+}
+
+// DefaultStoreDir returns the store directory below home used without WithStoreDir.
+func DefaultStoreDir(home string) string {
+	return internal.DefaultStoreDir(home)
+}
+
+// Manager adds, switches, lists and deletes configs stored below a home directory.
+// The methods read the current state from the filesystem on every call, so a Manager can be
+// kept for the lifetime of a program.
+type Manager struct {
+	home string
+	mode string
+	opts []internal.Option
+}
+
+// NewManager returns a Manager for the given home directory. The kube home and the store
+// directory are created if they don't exist.
+func NewManager(home string, opts ...Option) (*Manager, error) {
+	m := &Manager{home: home}
+	for _, opt := range opts {
+		opt(m)
+	}
+	if m.mode != "" && m.mode != ModeSymlink && m.mode != ModeEnv {
+		return nil, fmt.Errorf("%w: unknown mode %q", ErrInvalid, m.mode)
+	}
+	if _, err := m.co(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Doctor inspects the kube home, the store, the configs and their metadata, the kube config and
+// previous links and the KUBECONFIG environment variable below home and returns the problems
+// found. With fix set the problems which can be repaired safely are repaired, see Problem.Fixed.
+// Unlike NewManager it works on a broken kube home, so it doesn't need a Manager.
+func Doctor(ctx context.Context, home string, fix bool, opts ...Option) ([]Problem, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	m := &Manager{home: home}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return internal.Doctor(m.home, fix, m.opts...)
+}
+
+// JoinKubeConfigEnv returns the value of the KUBECONFIG environment variable listing files.
+func JoinKubeConfigEnv(files []string) string {
+	return internal.JoinKubeConfigEnv(files)
+}
+
+// co returns a fresh internal.CO reflecting the current state of the filesystem.
+func (m *Manager) co() (*internal.CO, error) {
+	co, err := internal.NewCO(m.home, m.opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize co: %w", err)
+	}
+	return co, nil
+}
+
+// config returns the Config for the file at configPath.
+func config(co *internal.CO, configPath string) Config {
+	cfg := Config{Path: configPath, Current: configPath != "" && configPath == co.EffectiveConfigPath()}
+	if path.Dir(configPath) == path.Clean(co.CObasePath) {
+		cfg.Name = path.Base(configPath)
+	}
+	return cfg
+}
+
+// withMetadata adds the metadata, environment and protection of the stored config cfg.
+func withMetadata(co *internal.CO, cfg Config) (Config, error) {
+	if cfg.Name == "" {
+		return cfg, nil
+	}
+	md, err := co.Metadata(cfg.Name)
+	if err != nil {
+		return cfg, err
+	}
+	cfg.Metadata = md
+	cfg.Environment = md.Env()
+	cfg.Protected, err = co.IsProtected(cfg.Name)
+	return cfg, err
+}
+
+// Add stores a config named name. If source is empty an empty config is created, otherwise
+// the file at source is copied. source can also be StdinSource to read the config from
+// standard input (see WithStdin) or an http(s) or file URL to fetch it from (see
+// WithFetchTimeout). Configs read from standard input or a URL must be kubeconfigs (ErrInvalid).
+// It returns ErrExists if a config named name exists, use Update to change it.
+func (m *Manager) Add(ctx context.Context, name, source string) (Config, error) {
+	if err := ctx.Err(); err != nil {
+		return Config{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return Config{}, err
+	}
+	co.ConfigName = name
+	if err := co.AddConfig(ctx, source); err != nil {
+		return Config{}, err
+	}
+	return Config{Name: name, Path: path.Join(co.CObasePath, name)}, nil
+}
+
+// Switch links the kube config to the config named name. name can also be an alias or a
+// unique prefix (see Resolve). In ModeEnv the kube config is left alone, see WithMode.
+func (m *Manager) Switch(ctx context.Context, name string) (SwitchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return SwitchResult{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return SwitchResult{}, err
+	}
+	if name == "" {
+		return SwitchResult{}, fmt.Errorf("%w: no config name given", ErrInvalid)
+	}
+	if name, err = co.ResolveName(name); err != nil {
+		return SwitchResult{}, err
+	}
+	co.ConfigName = name
+	return m.link(co, path.Join(co.CObasePath, name))
+}
+
+// Previous links the kube config to the previously used config.
+func (m *Manager) Previous(ctx context.Context) (SwitchResult, error) {
+	if err := ctx.Err(); err != nil {
+		return SwitchResult{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return SwitchResult{}, err
+	}
+	return m.link(co, co.PreviousConifgPath)
+}
+
+func (m *Manager) link(co *internal.CO, target string) (SwitchResult, error) {
+	if m.mode == ModeEnv {
+		from := co.EffectiveConfigPath()
+		if err := co.SwitchKubeConfigEnv(); err != nil {
+			return SwitchResult{}, err
+		}
+		return SwitchResult{Config: config(co, target), KubeConfigEnv: co.KubeConfigEnv, From: from, Expiring: co.Expiring}, nil
+	}
+	from := co.CurrentConfigPath
+	if err := co.LinkKubeConfig(); err != nil {
+		return SwitchResult{}, err
+	}
+	co.CurrentConfigPath = target
+	return SwitchResult{Config: config(co, target), KubeConfigPath: co.KubeConfigPath, From: from, Expiring: co.Expiring}, nil
+}
+
+// Delete removes the config named name. Before it is removed the kube config is linked to the
+// previous config. name can also be an alias, but not a prefix, so nothing is deleted which
+// wasn't named. A missing config returns ErrNotFound with suggestions of similar names.
+func (m *Manager) Delete(ctx context.Context, name string) (DeleteResult, error) {
+	if err := ctx.Err(); err != nil {
+		return DeleteResult{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return DeleteResult{}, err
+	}
+	if name, err = co.ResolveAlias(name); err != nil {
+		return DeleteResult{}, err
+	}
+	co.ConfigName = name
+	deleted := config(co, path.Join(co.CObasePath, name))
+	from, previous := co.CurrentConfigPath, co.PreviousConifgPath
+	if err := co.DeleteConfig(); err != nil {
+		return DeleteResult{}, err
+	}
+	co.CurrentConfigPath = previous
+	return DeleteResult{
+		Deleted: deleted,
+		Switch:  SwitchResult{Config: config(co, previous), KubeConfigPath: co.KubeConfigPath, From: from},
+	}, nil
+}
+
+// DeleteGroup removes all configs of group. If the current config is in the group the kube
+// config is first linked to the previous config or, if that is in the group as well, to another
+// config outside the group. The link is removed if no config remains. Nothing is deleted unless
+// every protected config of the group is confirmed.
+func (m *Manager) DeleteGroup(ctx context.Context, group string) (DeleteGroupResult, error) {
+	if err := ctx.Err(); err != nil {
+		return DeleteGroupResult{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return DeleteGroupResult{}, err
+	}
+	return co.DeleteGroup(group)
+}
+
+// Rename renames the config oldName to newName. Links to the config are updated.
+func (m *Manager) Rename(ctx context.Context, oldName, newName string) (Config, error) {
+	if err := ctx.Err(); err != nil {
+		return Config{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return Config{}, err
+	}
+	co.ConfigName = oldName
+	if err := co.RenameConfig(newName); err != nil {
+		return Config{}, err
+	}
+	return config(co, path.Join(co.CObasePath, newName)), nil
+}
+
+// List returns the stored configs matching all filters in alphabetical order.
+func (m *Manager) List(ctx context.Context, filters ...Filter) ([]Config, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	if err := co.ListConfigs(); err != nil {
+		return nil, err
+	}
+	configs := make([]Config, 0, len(co.Configs))
+	for _, name := range co.Configs {
+		cfg, err := withMetadata(co, config(co, path.Join(co.CObasePath, name)))
+		if err != nil {
+			return nil, err
+		}
+		if matches(cfg, filters) {
+			configs = append(configs, cfg)
+		}
+	}
+	return configs, nil
+}
+
+func matches(cfg Config, filters []Filter) bool {
+	for _, filter := range filters {
+		if !filter(cfg) {
+			return false
+		}
+	}
+	return true
+}
+
+// Current returns the config kubectl uses: the config the kube config links to or, if the
+// KUBECONFIG environment variable is set, the file of KUBECONFIG kubectl takes the current
+// context from. ErrNotFound is returned if there is none.
+func (m *Manager) Current(ctx context.Context) (Config, error) {
+	if err := ctx.Err(); err != nil {
+		return Config{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return Config{}, err
+	}
+	current := co.EffectiveConfigPath()
+	if current == "" {
+		return Config{}, fmt.Errorf("%w: %s is not linked to a config", ErrNotFound, co.KubeConfigPath)
+	}
+	return withMetadata(co, config(co, current))
+}
+
+// Merge returns the files the KUBECONFIG environment variable has to list to merge the given
+// configs into the current one. Without KUBECONFIG the list starts with the kube config.
+func (m *Manager) Merge(ctx context.Context, names ...string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	return co.MergeConfigs(names...)
+}
+
+// Unmerge returns the files the KUBECONFIG environment variable has to list without the given
+// configs. An empty result means KUBECONFIG can be unset.
+func (m *Manager) Unmerge(ctx context.Context, names ...string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	files, err := co.UnmergeConfigs(names...)
+	if err != nil {
+		return nil, err
+	}
+	if len(files) == 1 && path.Clean(files[0]) == path.Clean(co.KubeConfigPath) {
+		return []string{}, nil
+	}
+	return files, nil
+}
+
+// Protect sets whether switching to or deleting the config named name needs a confirmation.
+// If environment is not empty the environment of the config is set as well. Configs of a
+// protected environment stay protected when protected is false.
+func (m *Manager) Protect(ctx context.Context, name string, protected bool, environment string) (Config, error) {
+	if err := ctx.Err(); err != nil {
+		return Config{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return Config{}, err
+	}
+	co.ConfigName = name
+	if err := co.SetProtection(protected, environment); err != nil {
+		return Config{}, err
+	}
+	return withMetadata(co, config(co, path.Join(co.CObasePath, name)))
+}
+
+// SetExpiry makes the named config temporary: CollectExpired moves it to the trash after
+// expires. A zero expires makes it permanent again.
+func (m *Manager) SetExpiry(ctx context.Context, name string, expires time.Time) (Config, error) {
+	if err := ctx.Err(); err != nil {
+		return Config{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return Config{}, err
+	}
+	co.ConfigName = name
+	if err := co.SetExpiry(expires); err != nil {
+		return Config{}, err
+	}
+	return withMetadata(co, config(co, path.Join(co.CObasePath, name)))
+}
+
+// CollectExpired moves the configs set by SetExpiry which have expired to the trash in the
+// state directory. If the current config expired the previous config is switched to without
+// running hooks, or the kube config link is removed if there is no usable previous config.
+func (m *Manager) CollectExpired(ctx context.Context) (GCResult, error) {
+	if err := ctx.Err(); err != nil {
+		return GCResult{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return GCResult{}, err
+	}
+	return co.CollectExpired(time.Now())
+}
+
+// Update merges the kubeconfig at source, read like by Add, into the config named name: new
+// clusters, contexts and users are added, users are replaced and the fields of clusters and
+// contexts are updated, keeping the namespaces of the contexts, the current context, the
+// preferences and everything only the stored config has. The previous version is kept in a
+// backup file. With dryRun nothing is written and only the diff is returned.
+func (m *Manager) Update(ctx context.Context, name, source string, dryRun bool) (UpdateResult, error) {
+	if err := ctx.Err(); err != nil {
+		return UpdateResult{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return UpdateResult{}, err
+	}
+	co.ConfigName = name
+	return co.UpdateConfig(ctx, source, dryRun)
+}
+
+// Import adds the kubeconfig files in dir to the store. Their names are derived from the
+// current context and get a number appended if they are taken. Files with the same content as
+// a stored config are reported as Duplicate and not added. Hidden files, partial downloads and
+// files which aren't kubeconfigs are skipped. With deleteSource the imported and duplicate
+// files are removed. Failed imports are reported in the Err field of their ImportResult.
+func (m *Manager) Import(ctx context.Context, dir string, deleteSource bool) ([]ImportResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	return co.Import(ctx, dir, deleteSource)
+}
+
+// WatchImports imports the files in dir like Import and then the files created in or moved to
+// dir until ctx is done, passing every result to report. It needs the OS filesystem.
+func (m *Manager) WatchImports(ctx context.Context, dir string, deleteSource bool, report func(ImportResult)) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	co, err := m.co()
+	if err != nil {
+		return err
+	}
+	return co.WatchImports(ctx, dir, deleteSource, report)
+}
+
+// Check checks whether the clusters of every context in the named configs are reachable. If
+// no names are given all configs are checked. A failing check is reported in the Err field of
+// its CheckResult, the returned error is only set if the configs can't be read.
+func (m *Manager) Check(ctx context.Context, opts CheckOptions, names ...string) ([]CheckResult, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	if len(names) == 0 {
+		if err := co.ListConfigs(); err != nil {
+			return nil, err
+		}
+		names = co.Configs
+	}
+	return co.CheckConfigs(ctx, names, opts)
+}
+
+// Expiries returns the expiry dates of the client certificates, certificate authorities and
+// JWT bearer tokens embedded in the config named name, sorted by date.
+func (m *Manager) Expiries(ctx context.Context, name string) ([]Expiry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	co.ConfigName = name
+	return co.ConfigExpiries()
+}
+
+// UpdateMetadata calls update with the metadata of the config named name and stores the
+// result. Invalid label or annotation keys and label values are rejected with ErrInvalid.
+func (m *Manager) UpdateMetadata(ctx context.Context, name string, update func(md *Metadata) error) (Config, error) {
+	if err := ctx.Err(); err != nil {
+		return Config{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return Config{}, err
+	}
+	co.ConfigName = name
+	if err := co.UpdateMetadata(update); err != nil {
+		return Config{}, err
+	}
+	return withMetadata(co, config(co, path.Join(co.CObasePath, name)))
+}
+
+// SetGroup adds the config named name to group or removes it from group if member is false.
+func (m *Manager) SetGroup(ctx context.Context, name, group string, member bool) (Config, error) {
+	if err := ctx.Err(); err != nil {
+		return Config{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return Config{}, err
+	}
+	co.ConfigName = name
+	if err := co.SetGroup(group, member); err != nil {
+		return Config{}, err
+	}
+	return withMetadata(co, config(co, path.Join(co.CObasePath, name)))
+}
+
+// Groups returns all groups with at least one config in alphabetical order.
+func (m *Manager) Groups(ctx context.Context) ([]Group, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	byName, err := co.Groups()
+	if err != nil {
+		return nil, err
+	}
+	groups := make([]Group, 0, len(byName))
+	for name, configs := range byName {
+		groups = append(groups, Group{Name: name, Configs: configs})
+	}
+	sort.Slice(groups, func(i, j int) bool { return groups[i].Name < groups[j].Name })
+	return groups, nil
+}
+
+// Export writes the named configs and their metadata as gzip compressed tar archive to w.
+// The archive has the layout of the store directory, so it can be unpacked into another store.
+func (m *Manager) Export(ctx context.Context, w io.Writer, names ...string) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	co, err := m.co()
+	if err != nil {
+		return err
+	}
+	return co.Export(w, names)
+}
+
+// Resolve returns the config name meant by name: the name of an existing config, the config of
+// an alias or the config whose name or alias starts with name. A prefix of several configs
+// returns ErrAmbiguous. Unknown names are returned unchanged.
+func (m *Manager) Resolve(ctx context.Context, name string) (string, error) {
+	if err := ctx.Err(); err != nil {
+		return "", err
+	}
+	co, err := m.co()
+	if err != nil {
+		return "", err
+	}
+	return co.ResolveName(name)
+}
+
+// Aliases returns all aliases mapped to the names of their configs.
+func (m *Manager) Aliases(ctx context.Context) (map[string]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	return co.Aliases()
+}
+
+// AddAlias stores alias in the metadata of the config named name. ErrExists is returned if
+// alias is the name of a config or an alias of another config.
+func (m *Manager) AddAlias(ctx context.Context, name, alias string) (Config, error) {
+	if err := ctx.Err(); err != nil {
+		return Config{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return Config{}, err
+	}
+	co.ConfigName = name
+	if err := co.AddAlias(alias); err != nil {
+		return Config{}, err
+	}
+	return withMetadata(co, config(co, path.Join(co.CObasePath, name)))
+}
+
+// RemoveAlias removes an alias stored by AddAlias and returns the config it belonged to.
+func (m *Manager) RemoveAlias(ctx context.Context, alias string) (Config, error) {
+	if err := ctx.Err(); err != nil {
+		return Config{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return Config{}, err
+	}
+	name, err := co.RemoveAlias(alias)
+	if err != nil {
+		return Config{}, err
+	}
+	return withMetadata(co, config(co, path.Join(co.CObasePath, name)))
+}
+
+// Suggestions returns the config names and aliases close to name, the closest first. Switch
+// and Delete add them to their ErrNotFound errors.
+func (m *Manager) Suggestions(ctx context.Context, name string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	return co.Suggestions(name), nil
+}
+
+// Env returns the environment variables stored in the metadata of the config named name and
+// the variables of the config used before to unset. If name is empty the current config is
+// used, whose variables replace the ones of the previous config. Otherwise the variables of the
+// current config are replaced.
+func (m *Manager) Env(ctx context.Context, name string) (Env, error) {
+	if err := ctx.Err(); err != nil {
+		return Env{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return Env{}, err
+	}
+	if name == "" {
+		current := config(co, co.EffectiveConfigPath())
+		if current.Name == "" {
+			return Env{}, fmt.Errorf("%w: %s is not linked to a stored config", ErrNotFound, co.KubeConfigPath)
+		}
+		name = current.Name
+	}
+	set, unset, err := co.EnvChanges(name)
+	if err != nil {
+		return Env{}, err
+	}
+	return Env{Set: set, Unset: unset}, nil
+}
+
+// Prompt returns the name, context, namespace, environment, color, protection and first
+// expiring credential of the current config. It is meant to run on every shell prompt, the
+// result is cached until the kube config links to another file or the config or its metadata
+// change. The Name of the result is empty if the kube config is not linked to a stored config.
+func (m *Manager) Prompt(ctx context.Context) (PromptInfo, error) {
+	if err := ctx.Err(); err != nil {
+		return PromptInfo{}, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return PromptInfo{}, err
+	}
+	return co.PromptInfo()
+}
+
+// Migrate moves the configs and their metadata from the store directory from to the store
+// directory of the Manager and the previous link to its state directory. The kube config and
+// previous links are pointed to the new location. An empty from migrates the default store
+// directory ~/.kube/co. It returns the names of the moved files. Nothing is moved if one of them
+// already exists in the store directory (ErrExists).
+func (m *Manager) Migrate(ctx context.Context, from string) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	if from == "" {
+		from = DefaultStoreDir(m.home)
+	}
+	return co.Migrate(from)
+}
+
+// Stats returns the usage of all configs in the order by, see SortConfigs. Configs which weren't
+// used within the last unusedDays days are marked as unused.
+func (m *Manager) Stats(ctx context.Context, by string, unusedDays int) ([]ConfigStats, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	return co.Stats(by, unusedDays, time.Now())
+}
+
+// AuditLog returns the entries of the audit log selected by filter, the oldest first. Every
+// add, switch, delete, rename and metadata change is recorded with the user, host, terminal and
+// outcome.
+func (m *Manager) AuditLog(ctx context.Context, filter AuditFilter) ([]AuditEntry, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	return co.AuditLog(filter)
+}
+
+// Relink rewrites the kube config link, the previous link and the links in the store directory
+// in place to relative targets if relative is set and to absolute ones otherwise. Links to
+// missing files are pointed to the config of the same name in the store, which repairs a home
+// directory restored to another path. It returns the paths of the rewritten links.
+func (m *Manager) Relink(ctx context.Context, relative bool) ([]string, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	co, err := m.co()
+	if err != nil {
+		return nil, err
+	}
+	return co.Relink(relative)
+}
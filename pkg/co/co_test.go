@@ -0,0 +1,705 @@
+package co
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newManager(t *testing.T, opts ...Option) (*MemFS, *Manager) {
+	t.Setenv("KUBECONFIG", "")
+	fsys := NewMemFS()
+	require.NoError(t, fsys.MkdirAll("/home", 0700))
+	manager, err := NewManager("/home", append([]Option{WithFS(fsys)}, opts...)...)
+	require.NoError(t, err)
+	return fsys, manager
+}
+
+// withoutMetadata clears the metadata of configs so they can be compared without timestamps.
+func withoutMetadata(configs ...Config) []Config {
+	for i := range configs {
+		configs[i].Metadata = Metadata{}
+	}
+	return configs
+}
+
+func TestNewManager(t *testing.T) {
+	t.Run("Unknown backend", func(t *testing.T) {
+		_, err := NewManager(t.TempDir(), WithBackend("s3"))
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "unknown store backend")
+	})
+}
+
+func TestManager(t *testing.T) {
+	ctx := context.Background()
+
+	t.Run("Add and List", func(t *testing.T) {
+		_, manager := newManager(t)
+		cfg, err := manager.Add(ctx, "dev", "")
+		require.NoError(t, err)
+		assert.Equal(t, Config{Name: "dev", Path: "/home/.kube/co/dev"}, cfg)
+
+		_, err = manager.Add(ctx, "prod", "")
+		require.NoError(t, err)
+		configs, err := manager.List(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []Config{
+			{Name: "dev", Path: "/home/.kube/co/dev"},
+			{Name: "prod", Path: "/home/.kube/co/prod"},
+		}, withoutMetadata(configs...))
+	})
+
+	t.Run("Add copies source", func(t *testing.T) {
+		fsys, manager := newManager(t)
+		require.NoError(t, fsys.WriteFile("/home/source", []byte("kind: Config\n"), 0600))
+
+		_, err := manager.Add(ctx, "dev", "/home/source")
+		require.NoError(t, err)
+		got, err := fsys.ReadFile("/home/.kube/co/dev")
+		require.NoError(t, err)
+		assert.Equal(t, []byte("kind: Config\n"), got)
+	})
+
+	t.Run("Switch, Current and Previous", func(t *testing.T) {
+		_, manager := newManager(t)
+		_, err := manager.Add(ctx, "dev", "")
+		require.NoError(t, err)
+		_, err = manager.Add(ctx, "prod", "")
+		require.NoError(t, err)
+
+		_, err = manager.Current(ctx)
+		require.ErrorIs(t, err, ErrNotFound)
+		_, err = manager.Previous(ctx)
+		require.ErrorIs(t, err, ErrNoPrevious)
+
+		result, err := manager.Switch(ctx, "dev")
+		require.NoError(t, err)
+		assert.Equal(t, SwitchResult{
+			Config:         Config{Name: "dev", Path: "/home/.kube/co/dev", Current: true},
+			KubeConfigPath: "/home/.kube/config",
+		}, result)
+
+		result, err = manager.Switch(ctx, "prod")
+		require.NoError(t, err)
+		assert.Equal(t, "/home/.kube/co/dev", result.From)
+
+		current, err := manager.Current(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []Config{{Name: "prod", Path: "/home/.kube/co/prod", Current: true}}, withoutMetadata(current))
+
+		result, err = manager.Previous(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "dev", result.Config.Name)
+		assert.Equal(t, "/home/.kube/co/prod", result.From)
+	})
+
+	t.Run("Switch not found", func(t *testing.T) {
+		_, manager := newManager(t)
+		_, err := manager.Switch(ctx, "missing")
+		require.ErrorIs(t, err, ErrNotFound)
+		_, err = manager.Switch(ctx, "")
+		require.ErrorIs(t, err, ErrInvalid)
+	})
+
+	t.Run("Delete", func(t *testing.T) {
+		_, manager := newManager(t)
+		_, err := manager.Add(ctx, "dev", "")
+		require.NoError(t, err)
+		_, err = manager.Add(ctx, "prod", "")
+		require.NoError(t, err)
+		_, err = manager.Switch(ctx, "prod")
+		require.NoError(t, err)
+		_, err = manager.Switch(ctx, "dev")
+		require.NoError(t, err)
+
+		result, err := manager.Delete(ctx, "dev")
+		require.NoError(t, err)
+		assert.Equal(t, "dev", result.Deleted.Name)
+		assert.Equal(t, "prod", result.Switch.Config.Name)
+		configs, err := manager.List(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []Config{{Name: "prod", Path: "/home/.kube/co/prod", Current: true}}, withoutMetadata(configs...))
+
+		_, err = manager.Delete(ctx, "dev")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Rename", func(t *testing.T) {
+		_, manager := newManager(t)
+		_, err := manager.Add(ctx, "dev", "")
+		require.NoError(t, err)
+		_, err = manager.Add(ctx, "prod", "")
+		require.NoError(t, err)
+		_, err = manager.Switch(ctx, "dev")
+		require.NoError(t, err)
+
+		cfg, err := manager.Rename(ctx, "dev", "development")
+		require.NoError(t, err)
+		assert.Equal(t, Config{Name: "development", Path: "/home/.kube/co/development", Current: true}, cfg)
+
+		_, err = manager.Rename(ctx, "development", "prod")
+		require.ErrorIs(t, err, ErrExists)
+	})
+
+	t.Run("Check", func(t *testing.T) {
+		server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			fmt.Fprint(w, `{"gitVersion":"v1.31.0"}`)
+		}))
+		t.Cleanup(server.Close)
+		fsys, manager := newManager(t)
+		for _, name := range []string{"dev", "prod"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+		}
+		kubeConfig := fmt.Sprintf("clusters:\n- name: c\n  cluster:\n    server: %s\n    insecure-skip-tls-verify: true\ncontexts:\n- name: c\n  context:\n    cluster: c\n", server.URL)
+		require.NoError(t, fsys.WriteFile("/home/.kube/co/dev", []byte(kubeConfig), 0600))
+
+		results, err := manager.Check(ctx, CheckOptions{})
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.True(t, results[0].Healthy())
+		assert.Equal(t, "v1.31.0", results[0].ServerVersion)
+		assert.Equal(t, "prod", results[1].Config)
+		assert.ErrorIs(t, results[1].Err, ErrInvalid)
+
+		results, err = manager.Check(ctx, CheckOptions{}, "dev")
+		require.NoError(t, err)
+		assert.Len(t, results, 1)
+
+		_, err = manager.Check(ctx, CheckOptions{}, "missing")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Expiries", func(t *testing.T) {
+		fsys, manager := newManager(t)
+		_, err := manager.Add(ctx, "dev", "")
+		require.NoError(t, err)
+		exp := time.Now().Add(24 * time.Hour).Unix()
+		claims := base64.RawURLEncoding.EncodeToString(fmt.Appendf(nil, `{"exp":%d}`, exp))
+		kubeConfig := fmt.Sprintf("users:\n- name: sa\n  user:\n    token: e30.%s.sig\n", claims)
+		require.NoError(t, fsys.WriteFile("/home/.kube/co/dev", []byte(kubeConfig), 0600))
+
+		expiries, err := manager.Expiries(ctx, "dev")
+		require.NoError(t, err)
+		require.Len(t, expiries, 1)
+		assert.Equal(t, Expiry{Kind: ExpiryToken, Name: "sa", NotAfter: time.Unix(exp, 0)}, expiries[0])
+
+		result, err := manager.Switch(ctx, "dev")
+		require.NoError(t, err)
+		assert.Empty(t, result.Expiring)
+
+		warning, err := NewManager("/home", WithFS(fsys), WithWarnDays(DefaultWarnDays))
+		require.NoError(t, err)
+		result, err = warning.Switch(ctx, "dev")
+		require.NoError(t, err)
+		assert.Equal(t, expiries, result.Expiring)
+	})
+
+	t.Run("Protect", func(t *testing.T) {
+		fsys, manager := newManager(t)
+		for _, name := range []string{"dev", "prod", "live"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+		}
+
+		cfg, err := manager.Protect(ctx, "prod", false, "prod")
+		require.NoError(t, err)
+		assert.Equal(t, []Config{{Name: "prod", Path: "/home/.kube/co/prod", Environment: "prod", Protected: true}}, withoutMetadata(cfg))
+		_, err = manager.Protect(ctx, "live", true, "")
+		require.NoError(t, err)
+		_, err = manager.Protect(ctx, "missing", true, "")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		configs, err := manager.List(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []bool{false, true, true}, []bool{configs[0].Protected, configs[1].Protected, configs[2].Protected})
+
+		_, err = manager.Switch(ctx, "prod")
+		require.ErrorIs(t, err, ErrProtected)
+		assert.Equal(t, ExitProtected, ExitCode(err))
+		_, err = manager.Delete(ctx, "live")
+		require.ErrorIs(t, err, ErrProtected)
+
+		confirmed, err := NewManager("/home", WithFS(fsys), WithConfirm(func(name string) bool { return name == "prod" }))
+		require.NoError(t, err)
+		_, err = confirmed.Switch(ctx, "prod")
+		require.NoError(t, err)
+		_, err = confirmed.Switch(ctx, "live")
+		require.ErrorIs(t, err, ErrProtected)
+
+		unprotectedEnv, err := NewManager("/home", WithFS(fsys), WithProtectedEnvironments())
+		require.NoError(t, err)
+		_, err = unprotectedEnv.Switch(ctx, "prod")
+		require.NoError(t, err)
+		current, err := unprotectedEnv.Current(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []Config{{Name: "prod", Path: "/home/.kube/co/prod", Current: true, Environment: "prod"}}, withoutMetadata(current))
+	})
+
+	t.Run("Metadata and selectors", func(t *testing.T) {
+		_, manager := newManager(t)
+		for _, name := range []string{"dev", "prod", "staging"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+		}
+		setLabels := func(name string, labels map[string]string) {
+			_, err := manager.UpdateMetadata(ctx, name, func(md *Metadata) error {
+				md.Labels = labels
+				return nil
+			})
+			require.NoError(t, err)
+		}
+		setLabels("dev", map[string]string{"env": "dev", "team": "payments"})
+		setLabels("prod", map[string]string{"env": "prod", "team": "payments"})
+
+		cfg, err := manager.UpdateMetadata(ctx, "staging", func(md *Metadata) error {
+			md.Description = "Staging cluster"
+			md.Environment = "staging"
+			return nil
+		})
+		require.NoError(t, err)
+		assert.Equal(t, "Staging cluster", cfg.Metadata.Description)
+		assert.Equal(t, "staging", cfg.Environment)
+		assert.False(t, cfg.Metadata.Created.IsZero())
+
+		_, err = manager.UpdateMetadata(ctx, "dev", func(md *Metadata) error {
+			md.Labels = map[string]string{"not valid": "x"}
+			return nil
+		})
+		require.ErrorIs(t, err, ErrInvalid)
+
+		tests := map[string][]string{
+			"env=prod":          {"prod"},
+			"team=payments":     {"dev", "prod"},
+			"team!=payments":    {"staging"},
+			"env=staging":       {"staging"},
+			"team,env!=dev":     {"prod"},
+			"!team":             {"staging"},
+			"":                  {"dev", "prod", "staging"},
+			"env in (dev,prod)": nil,
+		}
+		for selector, want := range tests {
+			filter, err := Selector(selector)
+			if want == nil {
+				require.ErrorIs(t, err, ErrInvalid, selector)
+				continue
+			}
+			require.NoError(t, err, selector)
+			configs, err := manager.List(ctx, filter)
+			require.NoError(t, err)
+			names := []string{}
+			for _, cfg := range configs {
+				names = append(names, cfg.Name)
+			}
+			assert.Equal(t, want, names, selector)
+		}
+
+		result, err := manager.Switch(ctx, "dev")
+		require.NoError(t, err)
+		current, err := manager.Current(ctx)
+		require.NoError(t, err)
+		assert.False(t, current.Metadata.LastUsed.IsZero())
+		assert.Equal(t, "dev", result.Config.Name)
+
+		_, err = manager.Switch(ctx, "prod")
+		require.ErrorIs(t, err, ErrProtected)
+	})
+
+	t.Run("Groups", func(t *testing.T) {
+		fsys, manager := newManager(t)
+		for _, name := range []string{"a-dev", "a-prod", "b-dev"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+		}
+		for _, name := range []string{"a-dev", "a-prod"} {
+			cfg, err := manager.SetGroup(ctx, name, "customer-a", true)
+			require.NoError(t, err)
+			assert.Equal(t, []string{"customer-a"}, cfg.Metadata.Groups)
+		}
+		_, err := manager.SetGroup(ctx, "b-dev", "customer-b", true)
+		require.NoError(t, err)
+		_, err = manager.SetGroup(ctx, "b-dev", "not valid", true)
+		require.ErrorIs(t, err, ErrInvalid)
+
+		groups, err := manager.Groups(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []Group{
+			{Name: "customer-a", Configs: []string{"a-dev", "a-prod"}},
+			{Name: "customer-b", Configs: []string{"b-dev"}},
+		}, groups)
+
+		configs, err := manager.List(ctx, InGroup("customer-b"))
+		require.NoError(t, err)
+		require.Len(t, configs, 1)
+		assert.Equal(t, "b-dev", configs[0].Name)
+
+		_, err = manager.SetGroup(ctx, "b-dev", "customer-b", false)
+		require.NoError(t, err)
+		groups, err = manager.Groups(ctx)
+		require.NoError(t, err)
+		assert.Len(t, groups, 1)
+
+		require.NoError(t, fsys.WriteFile("/home/.kube/co/a-dev", []byte("config"), 0600))
+		buf := &bytes.Buffer{}
+		require.NoError(t, manager.Export(ctx, buf, "a-dev", "a-prod"))
+		assert.NotZero(t, buf.Len())
+		require.ErrorIs(t, manager.Export(ctx, buf, "missing"), ErrNotFound)
+	})
+
+	t.Run("Aliases", func(t *testing.T) {
+		_, manager := newManager(t, WithAliases(map[string]string{"d": "dev"}), WithPrefixMatch(true))
+		for _, name := range []string{"dev", "arn-aws-eks-staging"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+		}
+		cfg, err := manager.AddAlias(ctx, "arn-aws-eks-staging", "stg")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"stg"}, cfg.Metadata.Aliases)
+		_, err = manager.AddAlias(ctx, "dev", "stg")
+		require.ErrorIs(t, err, ErrExists)
+
+		aliases, err := manager.Aliases(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]string{"d": "dev", "stg": "arn-aws-eks-staging"}, aliases)
+
+		resolved, err := manager.Resolve(ctx, "arn")
+		require.NoError(t, err)
+		assert.Equal(t, "arn-aws-eks-staging", resolved)
+
+		result, err := manager.Switch(ctx, "stg")
+		require.NoError(t, err)
+		assert.Equal(t, "arn-aws-eks-staging", result.Config.Name)
+
+		_, err = manager.Add(ctx, "devel", "")
+		require.NoError(t, err)
+		_, err = manager.Switch(ctx, "de")
+		require.ErrorIs(t, err, ErrAmbiguous)
+		assert.Equal(t, ExitConflict, ExitCode(err))
+
+		result, err = manager.Switch(ctx, "d")
+		require.NoError(t, err)
+		assert.Equal(t, "dev", result.Config.Name)
+		_, err = manager.Delete(ctx, "deve")
+		require.ErrorIs(t, err, ErrNotFound, "delete doesn't resolve prefixes")
+		deleted, err := manager.Delete(ctx, "devel")
+		require.NoError(t, err)
+		assert.Equal(t, "devel", deleted.Deleted.Name)
+
+		cfg, err = manager.RemoveAlias(ctx, "stg")
+		require.NoError(t, err)
+		assert.Equal(t, "arn-aws-eks-staging", cfg.Name)
+		assert.Empty(t, cfg.Metadata.Aliases)
+	})
+
+	t.Run("Suggestions", func(t *testing.T) {
+		_, manager := newManager(t, WithPrefixMatch(false))
+		for _, name := range []string{"dev", "staging"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+		}
+
+		suggestions, err := manager.Suggestions(ctx, "stagign")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"staging"}, suggestions)
+
+		_, err = manager.Switch(ctx, "stag")
+		require.ErrorIs(t, err, ErrNotFound)
+		assert.Contains(t, err.Error(), "Did you mean staging?")
+		_, err = manager.Delete(ctx, "stag")
+		require.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Hooks", func(t *testing.T) {
+		_, manager := newManager(t, WithHooks(Hooks{
+			Configs: map[string]ConfigHooks{"prod": {PreSwitch: []string{`test "$KUBECTL_CO_OLD_NAME" != dev`}}},
+		}))
+		for _, name := range []string{"dev", "prod", "staging"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+		}
+		_, err := manager.Switch(ctx, "dev")
+		require.NoError(t, err)
+
+		_, err = manager.Switch(ctx, "prod")
+		require.ErrorIs(t, err, ErrVetoed)
+		current, err := manager.Current(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "dev", current.Name)
+
+		_, err = manager.Switch(ctx, "staging")
+		require.NoError(t, err)
+		_, err = manager.Switch(ctx, "prod")
+		require.NoError(t, err)
+	})
+
+	t.Run("Env", func(t *testing.T) {
+		_, manager := newManager(t)
+		_, err := manager.Env(ctx, "")
+		require.ErrorIs(t, err, ErrNotFound)
+
+		for name, profile := range map[string]string{"dev": "dev", "staging": ""} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+			if profile == "" {
+				continue
+			}
+			_, err = manager.UpdateMetadata(ctx, name, func(md *Metadata) error {
+				md.Variables = map[string]string{"AWS_PROFILE": profile}
+				return nil
+			})
+			require.NoError(t, err)
+		}
+		_, err = manager.Switch(ctx, "dev")
+		require.NoError(t, err)
+		_, err = manager.Switch(ctx, "staging")
+		require.NoError(t, err)
+
+		env, err := manager.Env(ctx, "")
+		require.NoError(t, err)
+		assert.Equal(t, Env{Set: map[string]string{}, Unset: []string{"AWS_PROFILE"}}, env)
+
+		env, err = manager.Env(ctx, "dev")
+		require.NoError(t, err)
+		assert.Equal(t, Env{Set: map[string]string{"AWS_PROFILE": "dev"}, Unset: []string{}}, env)
+	})
+
+	t.Run("Doctor", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", "")
+		fsys := NewMemFS()
+		require.NoError(t, fsys.MkdirAll("/home", 0700))
+		problems, err := Doctor(ctx, "/home", true, WithFS(fsys))
+		require.NoError(t, err)
+		require.Len(t, problems, 3)
+		assert.True(t, problems[0].Fixed)
+		assert.True(t, problems[1].Fixed)
+		assert.Equal(t, SeverityInfo, problems[2].Severity)
+
+		_, err = NewManager("/home", WithFS(fsys))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Migrate", func(t *testing.T) {
+		fsys, manager := newManager(t)
+		_, err := manager.Add(ctx, "dev", "")
+		require.NoError(t, err)
+		_, err = manager.Migrate(ctx, "")
+		assert.ErrorIs(t, err, ErrInvalid, "the default store can't be migrated onto itself")
+
+		manager, err = NewManager("/home", WithFS(fsys), WithStoreDir("/home/store"), WithStateDir("/home/state"))
+		require.NoError(t, err)
+		moved, err := manager.Migrate(ctx, "")
+		require.NoError(t, err)
+		assert.Contains(t, moved, "dev")
+		configs, err := manager.List(ctx)
+		require.NoError(t, err)
+		require.Len(t, configs, 1)
+		assert.Equal(t, "/home/store/dev", configs[0].Path)
+	})
+
+	t.Run("Stats", func(t *testing.T) {
+		_, manager := newManager(t)
+		for _, name := range []string{"dev", "prod", "staging"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+		}
+		for _, name := range []string{"prod", "dev", "prod"} {
+			_, err := manager.Switch(ctx, name)
+			require.NoError(t, err)
+		}
+
+		configs, err := manager.List(ctx)
+		require.NoError(t, err)
+		require.NoError(t, SortConfigs(configs, SortFrequent))
+		assert.Equal(t, []string{"prod", "dev", "staging"}, []string{configs[0].Name, configs[1].Name, configs[2].Name})
+		assert.ErrorIs(t, SortConfigs(configs, "size"), ErrInvalid)
+
+		stats, err := manager.Stats(ctx, SortFrequent, DefaultUnusedDays)
+		require.NoError(t, err)
+		require.Len(t, stats, 3)
+		assert.Equal(t, "prod", stats[0].Name)
+		assert.Equal(t, 2, stats[0].SwitchCount)
+		assert.False(t, stats[2].Unused, "staging was created just now")
+	})
+
+	t.Run("Expiry", func(t *testing.T) {
+		_, manager := newManager(t)
+		for _, name := range []string{"dev", "breakglass"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+			_, err = manager.Switch(ctx, name)
+			require.NoError(t, err)
+		}
+		expires := time.Now().Add(-time.Minute)
+		config, err := manager.SetExpiry(ctx, "breakglass", expires)
+		require.NoError(t, err)
+		assert.Equal(t, expires.UTC().Truncate(time.Second), config.Metadata.Expires)
+
+		result, err := manager.CollectExpired(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"breakglass"}, result.Trashed)
+		assert.Equal(t, "dev", result.SwitchedTo)
+		current, err := manager.Current(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "dev", current.Name)
+		_, err = manager.Switch(ctx, "breakglass")
+		assert.ErrorIs(t, err, ErrNotFound)
+	})
+
+	t.Run("Add from stdin", func(t *testing.T) {
+		data := "clusters:\n- name: dev\n  cluster:\n    server: https://127.0.0.1:6443\n"
+		fsys, manager := newManager(t, WithStdin(strings.NewReader(data)))
+		_, err := manager.Add(ctx, "dev", StdinSource)
+		require.NoError(t, err)
+		got, err := fsys.ReadFile("/home/.kube/co/dev")
+		require.NoError(t, err)
+		assert.Equal(t, data, string(got))
+
+		_, manager = newManager(t, WithStdin(strings.NewReader("not: a kubeconfig\n")))
+		_, err = manager.Add(ctx, "dev", StdinSource)
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+
+	t.Run("Update", func(t *testing.T) {
+		fsys, manager := newManager(t)
+		require.NoError(t, fsys.WriteFile("/home/dev.yaml", []byte("clusters:\n- name: dev\n  cluster:\n    server: https://old\n"), 0600))
+		_, err := manager.Add(ctx, "dev", "/home/dev.yaml")
+		require.NoError(t, err)
+		_, err = manager.Add(ctx, "dev", "/home/dev.yaml")
+		assert.ErrorIs(t, err, ErrExists)
+
+		require.NoError(t, fsys.WriteFile("/home/dev.yaml", []byte("clusters:\n- name: dev\n  cluster:\n    server: https://new\n"), 0600))
+		result, err := manager.Update(ctx, "dev", "/home/dev.yaml", false)
+		require.NoError(t, err)
+		assert.True(t, result.Changed)
+		assert.Contains(t, result.Diff, "+    server: https://new")
+		assert.NotEmpty(t, result.Backup)
+		data, err := fsys.ReadFile("/home/.kube/co/dev")
+		require.NoError(t, err)
+		assert.Contains(t, string(data), "https://new")
+	})
+
+	t.Run("Import", func(t *testing.T) {
+		fsys, manager := newManager(t)
+		require.NoError(t, fsys.MkdirAll("/home/Downloads", 0700))
+		data := []byte("current-context: kind-dev\nclusters:\n- name: kind-dev\n  cluster:\n    server: https://127.0.0.1:6443\n")
+		require.NoError(t, fsys.WriteFile("/home/Downloads/kubeconfig-1.yaml", data, 0600))
+		require.NoError(t, fsys.WriteFile("/home/Downloads/kubeconfig-2.yaml", data, 0600))
+
+		results, err := manager.Import(ctx, "/home/Downloads", false)
+		require.NoError(t, err)
+		require.Len(t, results, 2)
+		assert.Equal(t, "kind-dev", results[0].Name)
+		assert.False(t, results[0].Duplicate)
+		assert.True(t, results[1].Duplicate)
+		current, err := manager.Switch(ctx, "kind-dev")
+		require.NoError(t, err)
+		assert.Equal(t, "kind-dev", current.Config.Name)
+	})
+
+	t.Run("Audit log", func(t *testing.T) {
+		_, manager := newManager(t, WithAuditLog(true, 0, 0))
+		_, err := manager.Add(ctx, "dev", "")
+		require.NoError(t, err)
+		_, err = manager.Switch(ctx, "dev")
+		require.NoError(t, err)
+		_, err = manager.Switch(ctx, "missing")
+		require.Error(t, err)
+
+		entries, err := manager.AuditLog(ctx, AuditFilter{})
+		require.NoError(t, err)
+		require.Len(t, entries, 3)
+		assert.Equal(t, AuditAdd, entries[0].Action)
+		assert.Equal(t, AuditLink, entries[1].Action)
+		assert.Equal(t, AuditSuccess, entries[1].Outcome)
+
+		entries, err = manager.AuditLog(ctx, AuditFilter{Outcome: AuditFailure})
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "missing", entries[0].Config)
+	})
+
+	t.Run("Relink", func(t *testing.T) {
+		fsys, manager := newManager(t, WithRelativeLinks(true))
+		for _, name := range []string{"dev", "prod"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+			_, err = manager.Switch(ctx, name)
+			require.NoError(t, err)
+		}
+		target, err := fsys.Readlink("/home/.kube/config")
+		require.NoError(t, err)
+		assert.Equal(t, "co/prod", target)
+
+		rewritten, err := manager.Relink(ctx, false)
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/home/.kube/config", "/home/.kube/co/previous"}, rewritten)
+		current, err := manager.Current(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "prod", current.Name)
+	})
+
+	t.Run("Env mode", func(t *testing.T) {
+		_, manager := newManager(t, WithMode(ModeEnv), WithKubeConfigEnv("/tmp/extra"))
+		for _, name := range []string{"dev", "prod"} {
+			_, err := manager.Add(ctx, name, "")
+			require.NoError(t, err)
+		}
+		result, err := manager.Switch(ctx, "dev")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/home/.kube/co/dev", "/tmp/extra"}, result.KubeConfigEnv)
+		assert.Empty(t, result.KubeConfigPath)
+		_, err = manager.Current(ctx)
+		assert.ErrorIs(t, err, ErrNotFound, "the KUBECONFIG of the process is unchanged and lists no existing file")
+
+		files, err := manager.Merge(ctx, "prod")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"/tmp/extra", "/home/.kube/co/prod"}, files)
+
+		_, manager = newManager(t, WithKubeConfigEnv("/home/.kube/config:/home/.kube/co/prod"))
+		files, err = manager.Unmerge(ctx, "prod")
+		require.NoError(t, err)
+		assert.Empty(t, files)
+
+		_, err = NewManager("/home", WithFS(NewMemFS()), WithMode("copy"))
+		assert.ErrorIs(t, err, ErrInvalid)
+	})
+
+	t.Run("Prompt", func(t *testing.T) {
+		fsys, manager := newManager(t)
+		info, err := manager.Prompt(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, PromptInfo{}, info)
+
+		_, err = manager.Add(ctx, "dev", "")
+		require.NoError(t, err)
+		require.NoError(t, fsys.WriteFile("/home/.kube/co/dev", []byte("current-context: admin\n"), 0600))
+		_, err = manager.Switch(ctx, "dev")
+		require.NoError(t, err)
+
+		info, err = manager.Prompt(ctx)
+		require.NoError(t, err)
+		assert.Equal(t, "dev", info.Name)
+		assert.Equal(t, "admin", info.Context)
+	})
+
+	t.Run("Canceled context", func(t *testing.T) {
+		_, manager := newManager(t)
+		canceled, cancel := context.WithCancel(ctx)
+		cancel()
+
+		_, err := manager.List(canceled)
+		require.ErrorIs(t, err, context.Canceled)
+		_, err = manager.Switch(canceled, "dev")
+		require.ErrorIs(t, err, context.Canceled)
+	})
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strings"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+// defaultPromptFormat is the format of the prompt command if none is configured.
+const defaultPromptFormat = "{color}{name}{reset}{expiry}"
+
+func init() {
+	registerCommand(&command{
+		name:  "prompt",
+		short: "Print the current config for a shell prompt",
+		long: `The format can contain these placeholders:
+
+  {name}       name of the current config
+  {context}    current context of the config
+  {namespace}  namespace of the current context
+  {env}        environment of the config
+  {protected}  "!" if the config is protected
+  {expiry}     " [expired]" or " [expires in N days]" if a credential expires within warn-days
+  {color}      switches to the color of the config, {reset} back
+
+Nothing is printed if ~/.kube/config is not linked to a stored config. The result is cached,
+so it is fast enough for every prompt. With --shell bash or zsh the color codes are wrapped so
+the shell doesn't count them. --json prints everything for tools like starship.`,
+		maxArgs: 0,
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeyFormat, defaultPromptFormat, "Format of the prompt")
+			flags.Bool(viperKeyJSON, false, "Print the current config as JSON")
+			flags.String(viperKeyShell, "", "Wrap the color codes for the prompt of bash or zsh")
+		},
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			info, err := manager.Prompt(ctx)
+			if err != nil {
+				return err
+			}
+			if config.JSON {
+				return printPromptJSON(os.Stdout, info, time.Now())
+			}
+			if info.Name != "" {
+				fmt.Println(formatPrompt(config.Format, config.Shell, info, time.Now()))
+			}
+			return nil
+		},
+	})
+}
+
+// formatPrompt replaces the placeholders of format with the values of info.
+func formatPrompt(format, shell string, info co.PromptInfo, now time.Time) string {
+	colorCode, resetCode := "", ""
+	if attr, ok := colors[configColorName(info.Color, info.Environment)]; ok && os.Getenv("NO_COLOR") == "" {
+		colorCode = escapePrompt(shell, fmt.Sprintf("\x1b[%dm", attr))
+		resetCode = escapePrompt(shell, "\x1b[0m")
+	}
+	protected := ""
+	if info.Protected {
+		protected = "!"
+	}
+	expiry := ""
+	if info.Expiry != nil {
+		switch {
+		case info.Expiry.Expired(now):
+			expiry = " [expired]"
+		case info.Expiry.ExpiresWithin(config.WarnDays, now):
+			expiry = fmt.Sprintf(" [expires in %d days]", daysUntil(info.Expiry.NotAfter, now))
+		}
+	}
+	return strings.NewReplacer(
+		"{name}", info.Name,
+		"{context}", info.Context,
+		"{namespace}", info.Namespace,
+		"{env}", info.Environment,
+		"{protected}", protected,
+		"{expiry}", expiry,
+		"{color}", colorCode,
+		"{reset}", resetCode,
+	).Replace(format)
+}
+
+// escapePrompt marks the non-printing code for the prompt of shell.
+func escapePrompt(shell, code string) string {
+	switch shell {
+	case "bash":
+		return `\[` + code + `\]`
+	case "zsh":
+		return "%{" + code + "%}"
+	default:
+		return code
+	}
+}
+
+// promptJSON is the machine readable output of the prompt command.
+type promptJSON struct {
+	Name        string     `json:"name"`
+	Path        string     `json:"path"`
+	Context     string     `json:"context"`
+	Namespace   string     `json:"namespace"`
+	Environment string     `json:"environment"`
+	Color       string     `json:"color"`
+	Protected   bool       `json:"protected"`
+	ExpiresAt   *time.Time `json:"expiresAt,omitempty"`
+	Expiring    bool       `json:"expiring"`
+	Expired     bool       `json:"expired"`
+}
+
+func printPromptJSON(w io.Writer, info co.PromptInfo, now time.Time) error {
+	out := promptJSON{
+		Name:        info.Name,
+		Path:        info.Path,
+		Context:     info.Context,
+		Namespace:   info.Namespace,
+		Environment: info.Environment,
+		Protected:   info.Protected,
+	}
+	if info.Name != "" {
+		out.Color = configColorName(info.Color, info.Environment)
+	}
+	if info.Expiry != nil {
+		out.ExpiresAt = &info.Expiry.NotAfter
+		out.Expired = info.Expiry.Expired(now)
+		out.Expiring = info.Expiry.ExpiresWithin(config.WarnDays, now)
+	}
+	return json.NewEncoder(w).Encode(out)
+}
+
+// daysUntil returns the number of started days from now until t.
+func daysUntil(t, now time.Time) int {
+	return int(math.Ceil(t.Sub(now).Hours() / 24))
+}
@@ -0,0 +1,87 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	flag "github.com/spf13/pflag"
+)
+
+// confirmInput and confirmOutput are used to ask for confirmations. confirmInput is created once
+// since a reader buffers more than one answer of piped input.
+var (
+	confirmInput  *bufio.Reader = bufio.NewReader(os.Stdin)
+	confirmOutput io.Writer     = os.Stderr
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "protect",
+		args:  "<name>",
+		short: "Require a confirmation before switching to or deleting a config",
+		long: `Switching to or deleting a protected config asks to type the name of the config.
+Use --yes to skip the confirmation in scripts. Configs of the environments in the
+protected-environments setting (default prod and production) are protected as well.`,
+		minArgs:    1,
+		maxArgs:    1,
+		configArgs: 1,
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeyEnvironment, "", "Set the environment of the config, e.g. prod")
+		},
+		run: func(ctx context.Context, args []string) error {
+			return runProtect(ctx, args[0], true)
+		},
+	})
+	registerCommand(&command{
+		name:       "unprotect",
+		args:       "<name>",
+		short:      "Remove the protection of a config",
+		minArgs:    1,
+		maxArgs:    1,
+		configArgs: 1,
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeyEnvironment, "", "Set the environment of the config, e.g. dev")
+		},
+		run: func(ctx context.Context, args []string) error {
+			return runProtect(ctx, args[0], false)
+		},
+	})
+}
+
+func runProtect(ctx context.Context, name string, protected bool) error {
+	manager, err := newManager()
+	if err != nil {
+		return err
+	}
+	cfg, err := manager.Protect(ctx, name, protected, config.Environment)
+	if err != nil {
+		return err
+	}
+	switch {
+	case cfg.Protected && !protected:
+		fmt.Printf("%s is still protected by its environment %s\n", cfg.Name, cfg.Environment)
+	case cfg.Protected:
+		fmt.Println("Protected", cfg.Name)
+	default:
+		fmt.Println("Unprotected", cfg.Name)
+	}
+	return nil
+}
+
+// confirmProtected asks to type the name of the protected config. --yes confirms without asking.
+func confirmProtected(name string) bool {
+	if config.Yes {
+		return true
+	}
+	fmt.Fprintf(confirmOutput, "%s is protected. Type the name of the config to confirm: ", name)
+	answer, err := confirmInput.ReadString('\n')
+	if err != nil && answer == "" {
+		fmt.Fprintln(confirmOutput)
+		return false
+	}
+	return strings.TrimSpace(answer) == name
+}
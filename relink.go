@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/eslog"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "relink",
+		args:  "[--absolute]",
+		short: "Rewrite the kube config, previous and store links as relative links",
+		long: `Rewrites ~/.kube/config, the previous link and the links in the store directory in place,
+so they point to their targets relative to their directory and survive moving the home directory,
+e.g. into a dev container. A link to a missing file is pointed to the config of the same name in
+the store, which repairs the links of a home directory restored to another path. --absolute
+rewrites the links to absolute targets instead. Set relative-links to keep the links created by
+switching relative.`,
+		maxArgs: 0,
+		flags: func(flags *flag.FlagSet) {
+			flags.Bool(viperKeyAbsolute, false, "Rewrite the links to absolute targets")
+		},
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			relative := !config.Absolute
+			rewritten, err := manager.Relink(ctx, relative)
+			for _, link := range rewritten {
+				fmt.Println(link)
+			}
+			if err != nil {
+				return err
+			}
+			if len(rewritten) == 0 {
+				eslog.Info("All links are up to date")
+			}
+			if relative && !config.RelativeLinks {
+				eslog.Info("Switching creates absolute links again, set relative-links to keep them relative")
+			}
+			return nil
+		},
+	})
+}
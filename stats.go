@@ -0,0 +1,83 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/eslog"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "stats",
+		args:  "[--sort name|recent|frequent] [--unused-days <days>] [--unused]",
+		short: "Show how often and when the configs were used",
+		long: `Lists the number of switches to every config, its last use and creation. Configs which
+weren't used within --unused-days days, or were never used and created before, are marked as
+unused. They are candidates for a cleanup with 'kubectl co rm'. With --unused only they are
+listed. The usage is recorded since this version, so older configs start with zero switches
+and aren't marked until they are used or their creation is known.`,
+		maxArgs: 0,
+		flags: func(flags *flag.FlagSet) {
+			flags.String(viperKeySort, co.SortFrequent, "Order of the configs: "+strings.Join(co.SortOrders, ", "))
+			flags.Int(viperKeyUnusedDays, co.DefaultUnusedDays, "Days without use after which a config is unused")
+			flags.Bool(viperKeyUnused, false, "Only list the unused configs")
+		},
+		run: func(ctx context.Context, args []string) error {
+			if config.UnusedDays < 1 {
+				return fmt.Errorf("%w: --%s must be at least 1", co.ErrUsage, viperKeyUnusedDays)
+			}
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			stats, err := manager.Stats(ctx, config.Sort, config.UnusedDays)
+			if err != nil {
+				return err
+			}
+			unused := printStats(os.Stdout, stats, config.Unused)
+			if unused > 0 {
+				eslog.Infof("%d configs weren't used for %d days", unused, config.UnusedDays)
+			}
+			return nil
+		},
+	})
+}
+
+// printStats prints the usage of the configs as table and returns the number of unused configs.
+// With onlyUnused the used configs are left out.
+func printStats(w io.Writer, stats []co.ConfigStats, onlyUnused bool) int {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSWITCHES\tLAST-USED\tCREATED\tUNUSED")
+	unused := 0
+	for _, s := range stats {
+		if s.Unused {
+			unused++
+		} else if onlyUnused {
+			continue
+		}
+		mark := ""
+		if s.Unused {
+			mark = "*"
+		}
+		fmt.Fprintln(tw, strings.Join([]string{s.Name, strconv.Itoa(s.SwitchCount), formatStatsTime(s.LastUsed),
+			formatStatsTime(s.Created), mark}, "\t"))
+	}
+	eslog.LogIfErrorf(tw.Flush(), eslog.Errorf, "Error printing stats: %s")
+	return unused
+}
+
+func formatStatsTime(t time.Time) string {
+	if t.IsZero() {
+		return "-"
+	}
+	return t.Local().Format(time.DateTime)
+}
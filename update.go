@@ -0,0 +1,53 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	flag "github.com/spf13/pflag"
+	"github.com/steffakasid/eslog"
+	"github.com/steffakasid/kubectl-co/pkg/co"
+)
+
+func init() {
+	registerCommand(&command{
+		name:  "update",
+		args:  "<name> <path|-|url> [--dry-run]",
+		short: "Merge a refreshed kubeconfig into a stored config",
+		long: `Merges the kubeconfig at path, read from stdin with - or fetched from an http(s):// or
+file:// URL, into the stored config. New clusters, contexts and users are added, users are
+replaced, so refreshed credentials take effect, and the fields of clusters and contexts are
+updated. The namespaces of the contexts, the current context, the preferences and everything
+only the stored config has are kept. The diff is printed with every value of the users replaced
+by a hash and the previous version is kept in the .backup directory of the state directory.
+With --dry-run only the diff is printed.`,
+		minArgs:    2,
+		maxArgs:    2,
+		configArgs: 1,
+		flags: func(flags *flag.FlagSet) {
+			flags.Bool(viperKeyDryRun, false, "Only print the diff")
+			flags.Duration(viperKeyFetchTimeout, co.DefaultFetchTimeout, "Timeout for fetching the config from a URL")
+		},
+		run: func(ctx context.Context, args []string) error {
+			manager, err := newManager()
+			if err != nil {
+				return err
+			}
+			result, err := manager.Update(ctx, args[0], args[1], config.DryRun)
+			if err != nil {
+				return err
+			}
+			if !result.Changed {
+				eslog.Infof("%s is up to date", result.Name)
+				return nil
+			}
+			fmt.Print(result.Diff)
+			if config.DryRun {
+				eslog.Infof("Dry run, %s was not changed", result.Name)
+				return nil
+			}
+			eslog.Infof("Updated %s, the previous version is kept in %s", result.Name, result.Backup)
+			return nil
+		},
+	})
+}